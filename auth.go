@@ -0,0 +1,190 @@
+package cwsutil
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrMissingToken is embedded in the 401 response of requests without bearer token
+var ErrMissingToken = errors.New("missing bearer token")
+
+// ErrInsufficientScope is embedded in the 403 response of tokens without a required scope
+var ErrInsufficientScope = errors.New("insufficient scope")
+
+// DefaultJWTAlgorithms are the signing algorithms accepted when JWTConfig.Algorithms is empty
+var DefaultJWTAlgorithms = []string{"HS256", "RS256", "ES256"}
+
+// Claims are the registered claims with the common scope, roles and permissions claims
+// Embed it in application claims to add custom claims, e.g. type MyClaims struct { cwsutil.Claims; TenantId string `json:"tid"` }
+type Claims struct {
+	jwt.RegisteredClaims
+	// Scope is the space separated list of granted scopes
+	Scope string `json:"scope,omitempty"`
+	// Roles are the roles of the subject
+	Roles []string `json:"roles,omitempty"`
+	// Permissions are permissions granted directly to the subject
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// GetScopes returns the granted scopes
+func (c Claims) GetScopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// GetRoles returns the roles of the subject
+func (c Claims) GetRoles() []string {
+	return c.Roles
+}
+
+// GetPermissions returns the permissions granted directly to the subject
+func (c Claims) GetPermissions() []string {
+	return c.Permissions
+}
+
+// JWTConfig configures JWTMiddleware
+type JWTConfig struct {
+	// Keys are the verification keys by key id, the empty key id matches tokens without kid
+	// Use []byte secrets for HS256, *rsa.PublicKey for RS256 and *ecdsa.PublicKey for ES256
+	Keys map[string]any
+	// JWKS provides the keys that are not in Keys
+	JWKS *JWKS
+	// Algorithms are the accepted signing algorithms, DefaultJWTAlgorithms when empty
+	Algorithms []string
+	// Issuer is the required iss claim, empty accepts any issuer
+	Issuer string
+	// Audience are the accepted aud claims, a token must name one of them, empty accepts any audience
+	Audience []string
+	// Leeway is the clock skew tolerated when validating exp, nbf and iat
+	Leeway time.Duration
+	// RequiredScopes are the scopes a token must grant, claims expose them with GetScopes() []string
+	RequiredScopes []string
+	// Optional lets requests without token pass without claims, invalid tokens are still rejected
+	Optional bool
+	// TokenLookup returns the token of a request, the default reads the Authorization bearer token
+	TokenLookup func(c *gin.Context) string
+}
+
+// claimsKey is the context key holding the claims of the verified token
+type claimsKey struct{}
+
+// BearerToken returns the token of the Authorization: Bearer header, empty if there is none
+func BearerToken(c *gin.Context) string {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// JWTMiddleware verifies the JWT of each request and stores its claims of type C in the request context
+// The signature, exp (required), nbf, iat, iss and aud claims are validated with the configured leeway
+// Requests without or with an invalid token are rejected with UnauthorizedErrorResponse (401),
+// tokens missing a required scope with ForbiddenErrorResponse (403), both with a WWW-Authenticate header
+// e.g. router.Use(cwsutil.JWTMiddleware[cwsutil.Claims](cwsutil.JWTConfig{JWKS: cwsutil.NewJWKS(url), Issuer: issuer}))
+func JWTMiddleware[C any, PC interface {
+	*C
+	jwt.Claims
+}](config JWTConfig) gin.HandlerFunc {
+	algorithms := config.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = DefaultJWTAlgorithms
+	}
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(algorithms),
+		jwt.WithLeeway(config.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if len(config.Audience) > 0 {
+		options = append(options, jwt.WithAudience(config.Audience...))
+	}
+	parser := jwt.NewParser(options...)
+	lookup := config.TokenLookup
+	if lookup == nil {
+		lookup = BearerToken
+	}
+
+	return func(c *gin.Context) {
+		tokenString := lookup(c)
+		if tokenString == "" {
+			if config.Optional {
+				c.Next()
+				return
+			}
+			c.Header("WWW-Authenticate", "Bearer")
+			UnauthorizedErrorResponse.EmbedError(ErrMissingToken).WriteResponse(c)
+			c.Abort()
+			return
+		}
+
+		reqCtx := requestContext(c)
+		claims := PC(new(C))
+		_, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+			kid, _ := token.Header["kid"].(string)
+			if key, ok := config.Keys[kid]; ok {
+				return key, nil
+			}
+			if config.JWKS != nil {
+				return config.JWKS.Key(reqCtx, kid)
+			}
+			return nil, ErrJWKNotFound
+		})
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			UnauthorizedErrorResponse.EmbedError(err).WriteResponse(c)
+			c.Abort()
+			return
+		}
+
+		if len(config.RequiredScopes) > 0 {
+			var granted []string
+			if scoped, ok := any(claims).(interface{ GetScopes() []string }); ok {
+				granted = scoped.GetScopes()
+			}
+			for _, scope := range config.RequiredScopes {
+				if !containsString(granted, scope) {
+					c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(config.RequiredScopes, " ")+`"`)
+					ForbiddenErrorResponse.EmbedError(ErrInsufficientScope).WriteResponse(c)
+					c.Abort()
+					return
+				}
+			}
+		}
+
+		c.Request = c.Request.WithContext(context.WithValue(reqCtx, claimsKey{}, claims))
+		c.Next()
+	}
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// GetClaims returns the claims stored by JWTMiddleware, C must be the claims type of the middleware
+// ctx is the request context, or the context of a typed handler
+func GetClaims[C any](ctx context.Context) (*C, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	claims, ok := ctx.Value(claimsKey{}).(*C)
+	return claims, ok
+}
+
+// GetRequestClaims returns the claims stored by JWTMiddleware for the request, see GetClaims
+func GetRequestClaims[C any](c *gin.Context) (*C, bool) {
+	return GetClaims[C](requestContext(c))
+}
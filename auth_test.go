@@ -0,0 +1,248 @@
+package cwsutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type testClaims struct {
+	Claims
+	TenantId string `json:"tid"`
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+
+	server, err := NewJWKSTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	router := gin.New()
+	router.Use(JWTMiddleware[testClaims](JWTConfig{
+		Keys:           map[string]any{"hmac": secret},
+		JWKS:           server.JWKS(),
+		Issuer:         "https://issuer.example.com",
+		Audience:       []string{"orders", "billing"},
+		Leeway:         time.Minute,
+		RequiredScopes: []string{"orders:read"},
+	}))
+	router.GET("/orders", Handle(func(ctx context.Context, req struct{}) (string, error) {
+		claims, ok := GetClaims[testClaims](ctx)
+		if !ok {
+			return "", InternalServerErrorResponse
+		}
+		return claims.Subject + "@" + claims.TenantId, nil
+	}))
+
+	now := time.Now()
+	claims := func(modify func(c *testClaims)) testClaims {
+		c := testClaims{
+			Claims: Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Subject:   "user-1",
+					Issuer:    "https://issuer.example.com",
+					Audience:  jwt.ClaimStrings{"orders"},
+					ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+					IssuedAt:  jwt.NewNumericDate(now),
+				},
+				Scope: "orders:read orders:write",
+			},
+			TenantId: "t1",
+		}
+		if modify != nil {
+			modify(&c)
+		}
+		return c
+	}
+	sign := func(method jwt.SigningMethod, c testClaims) string {
+		var token string
+		var err error
+		if method == jwt.SigningMethodHS256 {
+			unsigned := jwt.NewWithClaims(method, c)
+			unsigned.Header["kid"] = "hmac"
+			token, err = unsigned.SignedString(secret)
+		} else {
+			token, err = server.Sign(method, c)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		status   int
+		expected string
+	}{
+		{name: "RS256", token: sign(jwt.SigningMethodRS256, claims(nil)), status: http.StatusOK, expected: "user-1@t1"},
+		{name: "ES256", token: sign(jwt.SigningMethodES256, claims(nil)), status: http.StatusOK, expected: "user-1@t1"},
+		{name: "HS256", token: sign(jwt.SigningMethodHS256, claims(nil)), status: http.StatusOK, expected: "user-1@t1"},
+		{name: "missing", token: "", status: http.StatusUnauthorized},
+		{name: "malformed", token: "not.a.token", status: http.StatusUnauthorized},
+		{name: "expired", token: sign(jwt.SigningMethodRS256, claims(func(c *testClaims) {
+			c.ExpiresAt = jwt.NewNumericDate(now.Add(-2 * time.Minute))
+		})), status: http.StatusUnauthorized},
+		{name: "expired within leeway", token: sign(jwt.SigningMethodRS256, claims(func(c *testClaims) {
+			c.ExpiresAt = jwt.NewNumericDate(now.Add(-30 * time.Second))
+		})), status: http.StatusOK, expected: "user-1@t1"},
+		{name: "not yet valid", token: sign(jwt.SigningMethodES256, claims(func(c *testClaims) {
+			c.NotBefore = jwt.NewNumericDate(now.Add(5 * time.Minute))
+		})), status: http.StatusUnauthorized},
+		{name: "no expiration", token: sign(jwt.SigningMethodRS256, claims(func(c *testClaims) {
+			c.ExpiresAt = nil
+		})), status: http.StatusUnauthorized},
+		{name: "wrong issuer", token: sign(jwt.SigningMethodRS256, claims(func(c *testClaims) {
+			c.Issuer = "https://evil.example.com"
+		})), status: http.StatusUnauthorized},
+		{name: "wrong audience", token: sign(jwt.SigningMethodRS256, claims(func(c *testClaims) {
+			c.Audience = jwt.ClaimStrings{"other"}
+		})), status: http.StatusUnauthorized},
+		{name: "insufficient scope", token: sign(jwt.SigningMethodRS256, claims(func(c *testClaims) {
+			c.Scope = "orders:write"
+		})), status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			var body struct {
+				Code string `json:"code"`
+				Data string `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if tt.status == http.StatusOK && body.Data != tt.expected {
+				t.Errorf("data = %q, want %q", body.Data, tt.expected)
+			}
+			if tt.status != http.StatusOK {
+				if body.Code != map[int]string{401: string(LocalCode_Unauthorized), 403: string(LocalCode_Forbidden)}[tt.status] {
+					t.Errorf("code = %q", body.Code)
+				}
+				if w.Header().Get("WWW-Authenticate") == "" {
+					t.Error("missing WWW-Authenticate header")
+				}
+			}
+		})
+	}
+}
+
+func TestJWKSRefreshOnUnknownKey(t *testing.T) {
+	server, err := NewJWKSTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	jwks := server.JWKS()
+	jwks.MinRefreshInterval = time.Hour
+	ctx := context.Background()
+	if _, err := jwks.Key(ctx, "test-rsa"); err != nil {
+		t.Fatal(err)
+	}
+	// an unknown key id does not refresh again within the minimum interval
+	fetched := jwks.fetched
+	if _, err := jwks.Key(ctx, "unknown"); err != ErrJWKNotFound {
+		t.Errorf("Key() error = %v, want ErrJWKNotFound", err)
+	}
+	if jwks.fetched != fetched {
+		t.Error("key set refreshed within the minimum refresh interval")
+	}
+}
+
+func TestJWKSRateLimitsFailedFetches(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	jwks := &JWKS{URL: server.URL, HTTPClient: server.Client(), MinRefreshInterval: time.Hour}
+	// concurrent requests wait for the running fetch, later ones wait for the minimum interval
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := jwks.Key(context.Background(), "test-rsa"); !errors.Is(err, ErrJWKNotFound) || err == ErrJWKNotFound {
+				t.Errorf("Key() error = %v, want ErrJWKNotFound with the fetch error", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if fetches.Load() != 1 {
+		t.Errorf("key server asked %d times, want 1", fetches.Load())
+	}
+}
+
+func TestJWKSFetchOutlivesCanceledRequest(t *testing.T) {
+	keyServer, err := NewJWKSTestServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keyServer.Close()
+
+	var fetches atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		resp, err := keyServer.Client().Get(keyServer.URL)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		io.Copy(w, resp.Body)
+	}))
+	defer server.Close()
+
+	jwks := &JWKS{URL: server.URL, HTTPClient: server.Client(), MinRefreshInterval: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		jwks.Key(ctx, "test-rsa")
+	}()
+	<-started
+	cancel()
+	close(release)
+	<-done
+
+	// the canceled request must not leave a failed fetch behind for the minimum refresh interval
+	if _, err := jwks.Key(context.Background(), "test-rsa"); err != nil {
+		t.Errorf("Key() error = %v", err)
+	}
+	if fetches.Load() != 1 {
+		t.Errorf("key server asked %d times, want 1", fetches.Load())
+	}
+}
@@ -0,0 +1,238 @@
+package cwsutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrPermissionDenied is embedded in the 403 response of requests missing a required permission
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ErrNotResourceOwner is embedded in the 403 response of requests for a resource owned by another subject
+var ErrNotResourceOwner = errors.New("not the resource owner")
+
+// Policy maps roles to the permissions they grant
+// Permissions are strings such as "orders:write", a granted "orders:*" matches every orders permission and "*" matches all
+type Policy struct {
+	lock  sync.RWMutex
+	roles map[string][]string
+}
+
+// policyFile is the format of policy files, e.g. in YAML:
+//
+//	roles:
+//	  admin: ["*"]
+//	  clerk: ["orders:read", "orders:write"]
+type policyFile struct {
+	Roles map[string][]string `json:"roles" yaml:"roles" toml:"roles"`
+}
+
+// NewPolicy returns a policy granting the permissions of each role
+func NewPolicy(rolePermissions map[string][]string) *Policy {
+	p := &Policy{roles: map[string][]string{}}
+	for role, permissions := range rolePermissions {
+		p.roles[role] = append([]string{}, permissions...)
+	}
+	return p
+}
+
+// LoadPolicy decodes a policy in JSON, YAML or TOML format, the format is chosen by the extension of name
+func LoadPolicy(name string, content []byte) (*Policy, error) {
+	var file policyFile
+	var err error
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		err = json.Unmarshal(content, &file)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &file)
+	case ".toml":
+		err = toml.Unmarshal(content, &file)
+	default:
+		return nil, fmt.Errorf("unsupported policy file format: %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", name, err)
+	}
+	return NewPolicy(file.Roles), nil
+}
+
+// LoadPolicyFile reads a policy file in JSON, YAML or TOML format, see LoadPolicy
+func LoadPolicyFile(filename string) (*Policy, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return LoadPolicy(filename, content)
+}
+
+// SetRolePermissions replaces the permissions granted by a role
+func (p *Policy) SetRolePermissions(role string, permissions ...string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.roles == nil {
+		p.roles = map[string][]string{}
+	}
+	p.roles[role] = append([]string{}, permissions...)
+}
+
+// Permissions returns the permissions granted by the roles
+func (p *Policy) Permissions(roles []string) []string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	permissions := []string{}
+	for _, role := range roles {
+		permissions = append(permissions, p.roles[role]...)
+	}
+	return permissions
+}
+
+// HasPermission reports whether one of the roles grants the permission
+func (p *Policy) HasPermission(roles []string, permission string) bool {
+	return grantsPermission(p.Permissions(roles), permission)
+}
+
+// grantsPermission reports whether one of the granted permissions matches the permission, including wildcards
+func grantsPermission(granted []string, permission string) bool {
+	for _, g := range granted {
+		if g == "*" || g == permission {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(g, "*"); ok && strings.HasSuffix(prefix, ":") && strings.HasPrefix(permission, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var authorizationPolicyLock sync.RWMutex
+var authorizationPolicy = NewPolicy(nil)
+
+// SetAuthorizationPolicy sets the policy used by Authorize and Require to resolve the permissions of roles
+func SetAuthorizationPolicy(policy *Policy) {
+	authorizationPolicyLock.Lock()
+	defer authorizationPolicyLock.Unlock()
+	if policy == nil {
+		policy = NewPolicy(nil)
+	}
+	authorizationPolicy = policy
+}
+
+// GetAuthorizationPolicy returns the policy set with SetAuthorizationPolicy, an empty policy by default
+func GetAuthorizationPolicy() *Policy {
+	authorizationPolicyLock.RLock()
+	defer authorizationPolicyLock.RUnlock()
+	return authorizationPolicy
+}
+
+// GrantedPermissions returns the permissions of the claims in ctx: the permissions claim and those of the roles claim
+// Claims expose them with GetPermissions() []string and GetRoles() []string, as Claims does
+func GrantedPermissions(ctx context.Context) []string {
+	claims := contextClaims(ctx)
+	granted := []string{}
+	if c, ok := claims.(interface{ GetPermissions() []string }); ok {
+		granted = append(granted, c.GetPermissions()...)
+	}
+	if c, ok := claims.(interface{ GetRoles() []string }); ok {
+		granted = append(granted, GetAuthorizationPolicy().Permissions(c.GetRoles())...)
+	}
+	return granted
+}
+
+// contextClaims returns the claims stored by JWTMiddleware, nil if the request is not authenticated
+func contextClaims(ctx context.Context) any {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Value(claimsKey{})
+}
+
+// Authorize checks that the authenticated subject of ctx has all permissions
+// Returns UnauthorizedErrorResponse (401) without claims, and PermissionDeniedErrorResponse (403)
+// naming the first missing permission in the localized message and the "permission" member otherwise
+// The errors are responses, so handlers can return them as is, e.g.
+//
+//	if err := cwsutil.Authorize(ctx, "orders:refund"); err != nil {
+//		return err
+//	}
+func Authorize(ctx context.Context, permissions ...string) error {
+	if contextClaims(ctx) == nil {
+		return UnauthorizedErrorResponse.EmbedError(ErrMissingToken)
+	}
+	granted := GrantedPermissions(ctx)
+	for _, permission := range permissions {
+		if !grantsPermission(granted, permission) {
+			return PermissionDeniedErrorResponse.
+				MessageValues(map[string]any{"permission": permission}).
+				EmbedError(fmt.Errorf("%w: %s", ErrPermissionDenied, permission)).
+				Extension("permission", permission)
+		}
+	}
+	return nil
+}
+
+// AuthorizeOwner checks that the authenticated subject of ctx owns the resource, the sub claim must equal ownerId
+// Subjects with all bypassPermissions are allowed as well, e.g. administrators with "orders:admin"
+// Returns UnauthorizedErrorResponse (401) without claims and ForbiddenErrorResponse (403) otherwise,
+// which does not name the bypass permissions
+func AuthorizeOwner(ctx context.Context, ownerId string, bypassPermissions ...string) error {
+	claims := contextClaims(ctx)
+	if claims == nil {
+		return UnauthorizedErrorResponse.EmbedError(ErrMissingToken)
+	}
+	if c, ok := claims.(interface{ GetSubject() (string, error) }); ok {
+		if subject, err := c.GetSubject(); err == nil && subject != "" && subject == ownerId {
+			return nil
+		}
+	}
+	if len(bypassPermissions) > 0 && Authorize(ctx, bypassPermissions...) == nil {
+		return nil
+	}
+	return ForbiddenErrorResponse.EmbedError(ErrNotResourceOwner)
+}
+
+// Require returns a middleware rejecting requests whose subject lacks one of the permissions, see Authorize
+// Attach it after JWTMiddleware, e.g. router.POST("/orders", cwsutil.Require("orders:write"), handler)
+func Require(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := Authorize(requestContext(c), permissions...); err != nil {
+			writeErrorResponse(c, err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireOwner returns a middleware rejecting requests whose subject does not own the resource, see AuthorizeOwner
+// owner returns the owner id of the requested resource, its errors are written like errors returned to WrapHandler
+// Unauthenticated requests are rejected with 401 before owner is called
+// e.g. cwsutil.RequireOwner(func(c *gin.Context) (string, error) { return orders.OwnerOf(c.Param("id")) }, "orders:admin")
+func RequireOwner(owner func(c *gin.Context) (string, error), bypassPermissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if contextClaims(requestContext(c)) == nil {
+			writeErrorResponse(c, UnauthorizedErrorResponse.EmbedError(ErrMissingToken))
+			c.Abort()
+			return
+		}
+		ownerId, err := owner(c)
+		if err == nil {
+			err = AuthorizeOwner(requestContext(c), ownerId, bypassPermissions...)
+		}
+		if err != nil {
+			writeErrorResponse(c, err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,194 @@
+package cwsutil
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"policy.json": `{"roles": {"clerk": ["orders:read", "orders:write"], "admin": ["*"]}}`,
+		"policy.yaml": "roles:\n  clerk: [orders:read, orders:write]\n  admin: ['*']\n",
+		"policy.toml": "[roles]\nclerk = [\"orders:read\", \"orders:write\"]\nadmin = [\"*\"]\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(dir, name)
+			if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			policy, err := LoadPolicyFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !policy.HasPermission([]string{"clerk"}, "orders:write") {
+				t.Error("clerk should have orders:write")
+			}
+			if policy.HasPermission([]string{"clerk"}, "orders:delete") {
+				t.Error("clerk should not have orders:delete")
+			}
+			if !policy.HasPermission([]string{"guest", "admin"}, "billing:refund") {
+				t.Error("admin should have every permission")
+			}
+		})
+	}
+
+	if _, err := LoadPolicy("policy.ini", nil); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestGrantsPermission(t *testing.T) {
+	tests := []struct {
+		granted    []string
+		permission string
+		expected   bool
+	}{
+		{granted: []string{"orders:write"}, permission: "orders:write", expected: true},
+		{granted: []string{"orders:read"}, permission: "orders:write", expected: false},
+		{granted: []string{"orders:*"}, permission: "orders:write", expected: true},
+		{granted: []string{"orders:*"}, permission: "ordersx:write", expected: false},
+		{granted: []string{"orders*"}, permission: "ordersx:write", expected: false},
+		{granted: []string{"*"}, permission: "billing:refund", expected: true},
+		{granted: nil, permission: "orders:read", expected: false},
+	}
+	for _, tt := range tests {
+		if got := grantsPermission(tt.granted, tt.permission); got != tt.expected {
+			t.Errorf("grantsPermission(%v, %q) = %v, want %v", tt.granted, tt.permission, got, tt.expected)
+		}
+	}
+}
+
+func TestRequire(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+	SetAuthorizationPolicy(NewPolicy(map[string][]string{
+		"clerk": {"orders:read", "orders:write"},
+		"admin": {"*"},
+	}))
+	defer SetAuthorizationPolicy(nil)
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	owners := map[string]string{"o1": "user-1", "o2": "user-2"}
+	router := gin.New()
+	router.Use(LocalizationMiddleware())
+	router.Use(JWTMiddleware[Claims](JWTConfig{Keys: map[string]any{"": secret}, Optional: true}))
+	ok := func(c *gin.Context) { OKResponse.WriteResponse(c) }
+	router.POST("/orders", Require("orders:write"), ok)
+	router.DELETE("/orders/:id", Require("orders:delete"), ok)
+	router.GET("/orders/:id", RequireOwner(func(c *gin.Context) (string, error) {
+		owner, found := owners[c.Param("id")]
+		if !found {
+			return "", NotFoundErrorResponse
+		}
+		return owner, nil
+	}, "orders:admin"), ok)
+	router.POST("/orders/:id/refund", WrapHandler(func(c *gin.Context) error {
+		if err := Authorize(requestContext(c), "orders:refund"); err != nil {
+			return err
+		}
+		OKResponse.WriteResponse(c)
+		return nil
+	}))
+
+	token := func(subject string, roles []string, permissions ...string) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   subject,
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+			Roles:       roles,
+			Permissions: permissions,
+		}).SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		language   string
+		status     int
+		code       string
+		permission string
+		message    string
+	}{
+		{name: "role grants permission", method: http.MethodPost, path: "/orders", token: token("user-1", []string{"clerk"}), status: http.StatusOK},
+		{name: "direct permission", method: http.MethodPost, path: "/orders", token: token("user-1", nil, "orders:*"), status: http.StatusOK},
+		{name: "admin wildcard", method: http.MethodDelete, path: "/orders/o1", token: token("user-3", []string{"admin"}), status: http.StatusOK},
+		{name: "missing permission", method: http.MethodDelete, path: "/orders/o1", token: token("user-1", []string{"clerk"}), status: http.StatusForbidden,
+			permission: "orders:delete", message: "Permission orders:delete is required"},
+		{name: "localized message", method: http.MethodDelete, path: "/orders/o1", token: token("user-1", []string{"clerk"}), language: "zh-TW", status: http.StatusForbidden,
+			permission: "orders:delete", message: "需要 orders:delete 權限"},
+		{name: "unauthenticated", method: http.MethodPost, path: "/orders", status: http.StatusUnauthorized},
+		{name: "owner", method: http.MethodGet, path: "/orders/o1", token: token("user-1", nil), status: http.StatusOK},
+		{name: "not owner", method: http.MethodGet, path: "/orders/o2", token: token("user-1", []string{"clerk"}), status: http.StatusForbidden, code: string(LocalCode_Forbidden)},
+		{name: "unauthenticated owner", method: http.MethodGet, path: "/orders/o9", status: http.StatusUnauthorized, code: string(LocalCode_Unauthorized)},
+		{name: "owner bypass", method: http.MethodGet, path: "/orders/o2", token: token("user-1", nil, "orders:admin"), status: http.StatusOK},
+		{name: "owner lookup error", method: http.MethodGet, path: "/orders/o9", token: token("user-1", nil), status: http.StatusNotFound},
+		{name: "inside handler", method: http.MethodPost, path: "/orders/o1/refund", token: token("user-1", []string{"clerk"}), status: http.StatusForbidden,
+			permission: "orders:refund", message: "Permission orders:refund is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.language != "" {
+				req.Header.Set("Accept-Language", tt.language)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			var body struct {
+				Code       string `json:"code"`
+				Message    string `json:"message"`
+				Permission string `json:"permission"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if tt.code != "" && (body.Code != tt.code || body.Permission != "") {
+				t.Errorf("code = %q, permission = %q, want code %q", body.Code, body.Permission, tt.code)
+			}
+			if tt.permission != "" {
+				if body.Code != string(LocalCode_PermissionDenied) {
+					t.Errorf("code = %q", body.Code)
+				}
+				if body.Permission != tt.permission {
+					t.Errorf("permission = %q, want %q", body.Permission, tt.permission)
+				}
+				if body.Message != tt.message {
+					t.Errorf("message = %q, want %q", body.Message, tt.message)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizeWithoutClaims(t *testing.T) {
+	err := AuthorizeOwner(httptest.NewRequest(http.MethodGet, "/", nil).Context(), "user-1")
+	var resp CWSLocalizedErrorResponse
+	if !errors.As(err, &resp) || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("AuthorizeOwner() error = %v, want 401 response", err)
+	}
+}
@@ -1,13 +1,13 @@
 package cwsutil
 
 import (
-	"log"
+	"context"
 	"net/http"
+	"runtime/debug"
+	"strconv"
 
 	"github.com/codeworks-tw/cwsutil/cwsbase"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/mongo"
-	"gorm.io/gorm"
 )
 
 // HTTP status code constants for localization
@@ -24,10 +24,16 @@ const (
 	LocalCode_Forbidden cwsbase.LocalizationCode = "403"
 	// LocalCode_NotFound represents HTTP 404 status code
 	LocalCode_NotFound cwsbase.LocalizationCode = "404"
+	// LocalCode_Conflict represents HTTP 409 status code
+	LocalCode_Conflict cwsbase.LocalizationCode = "409"
+	// LocalCode_GatewayTimeout represents HTTP 504 status code
+	LocalCode_GatewayTimeout cwsbase.LocalizationCode = "504"
+	// LocalCode_PermissionDenied represents HTTP 403 status code for a missing permission, the message names the permission
+	LocalCode_PermissionDenied cwsbase.LocalizationCode = "403.permission"
 )
 
 // localdata contains default localization data for multiple languages (English, Traditional Chinese, Simplified Chinese)
-// This provides standard HTTP error messages in different languages
+// This provides standard HTTP error messages and field validation messages in different languages
 var localdata string = `{
 	"en": {
 		"500": "Internal server error",
@@ -35,7 +41,24 @@ var localdata string = `{
 		"401": "Unauthorized",
 		"200": "OK",
 		"403": "Forbidden",
-		"404": "Resource not found"
+		"403.permission": "Permission {permission} is required",
+		"404": "Resource not found",
+		"409": "Resource conflict",
+		"504": "Request timed out",
+		"validation.invalid": "{field} failed the {rule} rule",
+		"validation.required": "{field} is required",
+		"validation.min": "{kind, select, string {{field} must be at least {param} characters long} collection {{field} must contain at least {param} items} other {{field} must be at least {param}}}",
+		"validation.max": "{kind, select, string {{field} must be at most {param} characters long} collection {{field} must contain at most {param} items} other {{field} must be at most {param}}}",
+		"validation.len": "{kind, select, string {{field} must be exactly {param} characters long} collection {{field} must contain exactly {param} items} other {{field} must be {param}}}",
+		"validation.email": "{field} must be a valid email address",
+		"validation.oneof": "{field} must be one of [{param}]",
+		"validation.gt": "{field} must be greater than {param}",
+		"validation.gte": "{field} must be greater than or equal to {param}",
+		"validation.lt": "{field} must be less than {param}",
+		"validation.lte": "{field} must be less than or equal to {param}",
+		"validation.url": "{field} must be a valid URL",
+		"validation.uuid": "{field} must be a valid UUID",
+		"validation.numeric": "{field} must be numeric"
 	},
 	"zh_tw": {
 		"500": "內部伺服器錯誤",
@@ -43,7 +66,24 @@ var localdata string = `{
 		"401": "未授權",
 		"200": "成功",
 		"403": "禁止訪問",
-		"404": "資源未找到"
+		"403.permission": "需要 {permission} 權限",
+		"404": "資源未找到",
+		"409": "資源衝突",
+		"504": "請求逾時",
+		"validation.invalid": "{field} 未通過 {rule} 規則",
+		"validation.required": "{field} 為必填",
+		"validation.min": "{kind, select, string {{field} 長度至少為 {param} 個字元} collection {{field} 至少需要 {param} 項} other {{field} 不可小於 {param}}}",
+		"validation.max": "{kind, select, string {{field} 長度最多為 {param} 個字元} collection {{field} 最多只能有 {param} 項} other {{field} 不可大於 {param}}}",
+		"validation.len": "{kind, select, string {{field} 長度必須為 {param} 個字元} collection {{field} 必須剛好 {param} 項} other {{field} 必須為 {param}}}",
+		"validation.email": "{field} 必須是有效的電子郵件地址",
+		"validation.oneof": "{field} 必須是 [{param}] 其中之一",
+		"validation.gt": "{field} 必須大於 {param}",
+		"validation.gte": "{field} 必須大於或等於 {param}",
+		"validation.lt": "{field} 必須小於 {param}",
+		"validation.lte": "{field} 必須小於或等於 {param}",
+		"validation.url": "{field} 必須是有效的網址",
+		"validation.uuid": "{field} 必須是有效的 UUID",
+		"validation.numeric": "{field} 必須為數字"
 	},
 	"zh_cn": {
 		"500": "内部服务器错误",
@@ -51,14 +91,33 @@ var localdata string = `{
 		"401": "未授权",
 		"200": "成功",
 		"403": "禁止访问",
-		"404": "资源未找到"
+		"403.permission": "需要 {permission} 权限",
+		"404": "资源未找到",
+		"409": "资源冲突",
+		"504": "请求超时",
+		"validation.invalid": "{field} 未通过 {rule} 规则",
+		"validation.required": "{field} 为必填",
+		"validation.min": "{kind, select, string {{field} 长度至少为 {param} 个字符} collection {{field} 至少需要 {param} 项} other {{field} 不可小于 {param}}}",
+		"validation.max": "{kind, select, string {{field} 长度最多为 {param} 个字符} collection {{field} 最多只能有 {param} 项} other {{field} 不可大于 {param}}}",
+		"validation.len": "{kind, select, string {{field} 长度必须为 {param} 个字符} collection {{field} 必须刚好 {param} 项} other {{field} 必须为 {param}}}",
+		"validation.email": "{field} 必须是有效的电子邮件地址",
+		"validation.oneof": "{field} 必须是 [{param}] 其中之一",
+		"validation.gt": "{field} 必须大于 {param}",
+		"validation.gte": "{field} 必须大于或等于 {param}",
+		"validation.lt": "{field} 必须小于 {param}",
+		"validation.lte": "{field} 必须小于或等于 {param}",
+		"validation.url": "{field} 必须是有效的网址",
+		"validation.uuid": "{field} 必须是有效的 UUID",
+		"validation.numeric": "{field} 必须为数字"
 	}
 }`
 
 // InitBasicLocalizationData initializes the localization system with default multilingual HTTP status messages
 // This function should be called during application startup to load standard error messages
+// It also calls RegisterValidation so validation errors report json tag names
 func InitBasicLocalizationData() {
 	cwsbase.UpdateLocalizationData([]byte(localdata))
+	RegisterValidation()
 }
 
 // CWSLocalizedResponse represents a localized HTTP response structure
@@ -68,27 +127,39 @@ type CWSLocalizedResponse struct {
 	StatusCode int
 	// LocalCode is the localization key for retrieving localized messages
 	LocalCode cwsbase.LocalizationCode
-	// embedValues contains values to be embedded in localized messages (for sprintf formatting)
+	// embedValues contains values to be embedded in localized messages (sprintf verbs or named placeholders)
 	embedValues []any
 	// data contains the response payload data
 	data any
+	// extensions contains additional top-level members of the response body
+	extensions map[string]any
 }
 
-// ToMessage returns the localized message for this response
+// ToMessage returns the localized message for this response in the default language
 func (r CWSLocalizedResponse) ToMessage() string {
 	return cwsbase.GetLocalizationMessage(r.LocalCode, r.embedValues...)
 }
 
-// WriteResponse writes the HTTP response to the gin context in JSON format
+// ToLocalizedMessage returns the localized message for this response in the language carried by ctx
+func (r CWSLocalizedResponse) ToLocalizedMessage(ctx context.Context) string {
+	return cwsbase.GetLocalizationMessageWithContext(ctx, r.LocalCode, r.embedValues...)
+}
+
+// WriteResponse writes the HTTP response to the gin context using the selected ResponseFormatter
+// The message is localized in the language negotiated by LocalizationMiddleware
 func (r CWSLocalizedResponse) WriteResponse(ctx *gin.Context) {
-	ctx.JSON(r.StatusCode, gin.H{
-		"code":    r.LocalCode,
-		"message": r.ToMessage(),
-		"data":    r.data,
+	ctx.Set(responseLocalCodeKey, string(r.LocalCode))
+	GetResponseFormatter(ctx).WriteResponse(ctx, ResponsePayload{
+		StatusCode: r.StatusCode,
+		LocalCode:  r.LocalCode,
+		Message:    r.ToLocalizedMessage(requestContext(ctx)),
+		Data:       r.data,
+		Extensions: r.extensions,
 	})
 }
 
 // MessageValues sets the values to be embedded in the localized message using sprintf formatting
+// A single map[string]any (or gin.H) fills named placeholders such as {name} and plural/select forms instead
 // Returns the same CWSLocalizedResponse instance for method chaining
 func (r CWSLocalizedResponse) MessageValues(values ...any) CWSLocalizedResponse {
 	r.embedValues = values
@@ -102,6 +173,14 @@ func (r CWSLocalizedResponse) ResponseData(data any) CWSLocalizedResponse {
 	return r
 }
 
+// Extension adds a top-level member to the response body, e.g. a pagination cursor
+// Members of the envelope itself (code, message, data) cannot be overridden
+// Returns the same CWSLocalizedResponse instance for method chaining
+func (r CWSLocalizedResponse) Extension(key string, value any) CWSLocalizedResponse {
+	r.extensions = withExtension(r.extensions, key, value)
+	return r
+}
+
 // CWSLocalizedErrorResponse represents a localized HTTP response structure that implements the error interface
 // It contains status code, localization code, embedded values for message formatting, and actual error details
 type CWSLocalizedErrorResponse struct {
@@ -109,10 +188,20 @@ type CWSLocalizedErrorResponse struct {
 	StatusCode int
 	// LocalCode is the localization key for retrieving localized messages
 	LocalCode cwsbase.LocalizationCode
-	// embedValues contains values to be embedded in localized messages (for sprintf formatting)
+	// embedValues contains values to be embedded in localized messages (sprintf verbs or named placeholders)
 	embedValues []any
 	// err stores the underlying error for debugging purposes (only shown in debug mode)
 	err error
+	// details contains machine-readable error details such as field validation errors
+	details any
+	// extensions contains additional top-level members of the response body
+	extensions map[string]any
+}
+
+// isDebugMode reports whether the errors of 5xx responses are exposed, read from DEBUG alone
+// so responses do not require the rest of the environment info
+func isDebugMode() bool {
+	return cwsbase.GetEnv("DEBUG", false)
 }
 
 // Error implements the error interface for CWSLocalizedErrorResponse
@@ -121,44 +210,78 @@ func (r CWSLocalizedErrorResponse) Error() string {
 	return r.ToMessage()
 }
 
-// ToMessage returns the localized message for this error response
+// Unwrap returns the embedded error so errors.Is and errors.As can inspect it
+func (r CWSLocalizedErrorResponse) Unwrap() error {
+	return r.err
+}
+
+// ToMessage returns the localized message for this error response in the default language
 func (r CWSLocalizedErrorResponse) ToMessage() string {
 	return cwsbase.GetLocalizationMessage(r.LocalCode, r.embedValues...)
 }
 
-// WriteResponse writes the HTTP error response to the gin context in JSON format
-// Automatically handles common database errors and debug mode error details
+// ToLocalizedMessage returns the localized message for this error response in the language carried by ctx
+func (r CWSLocalizedErrorResponse) ToLocalizedMessage(ctx context.Context) string {
+	return cwsbase.GetLocalizationMessageWithContext(ctx, r.LocalCode, r.embedValues...)
+}
+
+// WriteResponse writes the HTTP error response to the gin context using the selected ResponseFormatter
+// Generic responses (whose code is the status code, e.g. BadRequestErrorResponse) embedding an error registered
+// with RegisterErrorResponse, such as record not found, are written with the registered status and code instead,
+// see ResolveErrorResponse
+// The embedded error is only shown for client errors (4xx) or in debug mode
+// The embedded error is sent to the error sink configured with cwsbase.SetErrorSink
+// The request id assigned by RequestIdMiddleware is added to the body as request_id
+// The message is localized in the language negotiated by LocalizationMiddleware
 func (r CWSLocalizedErrorResponse) WriteResponse(ctx *gin.Context) {
+	r.writeResponse(ctx, true)
+}
+
+// isGeneric reports whether the response is a plain status response such as NotFoundErrorResponse,
+// i.e. its code is the status code rather than an application code
+func (r CWSLocalizedErrorResponse) isGeneric() bool {
+	return string(r.LocalCode) == strconv.Itoa(r.StatusCode)
+}
+
+// writeResponse writes the error response, report is false when the error was already reported (e.g. a recovered panic)
+func (r CWSLocalizedErrorResponse) writeResponse(ctx *gin.Context, report bool) {
+	var errorValue any
 	if r.err != nil {
-		if r.err == gorm.ErrRecordNotFound || r.err == mongo.ErrNoDocuments {
-			r.StatusCode = http.StatusNotFound
-			r.LocalCode = LocalCode_NotFound
+		if r.isGeneric() {
+			// generic responses take the status and code registered for the embedded error
+			if mapped, ok := ResolveErrorResponse(r.err); ok {
+				r.StatusCode = mapped.StatusCode
+				r.LocalCode = mapped.LocalCode
+				r.embedValues = mapped.embedValues
+			}
+		}
+		if report {
+			var stack []byte
+			if r.StatusCode >= 500 {
+				stack = debug.Stack()
+			}
+			reportError(ctx, r.err, r.StatusCode, stack, false)
 		}
-		log.Println(r.err)
-		if r.StatusCode < 500 {
-			ctx.JSON(r.StatusCode, gin.H{
-				"code":    r.LocalCode,
-				"message": r.ToMessage(),
-				"error":   r.err.Error(),
-			})
-			return
-		} else if r.StatusCode >= 500 && cwsbase.GetEnvironmentInfo().DebugMode {
-			ctx.JSON(r.StatusCode, gin.H{
-				"code":    r.LocalCode,
-				"message": r.ToMessage(),
-				"error":   r.err.Error(),
-			})
-			return
+		if r.StatusCode < 500 || isDebugMode() {
+			errorValue = r.err.Error()
 		}
 	}
-	ctx.JSON(r.StatusCode, gin.H{
-		"code":    r.LocalCode,
-		"message": r.ToMessage(),
-		"error":   nil,
+	if requestId := GetRequestId(ctx); requestId != "" {
+		r = r.Extension("request_id", requestId)
+	}
+	ctx.Set(responseLocalCodeKey, string(r.LocalCode))
+	GetResponseFormatter(ctx).WriteErrorResponse(ctx, ResponsePayload{
+		StatusCode: r.StatusCode,
+		LocalCode:  r.LocalCode,
+		Message:    r.ToLocalizedMessage(requestContext(ctx)),
+		Error:      errorValue,
+		Details:    r.details,
+		Extensions: r.extensions,
 	})
 }
 
 // MessageValues sets the values to be embedded in the localized error message using sprintf formatting
+// A single map[string]any (or gin.H) fills named placeholders such as {name} and plural/select forms instead
 // Returns the same CWSLocalizedErrorResponse instance for method chaining
 func (r CWSLocalizedErrorResponse) MessageValues(values ...any) CWSLocalizedErrorResponse {
 	r.embedValues = values
@@ -173,6 +296,38 @@ func (r CWSLocalizedErrorResponse) EmbedError(err error) CWSLocalizedErrorRespon
 	return r
 }
 
+// ErrorDetails sets machine-readable details included in the "details" field of the response body
+// ParseBody and ParseQuery use it to report field validation errors as a list of FieldError
+// Returns the same CWSLocalizedErrorResponse instance for method chaining
+func (r CWSLocalizedErrorResponse) ErrorDetails(details any) CWSLocalizedErrorResponse {
+	r.details = details
+	return r
+}
+
+// Details returns the machine-readable details set by ErrorDetails
+func (r CWSLocalizedErrorResponse) Details() any {
+	return r.details
+}
+
+// Extension adds a top-level member to the response body, e.g. a retry hint or a problem+json extension member
+// Members of the envelope itself cannot be overridden
+// Returns the same CWSLocalizedErrorResponse instance for method chaining
+func (r CWSLocalizedErrorResponse) Extension(key string, value any) CWSLocalizedErrorResponse {
+	r.extensions = withExtension(r.extensions, key, value)
+	return r
+}
+
+// withExtension returns a copy of extensions with key set to value
+// The map is copied so responses derived from shared pre-defined values do not affect each other
+func withExtension(extensions map[string]any, key string, value any) map[string]any {
+	copied := make(map[string]any, len(extensions)+1)
+	for k, v := range extensions {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
+
 // InternalServerErrorResponse represents a pre-configured 500 Internal Server Error response
 var InternalServerErrorResponse = CWSLocalizedErrorResponse{
 	StatusCode: http.StatusInternalServerError,
@@ -197,12 +352,31 @@ var ForbiddenErrorResponse = CWSLocalizedErrorResponse{
 	LocalCode:  LocalCode_Forbidden,
 }
 
+// PermissionDeniedErrorResponse represents a pre-configured 403 Forbidden error response naming the missing permission
+// Set the permission with MessageValues(map[string]any{"permission": permission}), see Authorize
+var PermissionDeniedErrorResponse = CWSLocalizedErrorResponse{
+	StatusCode: http.StatusForbidden,
+	LocalCode:  LocalCode_PermissionDenied,
+}
+
 // UnauthorizedErrorResponse represents a pre-configured 401 Unauthorized error response
 var UnauthorizedErrorResponse = CWSLocalizedErrorResponse{
 	StatusCode: http.StatusUnauthorized,
 	LocalCode:  LocalCode_Unauthorized,
 }
 
+// ConflictErrorResponse represents a pre-configured 409 Conflict error response
+var ConflictErrorResponse = CWSLocalizedErrorResponse{
+	StatusCode: http.StatusConflict,
+	LocalCode:  LocalCode_Conflict,
+}
+
+// GatewayTimeoutErrorResponse represents a pre-configured 504 Gateway Timeout error response
+var GatewayTimeoutErrorResponse = CWSLocalizedErrorResponse{
+	StatusCode: http.StatusGatewayTimeout,
+	LocalCode:  LocalCode_GatewayTimeout,
+}
+
 // OKResponse represents a pre-configured 200 OK success response
 var OKResponse = CWSLocalizedResponse{
 	StatusCode: http.StatusOK,
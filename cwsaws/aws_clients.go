@@ -25,13 +25,16 @@ var lock sync.Mutex
 var clients map[ClientName]any = map[ClientName]any{}
 
 const (
-	ClientName_STS        ClientName = "STS"
-	ClientName_DynamoDB   ClientName = "DynamoDB"
-	ClientName_SQS        ClientName = "SQS"
-	ClientName_SNS        ClientName = "SNS"
-	ClientName_S3         ClientName = "S3"
-	ClientName_SES        ClientName = "SES"
-	ClientName_CloudWatch ClientName = "CloudWatch"
+	ClientName_STS            ClientName = "STS"
+	ClientName_DynamoDB       ClientName = "DynamoDB"
+	ClientName_SQS            ClientName = "SQS"
+	ClientName_SNS            ClientName = "SNS"
+	ClientName_S3             ClientName = "S3"
+	ClientName_SES            ClientName = "SES"
+	ClientName_CloudWatch     ClientName = "CloudWatch"
+	ClientName_KMS            ClientName = "KMS"
+	ClientName_SecretsManager ClientName = "SecretsManager"
+	ClientName_SSM            ClientName = "SSM"
 )
 
 func GetSingletonClient[T any](name ClientName, ctx context.Context, clientGenFn func(cfg aws.Config) T, optFns ...func(*config.LoadOptions) error) T {
@@ -12,6 +12,7 @@ package cwsaws
 
 import (
 	"context"
+	"encoding/json"
 	"strconv"
 	"time"
 
@@ -109,3 +110,16 @@ func (p *CloudWatchLogsProxy) SendMessage(message string) error {
 	})
 	return err
 }
+
+// ReportError implements cwsbase.ErrorSink by sending the report as a JSON log event
+// e.g. cwsbase.SetErrorSink(cwsbase.MultiErrorSink(cwsbase.SlogErrorSink{}, &proxy))
+func (p *CloudWatchLogsProxy) ReportError(ctx context.Context, report cwsbase.ErrorReport) {
+	message, err := json.Marshal(report)
+	if err != nil {
+		cwsbase.Logger(ctx).Error("error report not sent to cloudwatch", "error", err)
+		return
+	}
+	if err := p.SendMessage(string(message)); err != nil {
+		cwsbase.Logger(ctx).Error("error report not sent to cloudwatch", "error", err)
+	}
+}
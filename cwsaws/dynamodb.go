@@ -12,7 +12,6 @@ package cwsaws
 
 import (
 	"context"
-	"log"
 	"sync"
 	"time"
 
@@ -21,6 +20,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/codeworks-tw/cwsutil/cwsbase"
 )
 
 type DynamoDBTableProxy[O any] struct {
@@ -87,7 +87,7 @@ func (table *DynamoDBTableProxy[O]) ProxyCreateTableAndWaitActive(input *dynamod
 	if err != nil {
 		return out, err
 	}
-	log.Printf("Table %s has been created and activated", table.TableName)
+	cwsbase.Logger(*table.Context).Info("dynamodb table created and activated", "table", table.TableName)
 	return out, err
 }
 
@@ -111,7 +111,7 @@ func (table *DynamoDBTableProxy[O]) ProxyDeleteTableAndWait() (*dynamodb.DeleteT
 	if err != nil {
 		return out, err
 	}
-	log.Printf("Table %s has been deleted", table.TableName)
+	cwsbase.Logger(*table.Context).Info("dynamodb table deleted", "table", table.TableName)
 	return out, err
 }
 
@@ -181,7 +181,7 @@ func (table *DynamoDBTableProxy[O]) ProxyQueryBatchUpdate(input *dynamodb.QueryI
 					var data O
 					err = attributevalue.UnmarshalMap(v, &data)
 					if err != nil {
-						log.Println(err.Error())
+						cwsbase.Logger(*table.Context).Warn("dynamodb item skipped", "table", table.TableName, "error", err)
 					} else if callback != nil {
 						batchRequests = callback(&data, batchRequests)
 					}
@@ -220,7 +220,7 @@ func (table *DynamoDBTableProxy[O]) ProxyScanWithCalback(input *dynamodb.ScanInp
 				var data O
 				err = attributevalue.UnmarshalMap(v, &data)
 				if err != nil {
-					log.Println(err.Error())
+					cwsbase.Logger(*table.Context).Warn("dynamodb item skipped", "table", table.TableName, "error", err)
 				} else if callback != nil {
 					batchRequests = callback(&data, batchRequests)
 				}
@@ -254,7 +254,7 @@ func (table *DynamoDBTableProxy[O]) ProxyQuery(input *dynamodb.QueryInput) ([]*O
 				var data O
 				err = attributevalue.UnmarshalMap(v, &data)
 				if err != nil {
-					log.Println(err.Error())
+					cwsbase.Logger(*table.Context).Warn("dynamodb item skipped", "table", table.TableName, "error", err)
 				} else {
 					items = append(items, &data)
 				}
@@ -278,7 +278,7 @@ func (table *DynamoDBTableProxy[O]) ProxyScan(input *dynamodb.ScanInput) ([]*O,
 			var data O
 			err = attributevalue.UnmarshalMap(v, &data)
 			if err != nil {
-				log.Println(err.Error())
+				cwsbase.Logger(*table.Context).Warn("dynamodb item skipped", "table", table.TableName, "error", err)
 			} else {
 				items = append(items, &data)
 			}
@@ -286,3 +286,62 @@ func (table *DynamoDBTableProxy[O]) ProxyScan(input *dynamodb.ScanInput) ([]*O,
 	}
 	return items, nil
 }
+
+// DynamoDBQueryIterator iterates the items of a query page by page, fetching the next page when the current one is consumed
+// It satisfies cwsutil.StreamIterator[*O], so query results can be streamed with cwsutil.WriteStream
+type DynamoDBQueryIterator[O any] struct {
+	paginator *dynamodb.QueryPaginator
+	items     []map[string]types.AttributeValue
+	index     int
+	current   map[string]types.AttributeValue
+	err       error
+}
+
+// ProxyQueryIterator returns an iterator over the items of a query without loading all pages into memory
+func (table *DynamoDBTableProxy[O]) ProxyQueryIterator(input *dynamodb.QueryInput) *DynamoDBQueryIterator[O] {
+	input.TableName = &table.TableName
+	return &DynamoDBQueryIterator[O]{paginator: dynamodb.NewQueryPaginator(table.Client, input)}
+}
+
+// Next advances to the next item, the next page is queried with ctx when the current page is consumed
+func (it *DynamoDBQueryIterator[O]) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	for it.index >= len(it.items) {
+		if !it.paginator.HasMorePages() {
+			return false
+		}
+		out, err := it.paginator.NextPage(ctx)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.items = out.Items
+		it.index = 0
+	}
+	it.current = it.items[it.index]
+	it.index++
+	return true
+}
+
+// Value unmarshals the current item
+func (it *DynamoDBQueryIterator[O]) Value() (*O, error) {
+	var data O
+	if err := attributevalue.UnmarshalMap(it.current, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// Err returns the error that stopped the iteration
+func (it *DynamoDBQueryIterator[O]) Err() error {
+	return it.err
+}
+
+// Close releases the items of the current page
+func (it *DynamoDBQueryIterator[O]) Close() error {
+	it.items = nil
+	it.current = nil
+	return nil
+}
@@ -0,0 +1,116 @@
+package cwsaws
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+)
+
+// getKMSClient returns the shared KMS client
+func getKMSClient(ctx context.Context, optFns ...func(*config.LoadOptions) error) *kms.Client {
+	return GetSingletonClient(ClientName_KMS, ctx, func(cfg aws.Config) *kms.Client {
+		return kms.NewFromConfig(cfg)
+	}, optFns...)
+}
+
+// getSecretsManagerClient returns the shared Secrets Manager client
+func getSecretsManagerClient(ctx context.Context, optFns ...func(*config.LoadOptions) error) *secretsmanager.Client {
+	return GetSingletonClient(ClientName_SecretsManager, ctx, func(cfg aws.Config) *secretsmanager.Client {
+		return secretsmanager.NewFromConfig(cfg)
+	}, optFns...)
+}
+
+// LoadKeyringFromSecretsManager loads a keyring stored as JSON secret string in the format of cwsbase.KeyringConfig
+// e.g. {"primary": "2024-07", "keys": {"2024-07": "<hex>", "2024-01": "<hex>"}}
+func LoadKeyringFromSecretsManager(ctx context.Context, secretId string, optFns ...func(*config.LoadOptions) error) (*cwsbase.Keyring, error) {
+	if ctx == nil {
+		ctx = context.TODO()
+	}
+	out, err := getSecretsManagerClient(ctx, optFns...).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.SecretString == nil {
+		return nil, fmt.Errorf("secret %s has no secret string", secretId)
+	}
+	keyringConfig, err := cwsbase.ParseKeyringConfig(secretId+".json", []byte(*out.SecretString))
+	if err != nil {
+		return nil, err
+	}
+	return keyringConfig.Keyring()
+}
+
+// LoadKeyringFromKMS loads a keyring of data keys encrypted with KMS (envelope encryption)
+// The keys of keyringConfig are the base64 ciphertext blobs of NewKMSDataKey instead of hex keys,
+// they are decrypted with KMS once, so the config can be kept in files or environment variables
+// The index key of keyringConfig, if any, is a ciphertext blob as well
+func LoadKeyringFromKMS(ctx context.Context, keyringConfig cwsbase.KeyringConfig, optFns ...func(*config.LoadOptions) error) (*cwsbase.Keyring, error) {
+	if ctx == nil {
+		ctx = context.TODO()
+	}
+	client := getKMSClient(ctx, optFns...)
+	keys := map[string][]byte{}
+	for id, encrypted := range keyringConfig.Keys {
+		key, err := decryptKMSDataKey(ctx, client, encrypted)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		keys[id] = key
+	}
+	keyring, err := cwsbase.NewKeyring(keyringConfig.Primary, keys)
+	if err != nil {
+		return nil, err
+	}
+	if keyringConfig.IndexKey != "" {
+		indexKey, err := decryptKMSDataKey(ctx, client, keyringConfig.IndexKey)
+		if err != nil {
+			return nil, fmt.Errorf("index key: %w", err)
+		}
+		if err := keyring.SetIndexKey(indexKey); err != nil {
+			return nil, err
+		}
+	}
+	return keyring, nil
+}
+
+// decryptKMSDataKey decrypts the base64 ciphertext blob of a data key
+func decryptKMSDataKey(ctx context.Context, client *kms.Client, encrypted string) ([]byte, error) {
+	blob, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	out, err := client.Decrypt(ctx, &kms.DecryptInput{CiphertextBlob: blob})
+	if err != nil {
+		return nil, fmt.Errorf("decrypting: %w", err)
+	}
+	return out.Plaintext, nil
+}
+
+// NewKMSDataKey generates an AES-256 data key under the KMS key kmsKeyId for rotating a keyring
+// Add key to the keyring with Keyring.Rotate and store encrypted, the base64 ciphertext blob, in the config of LoadKeyringFromKMS
+func NewKMSDataKey(ctx context.Context, kmsKeyId string, optFns ...func(*config.LoadOptions) error) (key []byte, encrypted string, err error) {
+	if ctx == nil {
+		ctx = context.TODO()
+	}
+	out, err := getKMSClient(ctx, optFns...).GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(kmsKeyId),
+		KeySpec: kmstypes.DataKeySpecAes256,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(out.Plaintext) == 0 {
+		return nil, "", errors.New("KMS returned an empty data key")
+	}
+	return out.Plaintext, base64.StdEncoding.EncodeToString(out.CiphertextBlob), nil
+}
@@ -12,7 +12,10 @@ package cwsaws
 
 import (
 	"context"
+	"encoding/base64"
+	"log"
 	"reflect"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -27,6 +30,7 @@ import (
 type IRepository[PKey any] interface {
 	Get(ctx context.Context, pKey PKey, columns ...string) (*map[string]any, error)
 	Query(ctx context.Context, indexName string, expr expression.Expression) ([]*map[string]any, error)
+	QueryPage(ctx context.Context, indexName string, expr expression.Expression, page cwsbase.PageRequest) (cwsbase.Page[*map[string]any], error)
 	Merge(ctx context.Context, pKey PKey, expr expression.Expression) (*map[string]any, error)
 	Delete(ctx context.Context, pKey PKey) (*map[string]any, error)
 	GetDynamoDBTableProxy(ctx context.Context) *DynamoDBTableProxy[map[string]any]
@@ -49,16 +53,41 @@ func (r *Repository[PKey]) GetPKeyKeys() []string {
 	return keys
 }
 
+// localDynamoDBConfig is the DynamoDB Local endpoint used when the environment is local
+type localDynamoDBConfig struct {
+	AWSID     string `env:"Local_DynamoDB_AWS_ID" required:"true"`
+	AWSSecret string `env:"Local_DynamoDB_AWS_Secret" required:"true"`
+	URL       string `env:"Local_DynamoDB_URL" required:"true"`
+	Region    string `env:"Local_DynamoDB_REGION" required:"true"`
+}
+
+// loadLocalDynamoDBConfig reads the environment once, it returns nil outside the local environment
+var loadLocalDynamoDBConfig = sync.OnceValues(func() (*localDynamoDBConfig, error) {
+	info, err := cwsbase.LoadEnvironmentInfo()
+	if err != nil || !info.IsLocal {
+		return nil, err
+	}
+	var local localDynamoDBConfig
+	if err := cwsbase.LoadConfig(&local); err != nil {
+		return nil, err
+	}
+	return &local, nil
+})
+
+// GetDynamoDBTableProxy returns the proxy of the table, of DynamoDB Local when IS_LOCAL is set
+// The program exits with every missing or invalid value of the environment if it cannot be loaded
 func (r *Repository[PKey]) GetDynamoDBTableProxy(ctx context.Context) DynamoDBTableProxy[map[string]any] {
-	if cwsbase.GetEnvironmentInfo().IsLocal {
-		credential := config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			cwsbase.GetEnv[string]("Local_DynamoDB_AWS_ID"),
-			cwsbase.GetEnv[string]("Local_DynamoDB_AWS_Secret"), ""))
+	local, err := loadLocalDynamoDBConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if local != nil {
+		credential := config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(local.AWSID, local.AWSSecret, ""))
 		endPoint := config.WithEndpointResolverWithOptions(
 			aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
 				return aws.Endpoint{
-					URL:           cwsbase.GetEnv[string]("Local_DynamoDB_URL"),
-					SigningRegion: cwsbase.GetEnv[string]("Local_DynamoDB_REGION"),
+					URL:           local.URL,
+					SigningRegion: local.Region,
 				}, nil
 			}))
 
@@ -172,3 +201,128 @@ func StructToAttributeValueMap(s any, modify ...func(key string, val any) any) (
 	}
 	return result, nil
 }
+
+// QueryPage queries a page of items, DynamoDB continues from the LastEvaluatedKey stored in the cursor
+// Offset mode skips the items of the previous pages, so clients should prefer the next cursor
+// HasMore is reported by DynamoDB and may be true when the next page turns out to be empty
+func (r *Repository[PKey]) QueryPage(ctx context.Context, indexName string, expr expression.Expression, page cwsbase.PageRequest) (cwsbase.Page[*map[string]any], error) {
+	tableProxy := r.GetDynamoDBTableProxy(ctx)
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(r.TableName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+	}
+	if indexName != "" {
+		input.IndexName = aws.String(indexName)
+	}
+	if page.IsCursor() {
+		startKey, err := cursorToAttributeKey(page.Cursor)
+		if err != nil {
+			return cwsbase.Page[*map[string]any]{}, err
+		}
+		input.ExclusiveStartKey = startKey
+	}
+
+	var total *int64
+	if page.WithTotal {
+		countInput := *input
+		countInput.Select = types.SelectCount
+		countInput.ExclusiveStartKey = nil
+		count := int64(0)
+		p := dynamodb.NewQueryPaginator(tableProxy.Client, &countInput)
+		for p.HasMorePages() {
+			out, err := p.NextPage(ctx)
+			if err != nil {
+				return cwsbase.Page[*map[string]any]{}, err
+			}
+			count += int64(out.Count)
+		}
+		total = &count
+	}
+
+	// each call is limited to the remaining items, so the LastEvaluatedKey is the key of the last item taken
+	skip := 0
+	if !page.IsCursor() {
+		skip = page.Offset()
+	}
+	items := []*map[string]any{}
+	for {
+		remaining := page.Limit() - len(items)
+		if skip > 0 {
+			remaining = skip
+		}
+		input.Limit = aws.Int32(int32(remaining))
+		out, err := tableProxy.Query(ctx, input)
+		if err != nil {
+			return cwsbase.Page[*map[string]any]{}, err
+		}
+		if skip > 0 {
+			skip -= len(out.Items)
+		} else {
+			for _, v := range out.Items {
+				item := map[string]any{}
+				if err := attributevalue.UnmarshalMap(v, &item); err != nil {
+					return cwsbase.Page[*map[string]any]{}, err
+				}
+				items = append(items, &item)
+			}
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+		if out.LastEvaluatedKey == nil || (skip <= 0 && len(items) >= page.Limit()) {
+			break
+		}
+	}
+
+	result := cwsbase.Page[*map[string]any]{Items: items, Total: total, HasMore: input.ExclusiveStartKey != nil}
+	if result.HasMore {
+		cursor, err := cwsbase.EncodeCursor(attributeKeyToCursor(input.ExclusiveStartKey))
+		if err != nil {
+			return result, err
+		}
+		result.NextCursor = cursor
+	}
+	return result, nil
+}
+
+// attributeKeyToCursor converts a LastEvaluatedKey into a cursor position keeping the S, N and B attribute types
+func attributeKeyToCursor(key map[string]types.AttributeValue) map[string]any {
+	position := map[string]any{}
+	for name, value := range key {
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			position[name] = map[string]any{"S": v.Value}
+		case *types.AttributeValueMemberN:
+			position[name] = map[string]any{"N": v.Value}
+		case *types.AttributeValueMemberB:
+			position[name] = map[string]any{"B": base64.StdEncoding.EncodeToString(v.Value)}
+		}
+	}
+	return position
+}
+
+// cursorToAttributeKey converts a cursor position created by attributeKeyToCursor back into an ExclusiveStartKey
+func cursorToAttributeKey(position map[string]any) (map[string]types.AttributeValue, error) {
+	key := map[string]types.AttributeValue{}
+	for name, value := range position {
+		typed, ok := value.(map[string]any)
+		if !ok {
+			return nil, cwsbase.ErrInvalidCursor
+		}
+		if s, ok := typed["S"].(string); ok {
+			key[name] = &types.AttributeValueMemberS{Value: s}
+		} else if n, ok := typed["N"].(string); ok {
+			key[name] = &types.AttributeValueMemberN{Value: n}
+		} else if b, ok := typed["B"].(string); ok {
+			decoded, err := base64.StdEncoding.DecodeString(b)
+			if err != nil {
+				return nil, cwsbase.ErrInvalidCursor
+			}
+			key[name] = &types.AttributeValueMemberB{Value: decoded}
+		} else {
+			return nil, cwsbase.ErrInvalidCursor
+		}
+	}
+	return key, nil
+}
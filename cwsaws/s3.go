@@ -15,7 +15,6 @@ import (
 	"context"
 	"errors"
 	"io"
-	"log"
 	"sync"
 	"time"
 
@@ -159,7 +158,7 @@ func (p *S3Proxy) ProxyGetObject(subPath string, parsingFunc func(content []byte
 		return pb, nil
 	}
 
-	log.Println(err)
+	cwsbase.Logger(p.Context).Error("s3 object not loaded", "key", key, "error", err)
 	if v, ok := s3Objects[key]; ok {
 		return v.Content, nil
 	}
@@ -0,0 +1,237 @@
+package cwsaws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+)
+
+// DefaultSecretTTL is how long resolved secrets are cached before they are fetched again
+const DefaultSecretTTL = 5 * time.Minute
+
+// SecretOption configures the secret resolvers
+type SecretOption func(*secretOptions)
+
+type secretOptions struct {
+	ttl         time.Duration
+	endpoint    string
+	loadOptions []func(*config.LoadOptions) error
+}
+
+// WithSecretTTL sets how long resolved secrets are cached, 0 fetches them on every resolve
+func WithSecretTTL(ttl time.Duration) SecretOption {
+	return func(o *secretOptions) {
+		o.ttl = ttl
+	}
+}
+
+// WithSecretEndpoint sends the requests to endpoint instead of AWS, e.g. a LocalStack at "http://localhost:4566"
+func WithSecretEndpoint(endpoint string) SecretOption {
+	return func(o *secretOptions) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithSecretLoadOptions sets the options of loading the AWS config of the clients, e.g. credentials or region
+// The resolvers then use their own clients instead of the shared ones
+func WithSecretLoadOptions(optFns ...func(*config.LoadOptions) error) SecretOption {
+	return func(o *secretOptions) {
+		o.loadOptions = append(o.loadOptions, optFns...)
+	}
+}
+
+func newSecretOptions(opts []SecretOption) secretOptions {
+	o := secretOptions{ttl: DefaultSecretTTL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+type secretEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// secretCache caches fetched secrets by reference until their TTL expires
+type secretCache struct {
+	lock    sync.Mutex
+	ttl     time.Duration
+	entries map[string]secretEntry
+	fetch   func(ctx context.Context, ref string) (string, error)
+}
+
+// get returns the cached value of ref or fetches it once expired
+// If fetching an expired secret fails the stale value is returned, so a short outage does not break running code
+func (c *secretCache) get(ctx context.Context, ref string) (string, error) {
+	c.lock.Lock()
+	entry, ok := c.entries[ref]
+	c.lock.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.value, nil
+	}
+
+	value, err := c.fetch(ctx, ref)
+	if err != nil {
+		if ok {
+			cwsbase.Logger(ctx).Warn("secret not refreshed, using the cached value", "ref", ref, "error", err)
+			return entry.value, nil
+		}
+		return "", err
+	}
+	if c.ttl > 0 {
+		c.lock.Lock()
+		c.entries[ref] = secretEntry{value: value, expiresAt: time.Now().Add(c.ttl)}
+		c.lock.Unlock()
+	}
+	return value, nil
+}
+
+// Invalidate drops cached secrets, all of them without refs, so they are fetched on the next resolve
+func (c *secretCache) Invalidate(refs ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(refs) == 0 {
+		c.entries = map[string]secretEntry{}
+		return
+	}
+	for _, ref := range refs {
+		delete(c.entries, ref)
+	}
+}
+
+// newSecretClient returns the shared client of name, or a dedicated client when load options are given
+// since the shared client keeps the options, e.g. the region, it was created with
+func newSecretClient[T any](ctx context.Context, name ClientName, clientGenFn func(cfg aws.Config) T, optFns []func(*config.LoadOptions) error) T {
+	if len(optFns) == 0 {
+		return GetSingletonClient(name, ctx, clientGenFn)
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	return clientGenFn(cfg)
+}
+
+// SSMResolver resolves SSM Parameter Store parameters by name, decrypting SecureString parameters
+type SSMResolver struct {
+	secretCache
+	client   *ssm.Client
+	endpoint string
+}
+
+// NewSSMResolver returns a resolver of parameter names, e.g. "/prod/db/url"
+func NewSSMResolver(ctx context.Context, opts ...SecretOption) *SSMResolver {
+	if ctx == nil {
+		ctx = context.TODO()
+	}
+	o := newSecretOptions(opts)
+	client := newSecretClient(ctx, ClientName_SSM, func(cfg aws.Config) *ssm.Client {
+		return ssm.NewFromConfig(cfg)
+	}, o.loadOptions)
+	r := &SSMResolver{client: client, endpoint: o.endpoint}
+	r.secretCache = secretCache{ttl: o.ttl, entries: map[string]secretEntry{}, fetch: r.fetch}
+	return r
+}
+
+// ResolveSecret returns the value of the parameter named ref
+func (r *SSMResolver) ResolveSecret(ctx context.Context, ref string) (string, error) {
+	return r.get(ctx, ref)
+}
+
+func (r *SSMResolver) fetch(ctx context.Context, name string) (string, error) {
+	out, err := r.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	}, func(o *ssm.Options) {
+		if r.endpoint != "" {
+			o.BaseEndpoint = aws.String(r.endpoint)
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get parameter %s: %w", name, err)
+	}
+	if out.Parameter == nil || out.Parameter.Value == nil {
+		return "", fmt.Errorf("parameter %s has no value", name)
+	}
+	return *out.Parameter.Value, nil
+}
+
+// SecretsManagerResolver resolves Secrets Manager secrets by id, or a field of JSON secrets with "<id>#<field>"
+type SecretsManagerResolver struct {
+	secretCache
+	client   *secretsmanager.Client
+	endpoint string
+}
+
+// NewSecretsManagerResolver returns a resolver of secret ids, e.g. "db" or "db#password"
+func NewSecretsManagerResolver(ctx context.Context, opts ...SecretOption) *SecretsManagerResolver {
+	if ctx == nil {
+		ctx = context.TODO()
+	}
+	o := newSecretOptions(opts)
+	client := newSecretClient(ctx, ClientName_SecretsManager, func(cfg aws.Config) *secretsmanager.Client {
+		return secretsmanager.NewFromConfig(cfg)
+	}, o.loadOptions)
+	r := &SecretsManagerResolver{client: client, endpoint: o.endpoint}
+	r.secretCache = secretCache{ttl: o.ttl, entries: map[string]secretEntry{}, fetch: r.fetch}
+	return r
+}
+
+// ResolveSecret returns the secret string of the secret ref, or a field of it for "<id>#<field>"
+// The secret is cached by id, so several fields of one secret are fetched once
+func (r *SecretsManagerResolver) ResolveSecret(ctx context.Context, ref string) (string, error) {
+	secretId, field, hasField := strings.Cut(ref, "#")
+	value, err := r.get(ctx, secretId)
+	if err != nil || !hasField {
+		return value, err
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", secretId, err)
+	}
+	v, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no field %s", secretId, field)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func (r *SecretsManagerResolver) fetch(ctx context.Context, secretId string) (string, error) {
+	out, err := r.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretId),
+	}, func(o *secretsmanager.Options) {
+		if r.endpoint != "" {
+			o.BaseEndpoint = aws.String(r.endpoint)
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", secretId, err)
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("secret %s has no secret string", secretId)
+	}
+	return *out.SecretString, nil
+}
+
+// RegisterSecretResolvers registers the "ssm" and "secretsmanager" schemes in cwsbase, so config values like
+// "ssm:/prod/db/url" or "secretsmanager:db#password" are resolved by cwsbase.LoadConfig and cwsbase.ResolveEnvSecrets
+func RegisterSecretResolvers(ctx context.Context, opts ...SecretOption) {
+	cwsbase.RegisterSecretResolver("ssm", NewSSMResolver(ctx, opts...))
+	cwsbase.RegisterSecretResolver("secretsmanager", NewSecretsManagerResolver(ctx, opts...))
+}
@@ -0,0 +1,108 @@
+package cwsaws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+)
+
+// newSecretsStandIn serves GetParameter and GetSecretValue like a local stand-in of SSM and Secrets Manager
+func newSecretsStandIn(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var input map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSSM.GetParameter":
+			json.NewEncoder(w).Encode(map[string]any{
+				"Parameter": map[string]any{"Name": input["Name"], "Value": "postgres://db.local/app", "Type": "SecureString"},
+			})
+		case "secretsmanager.GetSecretValue":
+			json.NewEncoder(w).Encode(map[string]any{
+				"Name":         input["SecretId"],
+				"SecretString": `{"username": "app", "password": "s3cr3t", "port": 5432}`,
+			})
+		default:
+			http.Error(w, "unknown target", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSecretResolvers(t *testing.T) {
+	var requests atomic.Int32
+	server := newSecretsStandIn(t, &requests)
+	opts := []SecretOption{
+		WithSecretEndpoint(server.URL),
+		WithSecretTTL(time.Hour),
+		WithSecretLoadOptions(
+			config.WithRegion("localhost"),
+			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("id", "secret", "")),
+		),
+	}
+	RegisterSecretResolvers(context.TODO(), opts...)
+	defer cwsbase.RegisterSecretResolver("ssm", nil)
+	defer cwsbase.RegisterSecretResolver("secretsmanager", nil)
+
+	var cfg struct {
+		DatabaseURL string `env:"DB_URL"`
+		Password    string `env:"DB_PASSWORD"`
+		Port        int    `env:"DB_PORT"`
+	}
+	err := cwsbase.LoadConfig(&cfg, cwsbase.WithConfigLookup(func(key string) (string, bool) {
+		v, ok := map[string]string{
+			"DB_URL":      "ssm:/prod/db/url",
+			"DB_PASSWORD": "secretsmanager:db#password",
+			"DB_PORT":     "secretsmanager:db#port",
+		}[key]
+		return v, ok
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DatabaseURL != "postgres://db.local/app" || cfg.Password != "s3cr3t" || cfg.Port != 5432 {
+		t.Errorf("config = %+v", cfg)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("requests = %d, want 2 with the secret cached", n)
+	}
+
+	os.Setenv("SECRETS_TEST_USERNAME", "secretsmanager:db#username")
+	defer os.Unsetenv("SECRETS_TEST_USERNAME")
+	if err := cwsbase.ResolveEnvSecrets(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if v := os.Getenv("SECRETS_TEST_USERNAME"); v != "app" {
+		t.Errorf("SECRETS_TEST_USERNAME = %q", v)
+	}
+
+	resolver := NewSSMResolver(context.TODO(), append(opts, WithSecretTTL(0))...)
+	for i := 0; i < 2; i++ {
+		if _, err := resolver.ResolveSecret(context.TODO(), "/prod/db/url"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := requests.Load(); n != 4 {
+		t.Errorf("requests = %d, want 4 without caching", n)
+	}
+
+	// resolvers with load options do not share a client created with other options
+	other := NewSSMResolver(context.TODO(), WithSecretLoadOptions(config.WithRegion("ap-northeast-1")))
+	if region := other.client.Options().Region; region != "ap-northeast-1" {
+		t.Errorf("region = %q, want the region of the load options", region)
+	}
+}
@@ -0,0 +1,161 @@
+package cwsaws
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/codeworks-tw/cwsutil/cwswebhook"
+)
+
+// DefaultWebhookDueIndex is the GSI of the deliveries table keyed by Status and NextAttemptAt
+const DefaultWebhookDueIndex = "StatusNextAttemptAtIndex"
+
+// WebhookStore is a cwswebhook.Store in two DynamoDB tables
+// Deliveries are keyed by Id with a GSI of Status and NextAttemptAt (unix seconds), attempts by DeliveryId and Number
+type WebhookStore struct {
+	DeliveryTable string
+	AttemptTable  string
+	// DueIndex is the GSI queried by DueDeliveries, DefaultWebhookDueIndex if empty
+	DueIndex string
+}
+
+func (s *WebhookStore) dueIndex() string {
+	if s.DueIndex == "" {
+		return DefaultWebhookDueIndex
+	}
+	return s.DueIndex
+}
+
+// CreateTables creates both tables on demand and waits for them to be active
+func (s *WebhookStore) CreateTables(ctx context.Context) error {
+	deliveries := GetDynamoDBTableProxy[cwswebhook.Delivery](s.DeliveryTable, ctx)
+	if !deliveries.ProxyTableIsActive() {
+		_, err := deliveries.ProxyCreateTableAndWaitActive(&dynamodb.CreateTableInput{
+			BillingMode: types.BillingModePayPerRequest,
+			AttributeDefinitions: []types.AttributeDefinition{
+				{AttributeName: aws.String("Id"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("Status"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("NextAttemptAt"), AttributeType: types.ScalarAttributeTypeN},
+			},
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("Id"), KeyType: types.KeyTypeHash},
+			},
+			GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+				IndexName: aws.String(s.dueIndex()),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String("Status"), KeyType: types.KeyTypeHash},
+					{AttributeName: aws.String("NextAttemptAt"), KeyType: types.KeyTypeRange},
+				},
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	attempts := GetDynamoDBTableProxy[cwswebhook.Attempt](s.AttemptTable, ctx)
+	if !attempts.ProxyTableIsActive() {
+		_, err := attempts.ProxyCreateTableAndWaitActive(&dynamodb.CreateTableInput{
+			BillingMode: types.BillingModePayPerRequest,
+			AttributeDefinitions: []types.AttributeDefinition{
+				{AttributeName: aws.String("DeliveryId"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("Number"), AttributeType: types.ScalarAttributeTypeN},
+			},
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("DeliveryId"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("Number"), KeyType: types.KeyTypeRange},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveDelivery puts the delivery
+func (s *WebhookStore) SaveDelivery(ctx context.Context, delivery *cwswebhook.Delivery) error {
+	item, err := attributevalue.MarshalMap(delivery)
+	if err != nil {
+		return err
+	}
+	table := GetDynamoDBTableProxy[cwswebhook.Delivery](s.DeliveryTable, ctx)
+	_, err = table.ProxyPutItem(&dynamodb.PutItemInput{Item: item})
+	return err
+}
+
+// GetDelivery returns the delivery with id
+func (s *WebhookStore) GetDelivery(ctx context.Context, id string) (*cwswebhook.Delivery, error) {
+	table := GetDynamoDBTableProxy[cwswebhook.Delivery](s.DeliveryTable, ctx)
+	delivery, err := table.ProxyGetItem(&dynamodb.GetItemInput{
+		Key:            map[string]types.AttributeValue{"Id": &types.AttributeValueMemberS{Value: id}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil {
+		return nil, cwswebhook.ErrDeliveryNotFound
+	}
+	return delivery, nil
+}
+
+// DueDeliveries queries one page of the due index, the index is eventually consistent so a delivery saved
+// just before may be missed until the next call
+func (s *WebhookStore) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]*cwswebhook.Delivery, error) {
+	keyCond := expression.Key("Status").Equal(expression.Value(string(cwswebhook.DeliveryPending))).
+		And(expression.Key("NextAttemptAt").LessThanEqual(expression.Value(now.Unix())))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, err
+	}
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(s.DeliveryTable),
+		IndexName:                 aws.String(s.dueIndex()),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(true),
+	}
+	if limit > 0 {
+		input.Limit = aws.Int32(int32(limit))
+	}
+	table := GetDynamoDBTableProxy[cwswebhook.Delivery](s.DeliveryTable, ctx)
+	out, err := table.Query(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []*cwswebhook.Delivery{}
+	return deliveries, attributevalue.UnmarshalListOfMaps(out.Items, &deliveries)
+}
+
+// AddAttempt puts the attempt
+func (s *WebhookStore) AddAttempt(ctx context.Context, attempt *cwswebhook.Attempt) error {
+	item, err := attributevalue.MarshalMap(attempt)
+	if err != nil {
+		return err
+	}
+	table := GetDynamoDBTableProxy[cwswebhook.Attempt](s.AttemptTable, ctx)
+	_, err = table.ProxyPutItem(&dynamodb.PutItemInput{Item: item})
+	return err
+}
+
+// Attempts queries the attempts of a delivery
+func (s *WebhookStore) Attempts(ctx context.Context, deliveryId string) ([]*cwswebhook.Attempt, error) {
+	expr, err := expression.NewBuilder().WithKeyCondition(expression.Key("DeliveryId").Equal(expression.Value(deliveryId))).Build()
+	if err != nil {
+		return nil, err
+	}
+	table := GetDynamoDBTableProxy[cwswebhook.Attempt](s.AttemptTable, ctx)
+	return table.ProxyQuery(&dynamodb.QueryInput{
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConsistentRead:            aws.Bool(true),
+	})
+}
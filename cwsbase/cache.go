@@ -0,0 +1,276 @@
+package cwsbase
+
+import (
+	"container/list"
+	"iter"
+	"sync"
+	"time"
+)
+
+// EvictionReason tells why an entry left a Cache
+type EvictionReason int
+
+const (
+	// EvictionExpired entries outlived their time to live
+	EvictionExpired EvictionReason = iota + 1
+	// EvictionCapacity entries were the least recently used when the cache exceeded MaxEntries or MaxCost
+	EvictionCapacity
+	// EvictionReplaced entries were replaced by Set with a new value of the key
+	EvictionReplaced
+	// EvictionDeleted entries were removed by Delete or Clear
+	EvictionDeleted
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictionExpired:
+		return "expired"
+	case EvictionCapacity:
+		return "capacity"
+	case EvictionReplaced:
+		return "replaced"
+	case EvictionDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// CacheConfig configures NewCache
+type CacheConfig[K comparable, V any] struct {
+	// MaxEntries limits the number of entries, unlimited if 0
+	MaxEntries int
+	// MaxCost limits the total cost of the entries, unlimited if 0
+	MaxCost int64
+	// Cost returns the cost of an entry counted against MaxCost, e.g. its size in bytes, 1 if nil
+	Cost func(key K, value V) int64
+	// TTL is the time to live of the entries added by Set, entries do not expire if 0
+	TTL time.Duration
+	// OnEvict is called after an entry left the cache, outside of the lock so it can use the cache
+	OnEvict func(key K, value V, reason EvictionReason)
+}
+
+// cacheEntry is an entry of the list of a Cache
+type cacheEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	cost      int64
+	expiresAt time.Time
+}
+
+// eviction is an entry to pass to OnEvict once the lock is released
+type eviction[K comparable, V any] struct {
+	entry  *cacheEntry[K, V]
+	reason EvictionReason
+}
+
+// Cache is a generic LRU cache with time to live and size limits, safe for concurrent use
+// Expired entries are removed when they are read or evicted, or by DeleteExpired
+// e.g. cwsbase.NewCache(cwsbase.CacheConfig[string, *User]{MaxEntries: 10000, TTL: 5 * time.Minute})
+type Cache[K comparable, V any] struct {
+	lock    sync.Mutex
+	config  CacheConfig[K, V]
+	entries map[K]*list.Element
+	// recent lists the entries from the most to the least recently used
+	recent *list.List
+	cost   int64
+	now    func() time.Time
+}
+
+// NewCache returns an empty cache
+func NewCache[K comparable, V any](config CacheConfig[K, V]) *Cache[K, V] {
+	return &Cache[K, V]{
+		config:  config,
+		entries: map[K]*list.Element{},
+		recent:  list.New(),
+		now:     time.Now,
+	}
+}
+
+// notify calls OnEvict with the evicted entries
+func (c *Cache[K, V]) notify(evictions []eviction[K, V]) {
+	if c.config.OnEvict == nil {
+		return
+	}
+	for _, e := range evictions {
+		c.config.OnEvict(e.entry.key, e.entry.value, e.reason)
+	}
+}
+
+// remove removes the entry of element from the cache
+func (c *Cache[K, V]) remove(element *list.Element, reason EvictionReason, evictions []eviction[K, V]) []eviction[K, V] {
+	entry := c.recent.Remove(element).(*cacheEntry[K, V])
+	delete(c.entries, entry.key)
+	c.cost -= entry.cost
+	return append(evictions, eviction[K, V]{entry, reason})
+}
+
+func (c *Cache[K, V]) expired(entry *cacheEntry[K, V], now time.Time) bool {
+	return !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt)
+}
+
+// Get returns the value of key and marks it as the most recently used, false if it is missing or expired
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	return c.get(key, true)
+}
+
+// Peek returns the value of key without changing its recency, false if it is missing or expired
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	return c.get(key, false)
+}
+
+func (c *Cache[K, V]) get(key K, touch bool) (V, bool) {
+	var evictions []eviction[K, V]
+	defer func() { c.notify(evictions) }()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var zero V
+	element, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := element.Value.(*cacheEntry[K, V])
+	if c.expired(entry, c.now()) {
+		evictions = c.remove(element, EvictionExpired, evictions)
+		return zero, false
+	}
+	if touch {
+		c.recent.MoveToFront(element)
+	}
+	return entry.value, true
+}
+
+// Set adds or replaces the value of key with the TTL of the config
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.config.TTL)
+}
+
+// SetWithTTL adds or replaces the value of key expiring after ttl, it does not expire if ttl is 0
+// The least recently used entries are evicted while the cache exceeds MaxEntries or MaxCost,
+// an entry costing more than MaxCost is evicted right away without evicting others
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	var evictions []eviction[K, V]
+	defer func() { c.notify(evictions) }()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.now()
+	entry := &cacheEntry[K, V]{key: key, value: value, cost: 1}
+	if c.config.Cost != nil {
+		entry.cost = c.config.Cost(key, value)
+	}
+	if ttl > 0 {
+		entry.expiresAt = now.Add(ttl)
+	}
+	if element, ok := c.entries[key]; ok {
+		evictions = c.remove(element, EvictionReplaced, evictions)
+	}
+	if c.config.MaxCost > 0 && entry.cost > c.config.MaxCost {
+		// the entry would evict everything else and then itself
+		evictions = append(evictions, eviction[K, V]{entry, EvictionCapacity})
+		return
+	}
+	c.entries[key] = c.recent.PushFront(entry)
+	c.cost += entry.cost
+
+	for c.recent.Len() > 0 && ((c.config.MaxEntries > 0 && c.recent.Len() > c.config.MaxEntries) || (c.config.MaxCost > 0 && c.cost > c.config.MaxCost)) {
+		oldest := c.recent.Back()
+		reason := EvictionCapacity
+		if c.expired(oldest.Value.(*cacheEntry[K, V]), now) {
+			reason = EvictionExpired
+		}
+		evictions = c.remove(oldest, reason, evictions)
+	}
+}
+
+// Delete removes the entry of key and reports whether it was in the cache
+func (c *Cache[K, V]) Delete(key K) bool {
+	var evictions []eviction[K, V]
+	defer func() { c.notify(evictions) }()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	evictions = c.remove(element, EvictionDeleted, evictions)
+	return true
+}
+
+// DeleteExpired removes all expired entries and returns their number, e.g. from a ticker to release memory early
+func (c *Cache[K, V]) DeleteExpired() int {
+	var evictions []eviction[K, V]
+	defer func() { c.notify(evictions) }()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.now()
+	for element := c.recent.Front(); element != nil; {
+		next := element.Next()
+		if c.expired(element.Value.(*cacheEntry[K, V]), now) {
+			evictions = c.remove(element, EvictionExpired, evictions)
+		}
+		element = next
+	}
+	return len(evictions)
+}
+
+// Clear removes all entries
+func (c *Cache[K, V]) Clear() {
+	var evictions []eviction[K, V]
+	defer func() { c.notify(evictions) }()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for c.recent.Len() > 0 {
+		evictions = c.remove(c.recent.Front(), EvictionDeleted, evictions)
+	}
+}
+
+// Len returns the number of entries, including expired entries not removed yet
+func (c *Cache[K, V]) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.recent.Len()
+}
+
+// Cost returns the total cost of the entries
+func (c *Cache[K, V]) Cost() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.cost
+}
+
+// All returns an iterator over the entries that have not expired, from the most to the least recently used,
+// without changing their recency
+// The entries are copied when the iteration starts, the cache can be changed during the iteration
+func (c *Cache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		c.lock.Lock()
+		now := c.now()
+		entries := make([]cacheEntry[K, V], 0, c.recent.Len())
+		for element := c.recent.Front(); element != nil; element = element.Next() {
+			if entry := element.Value.(*cacheEntry[K, V]); !c.expired(entry, now) {
+				entries = append(entries, *entry)
+			}
+		}
+		c.lock.Unlock()
+		for _, entry := range entries {
+			if !yield(entry.key, entry.value) {
+				return
+			}
+		}
+	}
+}
+
+// Keys returns an iterator over the keys of All
+func (c *Cache[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for key := range c.All() {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
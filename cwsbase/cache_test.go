@@ -0,0 +1,120 @@
+package cwsbase
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+type cacheTestEviction struct {
+	key    string
+	reason EvictionReason
+}
+
+func TestCacheLRU(t *testing.T) {
+	var evicted []cacheTestEviction
+	cache := NewCache(CacheConfig[string, int]{
+		MaxEntries: 2,
+		OnEvict: func(key string, value int, reason EvictionReason) {
+			evicted = append(evicted, cacheTestEviction{key, reason})
+		},
+	})
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("a")
+	cache.Set("c", 3)
+	if _, ok := cache.Get("b"); ok {
+		t.Error("least recently used entry b was not evicted")
+	}
+	if got := slices.Collect(cache.Keys()); !slices.Equal(got, []string{"c", "a"}) {
+		t.Errorf("Keys() = %v", got)
+	}
+
+	cache.Set("a", 10)
+	if value, _ := cache.Peek("a"); value != 10 {
+		t.Errorf("Peek(a) = %d", value)
+	}
+	cache.Delete("c")
+	cache.Clear()
+	want := []cacheTestEviction{{"b", EvictionCapacity}, {"a", EvictionReplaced}, {"c", EvictionDeleted}, {"a", EvictionDeleted}}
+	if !slices.Equal(evicted, want) {
+		t.Errorf("evicted %v, want %v", evicted, want)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var evicted []cacheTestEviction
+	cache := NewCache(CacheConfig[string, string]{
+		TTL: time.Minute,
+		OnEvict: func(key string, value string, reason EvictionReason) {
+			evicted = append(evicted, cacheTestEviction{key, reason})
+		},
+	})
+	cache.now = func() time.Time { return now }
+	cache.Set("short", "1")
+	cache.SetWithTTL("long", "2", time.Hour)
+	cache.SetWithTTL("forever", "3", 0)
+	cache.Set("other", "4")
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := cache.Get("short"); ok {
+		t.Error("expired entry returned")
+	}
+	if got := slices.Collect(cache.Keys()); !slices.Equal(got, []string{"forever", "long"}) {
+		t.Errorf("Keys() = %v", got)
+	}
+	if removed := cache.DeleteExpired(); removed != 1 || cache.Len() != 2 {
+		t.Errorf("DeleteExpired() = %d, Len() = %d", removed, cache.Len())
+	}
+	want := []cacheTestEviction{{"short", EvictionExpired}, {"other", EvictionExpired}}
+	if !slices.Equal(evicted, want) {
+		t.Errorf("evicted %v, want %v", evicted, want)
+	}
+}
+
+func TestCacheCost(t *testing.T) {
+	cache := NewCache(CacheConfig[string, []byte]{
+		MaxCost: 10,
+		Cost:    func(key string, value []byte) int64 { return int64(len(value)) },
+	})
+	cache.Set("a", make([]byte, 4))
+	cache.Set("b", make([]byte, 4))
+	cache.Set("c", make([]byte, 4))
+	if cache.Len() != 2 || cache.Cost() != 8 {
+		t.Errorf("Len() = %d, Cost() = %d", cache.Len(), cache.Cost())
+	}
+	cache.Set("huge", make([]byte, 11))
+	if _, ok := cache.Get("huge"); ok || cache.Len() != 2 || cache.Cost() != 8 {
+		t.Errorf("entry over MaxCost kept, Len() = %d", cache.Len())
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	var cache *Cache[string, int]
+	cache = NewCache(CacheConfig[string, int]{
+		MaxEntries: 50,
+		OnEvict: func(key string, value int, reason EvictionReason) {
+			// OnEvict is called without the lock, so it can use the cache
+			cache.Peek(key)
+		},
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := fmt.Sprint(i, "-", j%100)
+				cache.Set(key, j)
+				cache.Get(key)
+			}
+		}()
+	}
+	wg.Wait()
+	if cache.Len() != 50 {
+		t.Errorf("Len() = %d, want 50", cache.Len())
+	}
+}
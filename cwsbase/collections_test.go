@@ -0,0 +1,160 @@
+package cwsbase
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestStack(t *testing.T) {
+	stack := NewStack[int]()
+	values := []int{1, 2, 3}
+	for i := range values {
+		stack.Push(&values[i])
+	}
+	var all []int
+	for value := range stack.All() {
+		all = append(all, *value)
+	}
+	if !slices.Equal(all, []int{3, 2, 1}) {
+		t.Errorf("All() = %v", all)
+	}
+	if *stack.Pop() != 3 || *stack.Peek() != 2 || stack.Len() != 2 {
+		t.Errorf("stack after Pop() = %d items", stack.Len())
+	}
+	stack.Clear()
+	if stack.Pop() != nil || stack.Len() != 0 {
+		t.Error("stack not empty after Clear()")
+	}
+}
+
+func TestDeque(t *testing.T) {
+	deque := NewDeque[int](2)
+	deque.PushBack(3, 4, 5)
+	deque.PushFront(2, 1)
+	if got := slices.Collect(deque.All()); !slices.Equal(got, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("All() = %v", got)
+	}
+	if got := slices.Collect(deque.Backward()); !slices.Equal(got, []int{5, 4, 3, 2, 1}) {
+		t.Errorf("Backward() = %v", got)
+	}
+	if front, ok := deque.PopFront(); !ok || front != 1 {
+		t.Errorf("PopFront() = %d, %v", front, ok)
+	}
+	if back, ok := deque.PopBack(); !ok || back != 5 {
+		t.Errorf("PopBack() = %d, %v", back, ok)
+	}
+	if front, _ := deque.Front(); front != 2 {
+		t.Errorf("Front() = %d", front)
+	}
+	if back, _ := deque.Back(); back != 4 {
+		t.Errorf("Back() = %d", back)
+	}
+	deque.Clear()
+	if _, ok := deque.PopBack(); ok || deque.Len() != 0 {
+		t.Error("deque not empty after Clear()")
+	}
+
+	var queue Queue[string]
+	queue.Enqueue("a", "b")
+	queue.Enqueue("c")
+	if first, ok := queue.Dequeue(); !ok || first != "a" {
+		t.Errorf("Dequeue() = %q, %v", first, ok)
+	}
+	if got := slices.Collect(queue.All()); !slices.Equal(got, []string{"b", "c"}) {
+		t.Errorf("queue All() = %v", got)
+	}
+}
+
+func TestDequeConcurrent(t *testing.T) {
+	queue := NewQueue[int](0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				queue.Enqueue(j)
+			}
+		}()
+	}
+	wg.Wait()
+	count := 0
+	for _, ok := queue.Dequeue(); ok; _, ok = queue.Dequeue() {
+		count++
+	}
+	if count != 800 {
+		t.Errorf("dequeued %d values, want 800", count)
+	}
+}
+
+func TestPriorityQueue(t *testing.T) {
+	queue := NewPriorityQueue[int]()
+	queue.Push(5, 1, 4, 2, 3, 1)
+	if got := slices.Collect(queue.All()); !slices.Equal(got, []int{1, 1, 2, 3, 4, 5}) {
+		t.Errorf("All() = %v", got)
+	}
+	var popped []int
+	for value, ok := queue.Pop(); ok; value, ok = queue.Pop() {
+		popped = append(popped, value)
+	}
+	if !slices.Equal(popped, []int{1, 1, 2, 3, 4, 5}) {
+		t.Errorf("popped %v", popped)
+	}
+
+	type job struct {
+		name     string
+		priority int
+	}
+	jobs := NewPriorityQueueFunc(func(a, b job) bool { return a.priority > b.priority })
+	jobs.Push(job{"low", 1}, job{"high", 9}, job{"mid", 5})
+	if first, _ := jobs.Peek(); first.name != "high" || jobs.Len() != 3 {
+		t.Errorf("Peek() = %+v", first)
+	}
+}
+
+func TestRingBuffer(t *testing.T) {
+	ring := NewRingBuffer[int](3)
+	for i := 1; i <= 3; i++ {
+		if _, overwritten := ring.Push(i); overwritten {
+			t.Errorf("Push(%d) overwrote a value", i)
+		}
+	}
+	if oldest, overwritten := ring.Push(4); !overwritten || oldest != 1 {
+		t.Errorf("Push(4) = %d, %v", oldest, overwritten)
+	}
+	if got := slices.Collect(ring.All()); !slices.Equal(got, []int{2, 3, 4}) {
+		t.Errorf("All() = %v", got)
+	}
+	if oldest, ok := ring.Pop(); !ok || oldest != 2 || ring.Len() != 2 || ring.Cap() != 3 {
+		t.Errorf("Pop() = %d, %v", oldest, ok)
+	}
+}
+
+func TestOrderedSet(t *testing.T) {
+	set := NewOrderedSet(5, 3, 9, 3)
+	if added := set.Add(1, 5, 7); added != 2 {
+		t.Errorf("Add() = %d, want 2", added)
+	}
+	if got := set.Values(); !slices.Equal(got, []int{1, 3, 5, 7, 9}) {
+		t.Errorf("Values() = %v", got)
+	}
+	if got := slices.Collect(set.Backward()); !slices.Equal(got, []int{9, 7, 5, 3, 1}) {
+		t.Errorf("Backward() = %v", got)
+	}
+	if got := slices.Collect(set.Range(3, 8)); !slices.Equal(got, []int{3, 5, 7}) {
+		t.Errorf("Range(3, 8) = %v", got)
+	}
+	if got := slices.Collect(set.Range(8, 3)); len(got) != 0 {
+		t.Errorf("Range(8, 3) = %v", got)
+	}
+	if removed := set.Remove(3, 4); removed != 1 || set.Contains(3) || !set.Contains(5) {
+		t.Errorf("Remove() = %d", removed)
+	}
+	if min, _ := set.Min(); min != 1 {
+		t.Errorf("Min() = %d", min)
+	}
+	if max, _ := set.Max(); max != 9 {
+		t.Errorf("Max() = %d", max)
+	}
+}
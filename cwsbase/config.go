@@ -0,0 +1,582 @@
+package cwsbase
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrConfigMissing is returned for a required config value that is not set and has no default
+var ErrConfigMissing = errors.New("required config value is missing")
+
+// ConfigError describes a config value that failed to load
+type ConfigError struct {
+	// Field is the path of the struct field, e.g. "Database.Host", empty for LookupEnv
+	Field string
+	// Key is the config key of the value, e.g. "DB_HOST"
+	Key string
+	// Err is ErrConfigMissing or the parse error of the value
+	Err error
+}
+
+// Error formats the error as "config KEY (Field): reason"
+func (e *ConfigError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("config %s: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("config %s (%s): %v", e.Key, e.Field, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigOption configures LoadConfig
+type ConfigOption func(*configOptions)
+
+type configOptions struct {
+	files       []string
+	dotEnvFiles []string
+	prefix      string
+	lookup      func(key string) (string, bool)
+	ctx         context.Context
+}
+
+// WithConfigFile layers JSON, YAML or TOML files under the environment, later files override earlier ones
+// Nested objects are joined with "_", e.g. {"db": {"host": "x"}} sets DB_HOST; keys match case-insensitively
+func WithConfigFile(filenames ...string) ConfigOption {
+	return func(o *configOptions) {
+		o.files = append(o.files, filenames...)
+	}
+}
+
+// WithDotEnvFile layers .env files between config files and the environment, later files override earlier ones
+// Missing .env files are ignored, so they can be used for local development only
+func WithDotEnvFile(filenames ...string) ConfigOption {
+	return func(o *configOptions) {
+		o.dotEnvFiles = append(o.dotEnvFiles, filenames...)
+	}
+}
+
+// WithConfigPrefix prepends prefix to every key, e.g. "APP_"
+func WithConfigPrefix(prefix string) ConfigOption {
+	return func(o *configOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithConfigLookup replaces os.LookupEnv as the environment, e.g. for tests
+func WithConfigLookup(lookup func(key string) (string, bool)) ConfigOption {
+	return func(o *configOptions) {
+		o.lookup = lookup
+	}
+}
+
+// WithConfigContext sets the context of resolving secret references, see RegisterSecretResolver
+func WithConfigContext(ctx context.Context) ConfigOption {
+	return func(o *configOptions) {
+		o.ctx = ctx
+	}
+}
+
+// configSources holds the layers of config values, the environment overrides .env files which override config files
+type configSources struct {
+	ctx    context.Context
+	lookup func(key string) (string, bool)
+	dotEnv []map[string]string
+	files  []map[string]any
+}
+
+// value returns the value of key from the highest layer that sets it, empty environment values count as unset
+func (s *configSources) value(key string) (any, bool) {
+	if v, ok := s.lookup(key); ok && v != "" {
+		return v, true
+	}
+	for i := len(s.dotEnv) - 1; i >= 0; i-- {
+		if v, ok := s.dotEnv[i][key]; ok && v != "" {
+			return v, true
+		}
+	}
+	for i := len(s.files) - 1; i >= 0; i-- {
+		if v, ok := s.files[i][strings.ToUpper(key)]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// LoadConfig fills the struct pointed to by target from the environment, .env files and config files
+// Fields are tagged with the key and optional default and required flag, nested structs add their prefix tag to the keys:
+//
+//	type Config struct {
+//		S3CacheTTL int            `env:"S3CacheTTL" default:"10"`
+//		Timeout    time.Duration  `env:"TIMEOUT" default:"30s"`
+//		Origins    []string       `env:"ORIGINS"`             // a,b,c
+//		Limits     map[string]int `env:"LIMITS"`              // a:1,b:2
+//		Endpoint   *url.URL       `env:"ENDPOINT" required:"true"`
+//		Database   struct {
+//			Host string `env:"HOST" required:"true"`
+//		} `prefix:"DB_"`
+//	}
+//
+// Supports strings, bools, numbers, time.Duration, url.URL, encoding.TextUnmarshaler, pointers, slices and maps of those
+// String values with the scheme of a registered SecretResolver, e.g. "ssm:/prod/db/url", are resolved first
+// All invalid or missing values are collected into the returned error, use errors.As with *ConfigError to inspect them
+func LoadConfig(target any, opts ...ConfigOption) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config target must be a non-nil struct pointer, got %T", target)
+	}
+
+	o := configOptions{lookup: os.LookupEnv, ctx: context.Background()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	sources := &configSources{ctx: o.ctx, lookup: o.lookup}
+	for _, filename := range o.files {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		values, err := parseConfigFile(filename, content)
+		if err != nil {
+			return err
+		}
+		sources.files = append(sources.files, values)
+	}
+	for _, filename := range o.dotEnvFiles {
+		content, err := os.ReadFile(filename)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		values, err := parseDotEnv(content)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		sources.dotEnv = append(sources.dotEnv, values)
+	}
+
+	var errs []error
+	loadConfigStruct(v.Elem(), "", o.prefix, sources, &errs)
+	return errors.Join(errs...)
+}
+
+// LoadDotEnv sets the variables of .env files that are not set in the environment yet
+// Missing files are ignored; use it before code that reads os.Getenv directly
+func LoadDotEnv(filenames ...string) error {
+	for _, filename := range filenames {
+		content, err := os.ReadFile(filename)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		values, err := parseDotEnv(content)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		for key, value := range values {
+			if _, ok := os.LookupEnv(key); ok {
+				continue
+			}
+			if err := os.Setenv(key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LookupEnv reads the environment variable key as T, supporting the types of LoadConfig
+// Returns the default if the variable is not set, or a *ConfigError wrapping ErrConfigMissing without default
+// If the value is invalid the default, or the zero value, is returned with the error
+func LookupEnv[T any](key string, defaultVal ...T) (T, error) {
+	var t T
+	if len(defaultVal) > 0 {
+		t = defaultVal[0]
+	}
+	p := os.Getenv(key)
+	if p == "" {
+		if len(defaultVal) > 0 {
+			return t, nil
+		}
+		return t, &ConfigError{Key: key, Err: ErrConfigMissing}
+	}
+	var value T
+	if err := setConfigValue(reflect.ValueOf(&value).Elem(), p); err != nil {
+		return t, &ConfigError{Key: key, Err: err}
+	}
+	return value, nil
+}
+
+var (
+	durationType        = reflect.TypeFor[time.Duration]()
+	urlType             = reflect.TypeFor[url.URL]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// isConfigValueType reports whether t is loaded from a single value rather than as nested struct
+func isConfigValueType(t reflect.Type) bool {
+	return t == urlType || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// loadConfigStruct loads the tagged fields of v, appending the errors of all fields to errs
+func loadConfigStruct(v reflect.Value, fieldPath string, prefix string, sources *configSources, errs *[]error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Name
+		if fieldPath != "" {
+			name = fieldPath + "." + field.Name
+		}
+		key, tagged := field.Tag.Lookup("env")
+		if key == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+		if !tagged {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() != reflect.Struct || isConfigValueType(ft) {
+				continue
+			}
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					fv.Set(reflect.New(ft))
+				}
+				fv = fv.Elem()
+			}
+			loadConfigStruct(fv, name, prefix+field.Tag.Get("prefix"), sources, errs)
+			continue
+		}
+
+		key = prefix + key
+		raw, ok := sources.value(key)
+		if !ok {
+			if def, hasDefault := field.Tag.Lookup("default"); hasDefault {
+				raw, ok = def, true
+			}
+		}
+		if !ok {
+			if required, _ := strconv.ParseBool(field.Tag.Get("required")); required {
+				*errs = append(*errs, &ConfigError{Field: name, Key: key, Err: ErrConfigMissing})
+			}
+			continue
+		}
+		if s, ok := raw.(string); ok {
+			resolved, err := ResolveSecret(sources.ctx, s)
+			if err != nil {
+				*errs = append(*errs, &ConfigError{Field: name, Key: key, Err: err})
+				continue
+			}
+			raw = resolved
+		}
+		if err := setConfigValue(fv, raw); err != nil {
+			*errs = append(*errs, &ConfigError{Field: name, Key: key, Err: err})
+		}
+	}
+}
+
+// setConfigValue sets v from a string, or from the list, object or scalar of a config file
+func setConfigValue(v reflect.Value, raw any) error {
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if err := setConfigValue(elem.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	switch r := raw.(type) {
+	case []any:
+		if v.Kind() == reflect.Slice {
+			slice := reflect.MakeSlice(v.Type(), len(r), len(r))
+			for i, item := range r {
+				if err := setConfigValue(slice.Index(i), item); err != nil {
+					return err
+				}
+			}
+			v.Set(slice)
+			return nil
+		}
+	case map[string]any:
+		if v.Kind() == reflect.Map {
+			m := reflect.MakeMapWithSize(v.Type(), len(r))
+			for k, item := range r {
+				key := reflect.New(v.Type().Key()).Elem()
+				if err := setConfigValue(key, k); err != nil {
+					return err
+				}
+				value := reflect.New(v.Type().Elem()).Elem()
+				if err := setConfigValue(value, item); err != nil {
+					return err
+				}
+				m.SetMapIndex(key, value)
+			}
+			v.Set(m)
+			return nil
+		}
+	}
+	return parseConfigValue(v, configString(raw))
+}
+
+// parseConfigValue parses s into v, lists are comma separated and maps are comma separated key:value pairs
+func parseConfigValue(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case v.Type() == urlType:
+		u, err := url.Parse(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	case v.Addr().Type().Implements(textUnmarshalerType):
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s))
+			return nil
+		}
+		items := splitConfigList(s)
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := parseConfigValue(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		items := splitConfigList(s)
+		m := reflect.MakeMapWithSize(v.Type(), len(items))
+		for _, item := range items {
+			k, val, ok := strings.Cut(item, ":")
+			if !ok {
+				return fmt.Errorf("invalid map entry %q, expect key:value", item)
+			}
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := parseConfigValue(key, strings.TrimSpace(k)); err != nil {
+				return err
+			}
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := parseConfigValue(value, strings.TrimSpace(val)); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
+
+// splitConfigList splits a comma separated list, trimming spaces and dropping empty items
+func splitConfigList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// configString formats a scalar, list or object of a config file in the string format of the environment
+func configString(raw any) string {
+	switch r := raw.(type) {
+	case string:
+		return r
+	case json.Number:
+		return r.String()
+	case float64:
+		return strconv.FormatFloat(r, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(r), 'f', -1, 32)
+	case time.Time:
+		return r.Format(time.RFC3339Nano)
+	case []any:
+		items := make([]string, len(r))
+		for i, item := range r {
+			items[i] = configString(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]any:
+		items := make([]string, 0, len(r))
+		for k, item := range r {
+			items = append(items, k+":"+configString(item))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(raw)
+}
+
+// parseConfigFile decodes a JSON, YAML or TOML config file into upper cased keys
+// Nested objects are flattened with "_" and kept under their own key as well, so they can fill map fields
+func parseConfigFile(name string, content []byte) (map[string]any, error) {
+	var raw map[string]any
+	var err error
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+		err = decoder.Decode(&raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &raw)
+	case ".toml":
+		err = toml.Unmarshal(content, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file format: %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", name, err)
+	}
+
+	values := map[string]any{}
+	flattenConfigFile("", raw, values)
+	return values, nil
+}
+
+// flattenConfigFile copies nested config values into values keyed by the joined upper cased keys
+func flattenConfigFile(prefix string, raw map[string]any, values map[string]any) {
+	for k, v := range raw {
+		key := strings.ToUpper(k)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		values[key] = v
+		if nested, ok := v.(map[string]any); ok {
+			flattenConfigFile(key, nested, values)
+		}
+	}
+}
+
+// parseDotEnv parses KEY=VALUE lines, skipping blank lines, comments and an "export " prefix
+// Double quoted values support \n, \" and \\ escapes, single quoted values are literal, unquoted values end at " #"
+func parseDotEnv(content []byte) (map[string]string, error) {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: expect KEY=VALUE", n)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, `"`):
+			unquoted, err := unquoteDotEnv(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			value = unquoted
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quote", n)
+			}
+			value = value[1 : end+1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+// unquoteDotEnv returns the content of a double quoted .env value, ignoring anything after the closing quote
+func unquoteDotEnv(value string) (string, error) {
+	var b strings.Builder
+	for i := 1; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if i+1 == len(value) {
+				return "", errors.New("unterminated quote")
+			}
+			i++
+			switch value[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(value[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", errors.New("unterminated quote")
+}
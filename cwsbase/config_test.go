@@ -0,0 +1,190 @@
+package cwsbase
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	S3CacheTTL int               `env:"S3CacheTTL" default:"10"`
+	Big        int64             `env:"BIG"`
+	Ratio      float64           `env:"RATIO"`
+	Timeout    time.Duration     `env:"TIMEOUT" default:"30s"`
+	Origins    []string          `env:"ORIGINS"`
+	Limits     map[string]int    `env:"LIMITS"`
+	Labels     map[string]string `env:"LABELS"`
+	Endpoint   *url.URL          `env:"ENDPOINT"`
+	Skipped    *skippedConfig    `env:"-"`
+	Name       string            `env:"NAME" required:"true"`
+	Database   struct {
+		Host string `env:"HOST" required:"true"`
+		Port int    `env:"PORT" default:"5432"`
+	} `prefix:"DB_"`
+}
+
+type skippedConfig struct {
+	Value string `env:"VALUE" required:"true"`
+}
+
+func mapLookup(env map[string]string) ConfigOption {
+	return WithConfigLookup(func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	var config testConfig
+	err := LoadConfig(&config, mapLookup(map[string]string{
+		"BIG":      "9000000000",
+		"RATIO":    "0.1234567890123",
+		"TIMEOUT":  "1m",
+		"ORIGINS":  "a.com, b.com",
+		"LIMITS":   "read:10,write:2",
+		"NAME":     "api",
+		"DB_HOST":  "db.local",
+		"ENDPOINT": "https://api.local/v1",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.S3CacheTTL != 10 || config.Big != 9000000000 || config.Ratio != 0.1234567890123 || config.Timeout != time.Minute {
+		t.Errorf("scalars = %+v", config)
+	}
+	if !reflect.DeepEqual(config.Origins, []string{"a.com", "b.com"}) || !reflect.DeepEqual(config.Limits, map[string]int{"read": 10, "write": 2}) {
+		t.Errorf("origins = %v, limits = %v", config.Origins, config.Limits)
+	}
+	if config.Database.Host != "db.local" || config.Database.Port != 5432 || config.Endpoint.Host != "api.local" || config.Skipped != nil {
+		t.Errorf("database = %+v, endpoint = %v", config.Database, config.Endpoint)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	var config testConfig
+	err := LoadConfig(&config, mapLookup(map[string]string{
+		"S3CacheTTL": "ten",
+		"TIMEOUT":    "30",
+	}))
+
+	var keys []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var configErr *ConfigError
+		if !errors.As(e, &configErr) {
+			t.Fatalf("error %v is not a ConfigError", e)
+		}
+		keys = append(keys, configErr.Key)
+	}
+	if want := []string{"S3CacheTTL", "TIMEOUT", "NAME", "DB_HOST"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("error keys = %v, want %v", keys, want)
+	}
+	if !errors.Is(err, ErrConfigMissing) {
+		t.Error("missing required values do not wrap ErrConfigMissing")
+	}
+
+	if err := LoadConfig(config); err == nil {
+		t.Error("LoadConfig() with a non-pointer target succeeded")
+	}
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "config.yaml")
+	jsonFile := filepath.Join(dir, "config.json")
+	dotEnv := filepath.Join(dir, ".env")
+	write := func(name string, content string) {
+		if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(yamlFile, "name: from-yaml\nratio: 0.5\norigins: [a.com, b.com]\nlabels: {team: core, tier: \"1\"}\ndb:\n  host: yaml-db\n  port: 1000\n")
+	write(jsonFile, `{"DB": {"PORT": 2000}, "BIG": 9000000000}`)
+	write(dotEnv, "# local\nexport DB_HOST=dotenv-db\nTIMEOUT=\"2m\" # quoted\nNAME='dotenv' \n")
+
+	var config testConfig
+	err := LoadConfig(&config,
+		WithConfigFile(yamlFile, jsonFile),
+		WithDotEnvFile(dotEnv, filepath.Join(dir, ".env.missing")),
+		mapLookup(map[string]string{"NAME": "from-env", "DB_HOST": ""}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Name != "from-env" || config.Database.Host != "dotenv-db" || config.Database.Port != 2000 {
+		t.Errorf("layers = %+v", config)
+	}
+	if config.Big != 9000000000 || config.Ratio != 0.5 || config.Timeout != 2*time.Minute {
+		t.Errorf("file values = %+v", config)
+	}
+	if !reflect.DeepEqual(config.Origins, []string{"a.com", "b.com"}) || !reflect.DeepEqual(config.Labels, map[string]string{"team": "core", "tier": "1"}) {
+		t.Errorf("origins = %v, labels = %v", config.Origins, config.Labels)
+	}
+
+	if err := LoadConfig(&config, WithConfigFile(filepath.Join(dir, "missing.yaml"))); err == nil {
+		t.Error("LoadConfig() with a missing config file succeeded")
+	}
+}
+
+func TestParseDotEnv(t *testing.T) {
+	tests := []struct {
+		line  string
+		value string
+	}{
+		{"A=plain", "plain"},
+		{"A = spaced ", "spaced"},
+		{"A=value # comment", "value"},
+		{"A=a#b", "a#b"},
+		{`A="multi\nline \"quoted\""`, "multi\nline \"quoted\""},
+		{`A='lit\n # eral'`, `lit\n # eral`},
+		{"export A=exported", "exported"},
+		{"A=", ""},
+	}
+	for _, test := range tests {
+		values, err := parseDotEnv([]byte(test.line))
+		if err != nil {
+			t.Errorf("parseDotEnv(%q) error = %v", test.line, err)
+			continue
+		}
+		if values["A"] != test.value {
+			t.Errorf("parseDotEnv(%q) = %q, want %q", test.line, values["A"], test.value)
+		}
+	}
+	for _, line := range []string{"NOEQUALS", "A B=c", `A="open`} {
+		if _, err := parseDotEnv([]byte(line)); err == nil {
+			t.Errorf("parseDotEnv(%q) succeeded", line)
+		}
+	}
+}
+
+func TestLookupEnv(t *testing.T) {
+	t.Setenv("CONFIG_TEST_INT64", "9000000000")
+	t.Setenv("CONFIG_TEST_FLOAT", "0.1234567890123")
+	t.Setenv("CONFIG_TEST_BAD", "nope")
+
+	if v, err := LookupEnv[int64]("CONFIG_TEST_INT64"); err != nil || v != 9000000000 {
+		t.Errorf("LookupEnv[int64]() = %v, %v", v, err)
+	}
+	if v := GetEnv[float64]("CONFIG_TEST_FLOAT"); v != 0.1234567890123 {
+		t.Errorf("GetEnv[float64]() = %v", v)
+	}
+	if v, err := LookupEnv("CONFIG_TEST_BAD", 3); err == nil || v != 3 {
+		t.Errorf("LookupEnv() of an invalid value = %v, %v", v, err)
+	}
+	if _, err := LookupEnv[string]("CONFIG_TEST_UNSET"); !errors.Is(err, ErrConfigMissing) {
+		t.Errorf("LookupEnv() of an unset variable error = %v", err)
+	}
+}
+
+func TestLoadEnvironmentInfo(t *testing.T) {
+	info, err := LoadEnvironmentInfo(mapLookup(map[string]string{"ENV": "prod", "DEBUG": "true"}))
+	if err != nil || info != (EnvironmentInfo{Env: "prod", DebugMode: true}) {
+		t.Errorf("LoadEnvironmentInfo() = %+v, %v", info, err)
+	}
+	if _, err := LoadEnvironmentInfo(mapLookup(map[string]string{"DEBUG": "maybe"})); err == nil {
+		t.Error("LoadEnvironmentInfo() without ENV succeeded")
+	}
+}
@@ -0,0 +1,158 @@
+package cwsbase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+)
+
+type requestIdKey struct{}
+type traceParentKey struct{}
+
+// ContextWithRequestId returns a copy of ctx carrying the request id
+func ContextWithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// RequestIdFromContext returns the request id carried by ctx, empty if there is none
+func RequestIdFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+// ContextWithTraceParent returns a copy of ctx carrying a W3C traceparent header value
+func ContextWithTraceParent(ctx context.Context, traceParent string) context.Context {
+	return context.WithValue(ctx, traceParentKey{}, traceParent)
+}
+
+// TraceParentFromContext returns the W3C traceparent carried by ctx, empty if there is none
+func TraceParentFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	traceParent, _ := ctx.Value(traceParentKey{}).(string)
+	return traceParent
+}
+
+// randomHex returns n random bytes as lowercase hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// NewRequestId returns a random request id of 32 hex characters
+func NewRequestId() string {
+	return randomHex(16)
+}
+
+// NewTraceParent returns a sampled W3C traceparent of a new trace, e.g. "00-<trace id>-<parent id>-01"
+func NewTraceParent() string {
+	return "00-" + randomHex(16) + "-" + randomHex(8) + "-01"
+}
+
+// ParseTraceParent returns the trace id and parent id of a W3C traceparent header value
+// ok is false if the value is malformed or uses the invalid all-zero ids
+func ParseTraceParent(traceParent string) (traceId string, parentId string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", "", false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", "", false
+	}
+	for _, part := range parts[:4] {
+		if _, err := hex.DecodeString(part); err != nil || part != strings.ToLower(part) {
+			return "", "", false
+		}
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// correlationAttrs returns the request_id and trace_id attributes of ctx
+func correlationAttrs(ctx context.Context) []slog.Attr {
+	attrs := []slog.Attr{}
+	if requestId := RequestIdFromContext(ctx); requestId != "" {
+		attrs = append(attrs, slog.String("request_id", requestId))
+	}
+	if traceId, _, ok := ParseTraceParent(TraceParentFromContext(ctx)); ok {
+		attrs = append(attrs, slog.String("trace_id", traceId))
+	}
+	return attrs
+}
+
+// Logger returns slog.Default() with the request_id and trace_id of ctx, if any
+// e.g. cwsbase.Logger(ctx).Warn("item skipped", "error", err)
+func Logger(ctx context.Context) *slog.Logger {
+	return LoggerWithContext(slog.Default(), ctx)
+}
+
+// LoggerWithContext returns logger with the request_id and trace_id of ctx, if any
+// When the handler of logger is a ContextHandler it adds them itself, then log with the ...Context methods
+func LoggerWithContext(logger *slog.Logger, ctx context.Context) *slog.Logger {
+	if _, ok := logger.Handler().(*ContextHandler); ok {
+		return logger
+	}
+	for _, attr := range correlationAttrs(ctx) {
+		logger = logger.With(attr)
+	}
+	return logger
+}
+
+// ContextHandler is a slog.Handler adding the request_id and trace_id of the context to each record
+// Install it once, e.g. slog.SetDefault(slog.New(cwsbase.NewContextHandler(slog.NewJSONHandler(os.Stdout, nil)))),
+// and every slog.InfoContext(ctx, ...) call is correlated with the request
+type ContextHandler struct {
+	slog.Handler
+	// keys are the top-level attribute keys added with WithAttrs
+	keys map[string]bool
+	// grouped is set once a group is opened, later attributes are no top-level attributes
+	grouped bool
+}
+
+// NewContextHandler wraps handler with ContextHandler
+func NewContextHandler(handler slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: handler}
+}
+
+// Handle adds the correlation attributes of ctx that neither the record nor the logger has yet
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	present := map[string]bool{}
+	record.Attrs(func(attr slog.Attr) bool {
+		present[attr.Key] = true
+		return true
+	})
+	for _, attr := range correlationAttrs(ctx) {
+		if !present[attr.Key] && !h.keys[attr.Key] {
+			record.AddAttrs(attr)
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a ContextHandler wrapping the handler with the attributes
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	keys := map[string]bool{}
+	for key := range h.keys {
+		keys[key] = true
+	}
+	if !h.grouped {
+		for _, attr := range attrs {
+			keys[attr.Key] = true
+		}
+	}
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs), keys: keys, grouped: h.grouped}
+}
+
+// WithGroup returns a ContextHandler wrapping the handler with the group
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name), keys: h.keys, grouped: h.grouped || name != ""}
+}
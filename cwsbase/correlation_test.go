@@ -0,0 +1,60 @@
+package cwsbase
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		traceParent string
+		traceId     string
+		ok          bool
+	}{
+		{traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", traceId: "4bf92f3577b34da6a3ce929d0e0e4736", ok: true},
+		{traceParent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", traceId: "4bf92f3577b34da6a3ce929d0e0e4736", ok: true},
+		{traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{traceParent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{traceParent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{traceParent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{traceParent: "garbage"},
+		{traceParent: NewTraceParent(), ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.traceParent, func(t *testing.T) {
+			traceId, _, ok := ParseTraceParent(tt.traceParent)
+			if ok != tt.ok || (tt.traceId != "" && traceId != tt.traceId) {
+				t.Errorf("ParseTraceParent() = %q, %v, want %q, %v", traceId, ok, tt.traceId, tt.ok)
+			}
+		})
+	}
+}
+
+func TestContextHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewContextHandler(slog.NewTextHandler(&buf, nil)))
+	ctx := ContextWithRequestId(context.Background(), "req-1")
+	ctx = ContextWithTraceParent(ctx, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	logger.InfoContext(ctx, "with context")
+	logger.With("request_id", "explicit").InfoContext(ctx, "explicit")
+	LoggerWithContext(logger, ctx).InfoContext(ctx, "derived")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("lines = %q", lines)
+	}
+	if !strings.Contains(lines[0], "request_id=req-1") || !strings.Contains(lines[0], "trace_id=4bf92f3577b34da6a3ce929d0e0e4736") {
+		t.Errorf("line %q misses the correlation ids", lines[0])
+	}
+	if !strings.Contains(lines[1], "request_id=explicit") || strings.Count(lines[1], "request_id=") != 1 {
+		t.Errorf("line %q does not keep the explicit request id", lines[1])
+	}
+	if strings.Count(lines[2], "request_id=") != 1 {
+		t.Errorf("line %q repeats the request id", lines[2])
+	}
+}
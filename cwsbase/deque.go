@@ -0,0 +1,207 @@
+package cwsbase
+
+import (
+	"iter"
+	"sync"
+)
+
+// Deque is a generic double-ended queue on a growing ring of values, safe for concurrent use
+// The zero value is an empty deque, a Deque must not be copied after first use
+type Deque[T any] struct {
+	lock   sync.Mutex
+	values []T
+	// head is the index of the front value in values
+	head   int
+	length int
+}
+
+// NewDeque returns an empty deque with room for capacity values before it grows
+func NewDeque[T any](capacity int) *Deque[T] {
+	return &Deque[T]{values: make([]T, max(capacity, 0))}
+}
+
+// index returns the index in values of the i-th value from the front
+func (d *Deque[T]) index(i int) int {
+	return (d.head + i) % len(d.values)
+}
+
+// grow doubles the ring when it is full, moving the values to the start
+func (d *Deque[T]) grow() {
+	if d.length < len(d.values) {
+		return
+	}
+	values := make([]T, max(2*len(d.values), 8))
+	for i := 0; i < d.length; i++ {
+		values[i] = d.values[d.index(i)]
+	}
+	d.values = values
+	d.head = 0
+}
+
+// PushBack adds values to the back of the deque in order
+func (d *Deque[T]) PushBack(values ...T) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, value := range values {
+		d.grow()
+		d.values[d.index(d.length)] = value
+		d.length++
+	}
+}
+
+// PushFront adds values to the front of the deque in order, so the last value becomes the front
+func (d *Deque[T]) PushFront(values ...T) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, value := range values {
+		d.grow()
+		d.head = (d.head - 1 + len(d.values)) % len(d.values)
+		d.values[d.head] = value
+		d.length++
+	}
+}
+
+// PopFront removes and returns the front value, false if the deque is empty
+func (d *Deque[T]) PopFront() (T, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var zero T
+	if d.length == 0 {
+		return zero, false
+	}
+	value := d.values[d.head]
+	// release the reference for the garbage collector
+	d.values[d.head] = zero
+	d.head = d.index(1)
+	d.length--
+	return value, true
+}
+
+// PopBack removes and returns the back value, false if the deque is empty
+func (d *Deque[T]) PopBack() (T, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var zero T
+	if d.length == 0 {
+		return zero, false
+	}
+	i := d.index(d.length - 1)
+	value := d.values[i]
+	d.values[i] = zero
+	d.length--
+	return value, true
+}
+
+// Front returns the front value without removing it, false if the deque is empty
+func (d *Deque[T]) Front() (T, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.length == 0 {
+		var zero T
+		return zero, false
+	}
+	return d.values[d.head], true
+}
+
+// Back returns the back value without removing it, false if the deque is empty
+func (d *Deque[T]) Back() (T, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.length == 0 {
+		var zero T
+		return zero, false
+	}
+	return d.values[d.index(d.length-1)], true
+}
+
+// Len returns the number of values in the deque
+func (d *Deque[T]) Len() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.length
+}
+
+// Clear removes all values from the deque
+func (d *Deque[T]) Clear() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	clear(d.values)
+	d.head = 0
+	d.length = 0
+}
+
+// snapshot returns a copy of the values from the front to the back
+func (d *Deque[T]) snapshot() []T {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	values := make([]T, d.length)
+	for i := range values {
+		values[i] = d.values[d.index(i)]
+	}
+	return values
+}
+
+// All returns an iterator over the values from the front to the back
+// The values are copied when the iteration starts, the deque can be changed during the iteration
+func (d *Deque[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, value := range d.snapshot() {
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// Backward returns an iterator over the values from the back to the front
+func (d *Deque[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		values := d.snapshot()
+		for i := len(values) - 1; i >= 0; i-- {
+			if !yield(values[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Queue is a generic FIFO (First In, First Out) queue, safe for concurrent use
+// The zero value is an empty queue
+type Queue[T any] struct {
+	deque Deque[T]
+}
+
+// NewQueue returns an empty queue with room for capacity values before it grows
+func NewQueue[T any](capacity int) *Queue[T] {
+	return &Queue[T]{deque: Deque[T]{values: make([]T, max(capacity, 0))}}
+}
+
+// Enqueue adds values to the back of the queue in order
+func (q *Queue[T]) Enqueue(values ...T) {
+	q.deque.PushBack(values...)
+}
+
+// Dequeue removes and returns the oldest value, false if the queue is empty
+func (q *Queue[T]) Dequeue() (T, bool) {
+	return q.deque.PopFront()
+}
+
+// Peek returns the oldest value without removing it, false if the queue is empty
+func (q *Queue[T]) Peek() (T, bool) {
+	return q.deque.Front()
+}
+
+// Len returns the number of values in the queue
+func (q *Queue[T]) Len() int {
+	return q.deque.Len()
+}
+
+// Clear removes all values from the queue
+func (q *Queue[T]) Clear() {
+	q.deque.Clear()
+}
+
+// All returns an iterator over the values from the oldest to the newest, see Deque.All
+func (q *Queue[T]) All() iter.Seq[T] {
+	return q.deque.All()
+}
@@ -0,0 +1,147 @@
+package cwsbase
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrorReport describes an error that occurred while serving a request
+type ErrorReport struct {
+	// Err is the reported error
+	Err error
+	// Time is when the error was reported
+	Time time.Time
+	// Method is the HTTP method of the request
+	Method string
+	// Path is the URL path of the request
+	Path string
+	// RequestId is the id of the request, empty if the request has none
+	RequestId string
+	// StatusCode is the HTTP status code written for the error
+	StatusCode int
+	// Stack is the goroutine stack trace, only captured for server errors and panics
+	Stack []byte
+	// Panic is true if the error was recovered from a panic in the handler
+	Panic bool
+}
+
+// MarshalJSON encodes the report with the error as its message and the stack as text
+func (r ErrorReport) MarshalJSON() ([]byte, error) {
+	errorMessage := ""
+	if r.Err != nil {
+		errorMessage = r.Err.Error()
+	}
+	return json.Marshal(struct {
+		Error      string    `json:"error"`
+		Time       time.Time `json:"time"`
+		Method     string    `json:"method,omitempty"`
+		Path       string    `json:"path,omitempty"`
+		RequestId  string    `json:"request_id,omitempty"`
+		StatusCode int       `json:"status"`
+		Stack      string    `json:"stack,omitempty"`
+		Panic      bool      `json:"panic,omitempty"`
+	}{
+		Error:      errorMessage,
+		Time:       r.Time,
+		Method:     r.Method,
+		Path:       r.Path,
+		RequestId:  r.RequestId,
+		StatusCode: r.StatusCode,
+		Stack:      string(r.Stack),
+		Panic:      r.Panic,
+	})
+}
+
+// ErrorSink receives the errors reported while serving requests, e.g. to log them or forward them to CloudWatch
+// ReportError is called synchronously on the request goroutine and should not block for long
+type ErrorSink interface {
+	ReportError(ctx context.Context, report ErrorReport)
+}
+
+// ErrorSinkFunc adapts a function to the ErrorSink interface
+type ErrorSinkFunc func(ctx context.Context, report ErrorReport)
+
+// ReportError calls f(ctx, report)
+func (f ErrorSinkFunc) ReportError(ctx context.Context, report ErrorReport) {
+	f(ctx, report)
+}
+
+// SlogErrorSink writes error reports to a slog logger
+// Server errors are logged at error level, client errors at warn level
+type SlogErrorSink struct {
+	// Logger is the destination logger, nil means slog.Default()
+	Logger *slog.Logger
+}
+
+// ReportError logs the report with its request attributes
+func (s SlogErrorSink) ReportError(ctx context.Context, report ErrorReport) {
+	logger := s.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	level := slog.LevelWarn
+	if report.StatusCode >= 500 || report.Panic {
+		level = slog.LevelError
+	}
+	attrs := []slog.Attr{
+		slog.Any("error", report.Err),
+		slog.String("method", report.Method),
+		slog.String("path", report.Path),
+		slog.Int("status", report.StatusCode),
+	}
+	if report.RequestId != "" {
+		attrs = append(attrs, slog.String("request_id", report.RequestId))
+	}
+	if report.Panic {
+		attrs = append(attrs, slog.Bool("panic", true))
+	}
+	if len(report.Stack) > 0 {
+		attrs = append(attrs, slog.String("stack", string(report.Stack)))
+	}
+	logger.LogAttrs(ctx, level, "request failed", attrs...)
+}
+
+// MultiErrorSink forwards each report to all given sinks in order
+func MultiErrorSink(sinks ...ErrorSink) ErrorSink {
+	return ErrorSinkFunc(func(ctx context.Context, report ErrorReport) {
+		for _, sink := range sinks {
+			sink.ReportError(ctx, report)
+		}
+	})
+}
+
+var errorSinkLock sync.RWMutex
+var errorSink ErrorSink = SlogErrorSink{}
+
+// SetErrorSink replaces the sink receiving error reports, the default logs them with slog.Default()
+// Use MultiErrorSink to report to several destinations
+func SetErrorSink(sink ErrorSink) {
+	errorSinkLock.Lock()
+	defer errorSinkLock.Unlock()
+	errorSink = sink
+}
+
+// ReportError sends a report to the configured error sink, Time is set to now if empty
+// A panicking sink is recovered so error reporting never breaks the response
+func ReportError(ctx context.Context, report ErrorReport) {
+	errorSinkLock.RLock()
+	sink := errorSink
+	errorSinkLock.RUnlock()
+	if sink == nil {
+		return
+	}
+
+	if report.Time.IsZero() {
+		report.Time = time.Now()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("error sink panicked", "panic", r)
+		}
+	}()
+	sink.ReportError(ctx, report)
+}
@@ -0,0 +1,73 @@
+package cwsbase
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// valuePurpose is authenticated with encrypted values, so they cannot be opened as tokens and the other way around
+const valuePurpose = "value"
+
+// EncryptValue encrypts a stored value, e.g. a PII column, with the primary key of GetKeyring
+// The ciphertext is "v2.<key id>.<nonce and ciphertext>" and differs for equal values, use BlindIndex to search by value
+func EncryptValue(plaintext []byte) (string, error) {
+	keyring, err := GetKeyring()
+	if err != nil {
+		return "", err
+	}
+	return keyring.EncryptValue(plaintext)
+}
+
+// DecryptValue decrypts a value of EncryptValue with the keyring of GetKeyring
+func DecryptValue(ciphertext string) ([]byte, error) {
+	keyring, err := GetKeyring()
+	if err != nil {
+		return nil, err
+	}
+	return keyring.DecryptValue(ciphertext)
+}
+
+// BlindIndex returns the deterministic index of a value with the index key of GetKeyring, see Keyring.BlindIndex
+func BlindIndex(domain string, value string) (string, error) {
+	keyring, err := GetKeyring()
+	if err != nil {
+		return "", err
+	}
+	return keyring.BlindIndex(domain, value)
+}
+
+// EncryptValue encrypts a stored value with the primary key
+func (k *Keyring) EncryptValue(plaintext []byte) (string, error) {
+	return k.seal(plaintext, valuePurpose)
+}
+
+// DecryptValue decrypts a value encrypted with any key of the keyring
+func (k *Keyring) DecryptValue(ciphertext string) ([]byte, error) {
+	return k.open(ciphertext, valuePurpose)
+}
+
+// NeedsReEncrypt reports whether a value or token was not encrypted with the primary key
+func (k *Keyring) NeedsReEncrypt(ciphertext string) bool {
+	kid, ok := tokenKeyId(ciphertext)
+	return !ok || kid != k.PrimaryId()
+}
+
+// BlindIndex returns the HMAC-SHA256 of a value under the index key, the same value always gives the same index
+// Store it next to the encrypted value to search by exact value without decrypting, e.g. cwssql.Eq("phone_index", index)
+// domain separates the indexes of different fields, e.g. "phone" and "national_id", so equal values do not give equal indexes
+// Returns ErrBlindIndexKey if the keyring has no index key
+func (k *Keyring) BlindIndex(domain string, value string) (string, error) {
+	k.lock.RLock()
+	indexKey := k.indexKey
+	k.lock.RUnlock()
+	if indexKey == nil {
+		return "", ErrBlindIndexKey
+	}
+	mac := hmac.New(sha256.New, indexKey)
+	mac.Write([]byte(strings.ReplaceAll(domain, "\x00", "")))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
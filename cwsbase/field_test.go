@@ -0,0 +1,93 @@
+package cwsbase
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncryptValue(t *testing.T) {
+	keyring, err := NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := keyring.EncryptValue([]byte("+886912345678"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(ciphertext, "v2.k1.") || strings.Contains(ciphertext, "912345678") {
+		t.Errorf("ciphertext = %q", ciphertext)
+	}
+	plaintext, err := keyring.DecryptValue(ciphertext)
+	if err != nil || string(plaintext) != "+886912345678" {
+		t.Errorf("DecryptValue() = %q, %v", plaintext, err)
+	}
+
+	// a value chosen by a user cannot be passed as token and the other way around
+	forged, err := keyring.EncryptValue([]byte(`{"data": {"admin": true}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyring.DecryptToken(forged); !errors.Is(err, ErrTokenMalformed) {
+		t.Errorf("DecryptToken(value) error = %v", err)
+	}
+	token, err := keyring.EncryptToken(map[string]any{"a": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyring.DecryptValue(token); !errors.Is(err, ErrTokenMalformed) {
+		t.Errorf("DecryptValue(token) error = %v", err)
+	}
+
+	if keyring.NeedsReEncrypt(ciphertext) {
+		t.Error("NeedsReEncrypt() = true for the primary key")
+	}
+	if err := keyring.Rotate("k2", bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if !keyring.NeedsReEncrypt(ciphertext) {
+		t.Error("NeedsReEncrypt() = false after rotation")
+	}
+	if _, err := keyring.DecryptValue(ciphertext); err != nil {
+		t.Errorf("DecryptValue() after rotation error = %v", err)
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	keyring, err := NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyring.BlindIndex("phone", "+886912345678"); !errors.Is(err, ErrBlindIndexKey) {
+		t.Errorf("BlindIndex() without index key error = %v", err)
+	}
+	if err := keyring.SetIndexKey(bytes.Repeat([]byte{9}, 32)); err != nil {
+		t.Fatal(err)
+	}
+
+	index := func(domain, value string) string {
+		i, err := keyring.BlindIndex(domain, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+	phone := index("phone", "+886912345678")
+	if phone != index("phone", "+886912345678") {
+		t.Error("blind index is not deterministic")
+	}
+	if phone == index("national_id", "+886912345678") {
+		t.Error("blind indexes of different domains are equal")
+	}
+	if phone == index("phone", "+886912345679") {
+		t.Error("blind indexes of different values are equal")
+	}
+
+	if err := keyring.Rotate("k2", bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if phone != index("phone", "+886912345678") {
+		t.Error("blind index changed with the rotation of the encryption key")
+	}
+}
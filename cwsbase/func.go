@@ -18,7 +18,6 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -29,11 +28,11 @@ import (
 // EnvironmentInfo holds application environment configuration
 type EnvironmentInfo struct {
 	// Env represents the current environment (e.g., "test", "prod")
-	Env       string
+	Env string `env:"ENV" required:"true"`
 	// DebugMode indicates if debug mode is enabled
-	DebugMode bool
+	DebugMode bool `env:"DEBUG" default:"false"`
 	// IsLocal indicates if the application is running in local development mode
-	IsLocal   bool
+	IsLocal bool `env:"IS_LOCAL" default:"false"`
 }
 
 var matchFirstCap = regexp.MustCompile("(.)([A-Z][a-z]+)")
@@ -48,67 +47,32 @@ func ToSnakeCase(str string) string {
 }
 
 // GetEnvironmentInfo retrieves current environment configuration from environment variables
-// Reads ENV, DEBUG, and IS_LOCAL environment variables
+// Reads ENV, DEBUG, and IS_LOCAL environment variables, the program exits with a fatal error if ENV is missing
 func GetEnvironmentInfo() EnvironmentInfo {
-	return EnvironmentInfo{
-		Env:       GetEnv[string]("ENV"),
-		DebugMode: GetEnv("DEBUG", false),
-		IsLocal:   GetEnv("IS_LOCAL", false),
+	info, err := LoadEnvironmentInfo()
+	if err != nil {
+		log.Fatalln(err)
 	}
+	return info
+}
+
+// LoadEnvironmentInfo loads the environment configuration with LoadConfig, returning the errors instead of exiting
+func LoadEnvironmentInfo(opts ...ConfigOption) (EnvironmentInfo, error) {
+	var info EnvironmentInfo
+	err := LoadConfig(&info, opts...)
+	return info, err
 }
 
 // GetEnv retrieves an environment variable and converts it to the specified type T
-// Supports bool, int, int32, int64, float32, float64, and string types
-// If the environment variable is not set and no default value is provided, the program will exit with a fatal error
+// Supports the types of LookupEnv, e.g. bool, int, int64, float64, string and time.Duration
+// If the environment variable is not set and no default value is provided, or its value is invalid,
+// the program will exit with a fatal error, use LookupEnv or LoadConfig to handle the error
 func GetEnv[T any](key string, defaultVal ...T) T {
-	p := os.Getenv(key)
-	if p == "" {
-		if len(defaultVal) > 0 {
-			return defaultVal[0]
-		}
-		log.Fatalln("Missing required environment variable: " + key)
-	}
-	var t T
-	switch any(t).(type) {
-	case bool:
-		t, err := strconv.ParseBool(p)
-		if err != nil {
-			log.Fatalln("Invalid environment bool variable: " + key)
-		}
-		return any(t).(T)
-	case int:
-		t, err := strconv.ParseInt(p, 10, 32)
-		if err != nil {
-			log.Fatalln("Invalid environment int variable: " + key)
-		}
-		return any(int(t)).(T)
-	case int32:
-		t, err := strconv.ParseInt(p, 10, 32)
-		if err != nil {
-			log.Fatalln("Invalid environment int variable: " + key)
-		}
-		return any(int32(t)).(T)
-	case int64:
-		t, err := strconv.ParseInt(p, 10, 32)
-		if err != nil {
-			log.Fatalln("Invalid environment int variable: " + key)
-		}
-		return any(t).(T)
-	case float32:
-		t, err := strconv.ParseFloat(p, 32)
-		if err != nil {
-			log.Fatalln("Invalid environment float variable: " + key)
-		}
-		return any(float32(t)).(T)
-	case float64:
-		t, err := strconv.ParseFloat(p, 32)
-		if err != nil {
-			log.Fatalln("Invalid environment float variable: " + key)
-		}
-		return any(t).(T)
-	default:
-		return any(p).(T)
+	t, err := LookupEnv(key, defaultVal...)
+	if err != nil {
+		log.Fatalln(err)
 	}
+	return t
 }
 
 // IntToDateTime converts a Unix timestamp to a formatted date-time string
@@ -186,6 +150,8 @@ func ConvertArrayAnyToArrayString(arr []any) []string {
 
 // ReadHttpBody reads and parses an HTTP response body as JSON into a map[string]any
 // Automatically closes the response body when finished
+//
+// Deprecated: use HttpDo or HttpGet to decode into a typed response
 func ReadHttpBody(response http.Response) (map[string]any, error) {
 	defer response.Body.Close()
 	body, err := io.ReadAll(response.Body)
@@ -201,8 +167,13 @@ func ReadHttpBody(response http.Response) (map[string]any, error) {
 	return data, nil
 }
 
+// sharedHttpClient is reused by SendHttpRequestJson for its connection pool and timeout
+var sharedHttpClient = &http.Client{Timeout: 30 * time.Second}
+
 // SendHttpRequestJson sends an HTTP request with JSON body and custom headers
 // The jsonBody is marshaled to JSON and sent as the request body
+//
+// Deprecated: use HttpClient, which adds retries, a circuit breaker and typed errors
 func SendHttpRequestJson(c context.Context, method string, url string, jsonBody map[string]any, header map[string]string) (*http.Response, error) {
 	b, err := json.Marshal(jsonBody)
 	if err != nil {
@@ -219,8 +190,7 @@ func SendHttpRequestJson(c context.Context, method string, url string, jsonBody
 		request.Header.Set(k, v)
 	}
 
-	client := &http.Client{}
-	return client.Do(request)
+	return sharedHttpClient.Do(request)
 }
 
 // StringToCapital capitalizes the first character of a string
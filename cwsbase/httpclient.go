@@ -0,0 +1,444 @@
+package cwsbase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// requestIdHeader and traceParentHeader forward the correlation of the context, see RequestIdFromContext
+	requestIdHeader   = "X-Request-ID"
+	traceParentHeader = "traceparent"
+	// idempotencyKeyHeader marks a POST or PATCH request as safe to retry
+	idempotencyKeyHeader = "Idempotency-Key"
+	// redactedValue replaces the values of redacted headers
+	redactedValue = "[REDACTED]"
+)
+
+// HttpStatusError is returned by HttpClient for responses outside 2xx
+type HttpStatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Error formats the error as "GET https://example.com: 503 Service Unavailable"
+func (e *HttpStatusError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Decode unmarshals the JSON body of the error response into v, e.g. a problem details document
+func (e *HttpStatusError) Decode(v any) error {
+	return json.Unmarshal(e.Body, v)
+}
+
+// IsHttpStatus reports whether err is an HttpStatusError with one of the status codes, any status without codes
+func IsHttpStatus(err error, statusCodes ...int) bool {
+	var statusErr *HttpStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	return len(statusCodes) == 0 || slices.Contains(statusCodes, statusErr.StatusCode)
+}
+
+// HttpRetryPolicy configures the retries of HttpClient
+// Only idempotent methods, and POST or PATCH requests with an Idempotency-Key header, are retried
+type HttpRetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one, 1 or less disables retries
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, it doubles with each retry
+	BaseDelay time.Duration
+	// MaxDelay caps the delay of a retry, including one requested by a Retry-After header
+	MaxDelay time.Duration
+	// RetryStatuses are the response statuses retried, transport errors are always retried
+	RetryStatuses []int
+}
+
+// DefaultHttpRetryPolicy is the retry policy of NewHttpClient
+var DefaultHttpRetryPolicy = HttpRetryPolicy{
+	MaxAttempts:   3,
+	BaseDelay:     100 * time.Millisecond,
+	MaxDelay:      2 * time.Second,
+	RetryStatuses: []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+}
+
+// delay returns the delay before retry n (1 based) with full jitter, or the Retry-After of the response
+func (p HttpRetryPolicy) delay(n int, header http.Header) time.Duration {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return min(time.Duration(seconds)*time.Second, p.MaxDelay)
+	}
+	backoff := p.BaseDelay << (n - 1)
+	if backoff <= 0 || backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return rand.N(backoff + 1)
+}
+
+// HttpEvent describes one attempt of a request for the hooks of HttpClient, the headers are redacted
+type HttpEvent struct {
+	Method         string
+	URL            string
+	Attempt        int
+	RequestHeader  http.Header
+	StatusCode     int
+	ResponseHeader http.Header
+	Duration       time.Duration
+	Err            error
+}
+
+// HttpHook is called after each attempt of a request, e.g. for logging or metrics
+type HttpHook func(ctx context.Context, event HttpEvent)
+
+// LogHttpEvent is an HttpHook logging the attempts with Logger(ctx), failures at warn level
+func LogHttpEvent(ctx context.Context, event HttpEvent) {
+	attrs := []any{
+		"method", event.Method,
+		"url", event.URL,
+		"attempt", event.Attempt,
+		"status", event.StatusCode,
+		"duration", event.Duration,
+		"request_header", event.RequestHeader,
+	}
+	level := slog.LevelInfo
+	if event.Err != nil {
+		attrs = append(attrs, "error", event.Err)
+		level = slog.LevelWarn
+	} else if event.StatusCode >= 500 {
+		level = slog.LevelWarn
+	}
+	Logger(ctx).Log(ctx, level, "outbound http request", attrs...)
+}
+
+// DefaultRedactedHeaders are the headers redacted in HttpEvent and HttpRecording
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Auth-Token"}
+
+// RedactHeader returns a copy of header with the values of the named headers replaced by "[REDACTED]"
+func RedactHeader(header http.Header, names ...string) http.Header {
+	redacted := header.Clone()
+	if redacted == nil {
+		return http.Header{}
+	}
+	for _, name := range names {
+		if _, ok := redacted[http.CanonicalHeaderKey(name)]; ok {
+			redacted.Set(name, redactedValue)
+		}
+	}
+	return redacted
+}
+
+// HttpClientOption configures NewHttpClient
+type HttpClientOption func(*HttpClient)
+
+// WithHttpTimeout sets the timeout of each attempt, 30 seconds by default
+func WithHttpTimeout(timeout time.Duration) HttpClientOption {
+	return func(c *HttpClient) {
+		c.client.Timeout = timeout
+	}
+}
+
+// WithHttpBaseURL resolves relative request URLs against baseURL, e.g. "https://api.example.com/v1"
+func WithHttpBaseURL(baseURL string) HttpClientOption {
+	return func(c *HttpClient) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithHttpHeader sets a header sent with every request, e.g. an API key
+func WithHttpHeader(key string, value string) HttpClientOption {
+	return func(c *HttpClient) {
+		c.header.Set(key, value)
+	}
+}
+
+// WithHttpRetry replaces DefaultHttpRetryPolicy
+func WithHttpRetry(policy HttpRetryPolicy) HttpClientOption {
+	return func(c *HttpClient) {
+		c.retry = policy
+	}
+}
+
+// WithHttpCircuitBreaker replaces DefaultHttpCircuitBreaker, a zero FailureThreshold disables it
+func WithHttpCircuitBreaker(config HttpCircuitBreakerConfig) HttpClientOption {
+	return func(c *HttpClient) {
+		c.breakerConfig = config
+	}
+}
+
+// WithHttpTransport sets the transport, e.g. an HttpRecorder or the client of an httptest.Server
+func WithHttpTransport(transport http.RoundTripper) HttpClientOption {
+	return func(c *HttpClient) {
+		c.client.Transport = transport
+	}
+}
+
+// WithHttpHook adds a hook called after each attempt, e.g. LogHttpEvent
+func WithHttpHook(hook HttpHook) HttpClientOption {
+	return func(c *HttpClient) {
+		c.hooks = append(c.hooks, hook)
+	}
+}
+
+// WithHttpRedactedHeaders adds headers redacted in HttpEvent besides DefaultRedactedHeaders
+func WithHttpRedactedHeaders(names ...string) HttpClientOption {
+	return func(c *HttpClient) {
+		c.redacted = append(c.redacted, names...)
+	}
+}
+
+// HttpClient is an outbound HTTP client with timeouts, retries with exponential backoff and jitter,
+// a circuit breaker per host and typed errors, safe for concurrent use
+// The request id and traceparent of the request context are forwarded, see RequestIdFromContext
+type HttpClient struct {
+	client        *http.Client
+	baseURL       string
+	header        http.Header
+	retry         HttpRetryPolicy
+	breakerConfig HttpCircuitBreakerConfig
+	breakerLock   sync.Mutex
+	breakers      map[string]*circuitBreaker
+	hooks         []HttpHook
+	redacted      []string
+}
+
+// NewHttpClient returns an HttpClient with a 30 second timeout, DefaultHttpRetryPolicy and DefaultHttpCircuitBreaker
+func NewHttpClient(opts ...HttpClientOption) *HttpClient {
+	c := &HttpClient{
+		client:        &http.Client{Timeout: 30 * time.Second},
+		header:        http.Header{},
+		retry:         DefaultHttpRetryPolicy,
+		breakerConfig: DefaultHttpCircuitBreaker,
+		breakers:      map[string]*circuitBreaker{},
+		redacted:      slices.Clone(DefaultRedactedHeaders),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// HttpRequest is a request of HttpClient
+type HttpRequest struct {
+	// Method defaults to GET
+	Method string
+	// URL is absolute or relative to the base URL of the client
+	URL    string
+	Query  url.Values
+	Header http.Header
+	// Body is sent as is if it is []byte, string or io.Reader, and as JSON otherwise; nil sends no body
+	Body any
+}
+
+// HttpResponse is a 2xx response of HttpClient
+type HttpResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Do sends the request, retrying it according to the retry policy
+// Returns *HttpStatusError for responses outside 2xx and an error wrapping ErrHttpCircuitOpen while the host is failing
+func (c *HttpClient) Do(ctx context.Context, req HttpRequest) (*HttpResponse, error) {
+	if ctx == nil {
+		ctx = context.TODO()
+	}
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	target, err := c.resolveURL(req.URL, req.Query)
+	if err != nil {
+		return nil, err
+	}
+	body, contentType, err := encodeHttpBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	retryable := isIdempotentMethod(method) || req.Header.Get(idempotencyKeyHeader) != ""
+	breaker := c.breaker(target.Host)
+
+	for attempt := 1; ; attempt++ {
+		if !breaker.allow() {
+			return nil, fmt.Errorf("%s %s: %w", method, target.Redacted(), ErrHttpCircuitOpen)
+		}
+		resp, err := c.send(ctx, method, target, req.Header, body, contentType, attempt)
+		if ctx.Err() != nil {
+			breaker.release()
+			return nil, ctx.Err()
+		}
+		breaker.record(err == nil && resp.StatusCode < 500)
+
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		var header http.Header
+		if err == nil {
+			header = resp.Header
+			err = &HttpStatusError{Method: method, URL: target.Redacted(), StatusCode: resp.StatusCode, Header: resp.Header, Body: resp.Body}
+		}
+		if !retryable || attempt >= c.retry.MaxAttempts || !c.shouldRetry(err) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.retry.delay(attempt, header)):
+		}
+	}
+}
+
+// send makes one attempt and calls the hooks
+func (c *HttpClient) send(ctx context.Context, method string, target *url.URL, header http.Header, body []byte, contentType string, attempt int) (*HttpResponse, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, target.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range []http.Header{c.header, header} {
+		for key, values := range h {
+			httpReq.Header[http.CanonicalHeaderKey(key)] = slices.Clone(values)
+		}
+	}
+	if contentType != "" && httpReq.Header.Get("Content-Type") == "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if requestId := RequestIdFromContext(ctx); requestId != "" && httpReq.Header.Get(requestIdHeader) == "" {
+		httpReq.Header.Set(requestIdHeader, requestId)
+	}
+	if traceParent := TraceParentFromContext(ctx); traceParent != "" && httpReq.Header.Get(traceParentHeader) == "" {
+		httpReq.Header.Set(traceParentHeader, traceParent)
+	}
+
+	start := time.Now()
+	var resp *HttpResponse
+	httpResp, err := c.client.Do(httpReq)
+	if err == nil {
+		resp = &HttpResponse{StatusCode: httpResp.StatusCode, Header: httpResp.Header}
+		resp.Body, err = io.ReadAll(httpResp.Body)
+		httpResp.Body.Close()
+	}
+
+	if len(c.hooks) > 0 {
+		event := HttpEvent{
+			Method:        method,
+			URL:           target.Redacted(),
+			Attempt:       attempt,
+			RequestHeader: RedactHeader(httpReq.Header, c.redacted...),
+			Duration:      time.Since(start),
+			Err:           err,
+		}
+		if resp != nil {
+			event.StatusCode = resp.StatusCode
+			event.ResponseHeader = RedactHeader(resp.Header, c.redacted...)
+		}
+		for _, hook := range c.hooks {
+			hook(ctx, event)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// shouldRetry reports whether the error of an attempt is worth retrying
+func (c *HttpClient) shouldRetry(err error) bool {
+	var statusErr *HttpStatusError
+	if errors.As(err, &statusErr) {
+		return slices.Contains(c.retry.RetryStatuses, statusErr.StatusCode)
+	}
+	return true
+}
+
+// resolveURL joins a relative URL to the base URL and adds the query
+func (c *HttpClient) resolveURL(rawURL string, query url.Values) (*url.URL, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if !target.IsAbs() && c.baseURL != "" {
+		if target, err = url.Parse(c.baseURL + "/" + strings.TrimPrefix(rawURL, "/")); err != nil {
+			return nil, err
+		}
+	}
+	if len(query) > 0 {
+		values := target.Query()
+		for key, vs := range query {
+			values[key] = append(values[key], vs...)
+		}
+		target.RawQuery = values.Encode()
+	}
+	return target, nil
+}
+
+// encodeHttpBody returns the bytes and default content type of a request body
+func encodeHttpBody(body any) ([]byte, string, error) {
+	switch b := body.(type) {
+	case nil:
+		return nil, "", nil
+	case []byte:
+		return b, "", nil
+	case string:
+		return []byte(b), "", nil
+	case io.Reader:
+		data, err := io.ReadAll(b)
+		return data, "", err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, "application/json", nil
+}
+
+// isIdempotentMethod reports whether requests of method can be sent twice without a different effect
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// HttpDo sends the request with client and decodes the JSON of the response into T, an empty body gives the zero T
+func HttpDo[T any](ctx context.Context, client *HttpClient, req HttpRequest) (T, error) {
+	var t T
+	resp, err := client.Do(ctx, req)
+	if err != nil || len(resp.Body) == 0 {
+		return t, err
+	}
+	if err := json.Unmarshal(resp.Body, &t); err != nil {
+		return t, fmt.Errorf("failed to decode response of %s: %w", req.URL, err)
+	}
+	return t, nil
+}
+
+// HttpGet sends a GET request with client and decodes the JSON response into T
+func HttpGet[T any](ctx context.Context, client *HttpClient, url string) (T, error) {
+	return HttpDo[T](ctx, client, HttpRequest{Method: http.MethodGet, URL: url})
+}
+
+// HttpPost sends body as JSON in a POST request with client and decodes the JSON response into T
+func HttpPost[T any](ctx context.Context, client *HttpClient, url string, body any) (T, error) {
+	return HttpDo[T](ctx, client, HttpRequest{Method: http.MethodPost, URL: url, Body: body})
+}
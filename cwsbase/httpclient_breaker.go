@@ -0,0 +1,117 @@
+package cwsbase
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrHttpCircuitOpen is returned by HttpClient without sending the request while the circuit of the host is open
+var ErrHttpCircuitOpen = errors.New("circuit breaker is open")
+
+// HttpCircuitBreakerConfig configures the circuit breaker kept by HttpClient for each host
+// After FailureThreshold consecutive transport errors or 5xx responses the circuit opens and requests fail fast;
+// after OpenTimeout one request is let through, closing the circuit on success and opening it again on failure
+type HttpCircuitBreakerConfig struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+// DefaultHttpCircuitBreaker is the circuit breaker config of NewHttpClient
+var DefaultHttpCircuitBreaker = HttpCircuitBreakerConfig{
+	FailureThreshold: 5,
+	OpenTimeout:      30 * time.Second,
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker is the breaker of one host, a nil breaker always allows requests
+type circuitBreaker struct {
+	lock     sync.Mutex
+	config   HttpCircuitBreakerConfig
+	state    circuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// CircuitBreaker returns the circuit breaker config of the client, a zero FailureThreshold means disabled
+func (c *HttpClient) CircuitBreaker() HttpCircuitBreakerConfig {
+	return c.breakerConfig
+}
+
+// breaker returns the circuit breaker of host, nil if disabled
+func (c *HttpClient) breaker(host string) *circuitBreaker {
+	if c.breakerConfig.FailureThreshold <= 0 {
+		return nil
+	}
+	c.breakerLock.Lock()
+	defer c.breakerLock.Unlock()
+	b, ok := c.breakers[host]
+	if !ok {
+		b = &circuitBreaker{config: c.breakerConfig}
+		c.breakers[host] = b
+	}
+	return b
+}
+
+// allow reports whether a request may be sent, letting a single probe through once the open timeout passed
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.config.OpenTimeout {
+			return false
+		}
+		b.state = circuitHalfOpen
+		b.probing = true
+		return true
+	case circuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// record counts the outcome of an allowed request
+func (b *circuitBreaker) record(success bool) {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.probing = false
+	if success {
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.config.FailureThreshold {
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// release gives up an allowed request without an outcome, e.g. when its context was canceled
+func (b *circuitBreaker) release() {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.probing = false
+}
@@ -0,0 +1,153 @@
+package cwsbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// HttpRecording is a recorded exchange of an HttpRecorder, the headers are redacted
+type HttpRecording struct {
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestHeader  http.Header `json:"request_header,omitempty"`
+	RequestBody    string      `json:"request_body,omitempty"`
+	StatusCode     int         `json:"status_code"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+	ResponseBody   string      `json:"response_body,omitempty"`
+}
+
+// HttpRecorder is an http.RoundTripper recording exchanges, or replaying them without network in tests
+//
+//	recorder := cwsbase.NewHttpRecorder(nil) // e.g. against an httptest.Server or a sandbox
+//	client := cwsbase.NewHttpClient(cwsbase.WithHttpTransport(recorder))
+//	...
+//	err := recorder.Save("testdata/payments.json")
+//
+//	replayer, err := cwsbase.LoadHttpRecordings("testdata/payments.json")
+//	client = cwsbase.NewHttpClient(cwsbase.WithHttpTransport(replayer))
+type HttpRecorder struct {
+	lock       sync.Mutex
+	transport  http.RoundTripper
+	replay     bool
+	recordings []HttpRecording
+	// next is the index of the next unused recording of each method and URL while replaying
+	next map[string]int
+}
+
+// NewHttpRecorder returns a recorder sending requests with transport, http.DefaultTransport if nil
+func NewHttpRecorder(transport http.RoundTripper) *HttpRecorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &HttpRecorder{transport: transport}
+}
+
+// NewHttpReplayer returns a recorder answering requests with the recordings of the same method and URL in order
+// A request without unused recording fails, so unexpected calls are detected
+func NewHttpReplayer(recordings []HttpRecording) *HttpRecorder {
+	return &HttpRecorder{replay: true, recordings: recordings, next: map[string]int{}}
+}
+
+// LoadHttpRecordings returns a replayer of the recordings saved with HttpRecorder.Save
+func LoadHttpRecordings(filename string) (*HttpRecorder, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var recordings []HttpRecording
+	if err := json.Unmarshal(content, &recordings); err != nil {
+		return nil, fmt.Errorf("failed to parse http recordings %s: %w", filename, err)
+	}
+	return NewHttpReplayer(recordings), nil
+}
+
+// Recordings returns a copy of the recorded exchanges
+func (r *HttpRecorder) Recordings() []HttpRecording {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]HttpRecording(nil), r.recordings...)
+}
+
+// Save writes the recordings as indented JSON
+func (r *HttpRecorder) Save(filename string) error {
+	content, err := json.MarshalIndent(r.Recordings(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, content, 0o644)
+}
+
+// RoundTrip records or replays an exchange
+func (r *HttpRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.replay {
+		return r.replayExchange(req)
+	}
+
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		if requestBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	responseBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.recordings = append(r.recordings, HttpRecording{
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestHeader:  RedactHeader(req.Header, DefaultRedactedHeaders...),
+		RequestBody:    string(requestBody),
+		StatusCode:     resp.StatusCode,
+		ResponseHeader: RedactHeader(resp.Header, DefaultRedactedHeaders...),
+		ResponseBody:   string(responseBody),
+	})
+	return resp, nil
+}
+
+// replayExchange answers req with its next unused recording
+func (r *HttpRecorder) replayExchange(req *http.Request) (*http.Response, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	key := req.Method + " " + req.URL.String()
+	skip := r.next[key]
+	for _, recording := range r.recordings {
+		if recording.Method != req.Method || recording.URL != req.URL.String() {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		r.next[key]++
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recording.StatusCode, http.StatusText(recording.StatusCode)),
+			StatusCode:    recording.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        recording.ResponseHeader.Clone(),
+			Body:          io.NopCloser(bytes.NewBufferString(recording.ResponseBody)),
+			ContentLength: int64(len(recording.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no http recording left for %s", key)
+}
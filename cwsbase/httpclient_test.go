@@ -0,0 +1,182 @@
+package cwsbase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type httpTestUser struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+var httpTestRetry = HttpRetryPolicy{
+	MaxAttempts:   3,
+	BaseDelay:     time.Millisecond,
+	MaxDelay:      5 * time.Millisecond,
+	RetryStatuses: DefaultHttpRetryPolicy.RetryStatuses,
+}
+
+func TestHttpClientDecode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "key" || r.Header.Get(requestIdHeader) != "req-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v1/users/1":
+			json.NewEncoder(w).Encode(httpTestUser{Id: "1", Name: r.URL.Query().Get("name")})
+		case "POST /v1/users":
+			var user httpTestUser
+			json.NewDecoder(r.Body).Decode(&user)
+			user.Id = "2"
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(user)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"title": "not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	var events []HttpEvent
+	client := NewHttpClient(
+		WithHttpBaseURL(server.URL+"/v1/"),
+		WithHttpHeader("X-Api-Key", "key"),
+		WithHttpHook(func(ctx context.Context, event HttpEvent) { events = append(events, event) }),
+	)
+	ctx := ContextWithRequestId(context.Background(), "req-1")
+
+	user, err := HttpDo[httpTestUser](ctx, client, HttpRequest{URL: "/users/1", Query: map[string][]string{"name": {"Ann"}}})
+	if err != nil || user != (httpTestUser{Id: "1", Name: "Ann"}) {
+		t.Errorf("HttpDo() = %+v, %v", user, err)
+	}
+	created, err := HttpPost[httpTestUser](ctx, client, "users", httpTestUser{Name: "Bob"})
+	if err != nil || created != (httpTestUser{Id: "2", Name: "Bob"}) {
+		t.Errorf("HttpPost() = %+v, %v", created, err)
+	}
+
+	_, err = HttpGet[httpTestUser](ctx, client, "missing")
+	var statusErr *HttpStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound || !IsHttpStatus(err, http.StatusNotFound) {
+		t.Fatalf("HttpGet() of a missing path error = %v", err)
+	}
+	var problem struct{ Title string }
+	if err := statusErr.Decode(&problem); err != nil || problem.Title != "not found" {
+		t.Errorf("Decode() = %+v, %v", problem, err)
+	}
+
+	if len(events) != 3 || events[0].StatusCode != http.StatusOK || events[0].RequestHeader.Get("X-Api-Key") != redactedValue {
+		t.Errorf("events = %+v", events)
+	}
+}
+
+func TestHttpClientRetry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id": "1"}`))
+	}))
+	defer server.Close()
+	client := NewHttpClient(WithHttpRetry(httpTestRetry))
+
+	tests := []struct {
+		name    string
+		req     HttpRequest
+		wantErr bool
+		calls   int32
+	}{
+		{"idempotent get", HttpRequest{URL: server.URL}, false, 3},
+		{"post", HttpRequest{Method: http.MethodPost, URL: server.URL, Body: map[string]any{}}, true, 1},
+		{"post with idempotency key", HttpRequest{Method: http.MethodPost, URL: server.URL, Header: http.Header{"Idempotency-Key": {"k1"}}}, false, 3},
+	}
+	for _, test := range tests {
+		calls.Store(0)
+		_, err := client.Do(context.Background(), test.req)
+		if (err != nil) != test.wantErr || calls.Load() != test.calls {
+			t.Errorf("%s: error = %v, calls = %d, want %d", test.name, err, calls.Load(), test.calls)
+		}
+	}
+}
+
+func TestHttpClientCircuitBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	client := NewHttpClient(
+		WithHttpRetry(HttpRetryPolicy{MaxAttempts: 1}),
+		WithHttpCircuitBreaker(HttpCircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: 20 * time.Millisecond}),
+	)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Do(context.Background(), HttpRequest{URL: server.URL}); !IsHttpStatus(err, http.StatusInternalServerError) {
+			t.Fatalf("request %d error = %v", i, err)
+		}
+	}
+	if _, err := client.Do(context.Background(), HttpRequest{URL: server.URL}); !errors.Is(err, ErrHttpCircuitOpen) {
+		t.Errorf("request to an open circuit error = %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2", calls.Load())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	healthy.Store(true)
+	if _, err := client.Do(context.Background(), HttpRequest{URL: server.URL}); err != nil {
+		t.Errorf("probe request error = %v", err)
+	}
+	if _, err := client.Do(context.Background(), HttpRequest{URL: server.URL}); err != nil {
+		t.Errorf("request after the circuit closed error = %v", err)
+	}
+}
+
+func TestHttpRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Write([]byte(`{"id": "1", "name": "Ann"}`))
+	}))
+	recorder := NewHttpRecorder(nil)
+	client := NewHttpClient(WithHttpTransport(recorder), WithHttpHeader("Authorization", "Bearer token"))
+	if _, err := HttpGet[httpTestUser](context.Background(), client, server.URL+"/users/1"); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	filename := filepath.Join(t.TempDir(), "recordings.json")
+	if err := recorder.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	recordings := recorder.Recordings()
+	if len(recordings) != 1 || recordings[0].RequestHeader.Get("Authorization") != redactedValue || recordings[0].ResponseHeader.Get("Set-Cookie") != redactedValue {
+		t.Errorf("recordings = %+v", recordings)
+	}
+
+	replayer, err := LoadHttpRecordings(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = NewHttpClient(WithHttpTransport(replayer), WithHttpRetry(HttpRetryPolicy{MaxAttempts: 1}))
+	user, err := HttpGet[httpTestUser](context.Background(), client, server.URL+"/users/1")
+	if err != nil || user.Name != "Ann" {
+		t.Errorf("replayed HttpGet() = %+v, %v", user, err)
+	}
+	if _, err := HttpGet[httpTestUser](context.Background(), client, server.URL+"/users/1"); err == nil {
+		t.Error("request without recording left succeeded")
+	}
+}
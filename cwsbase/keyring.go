@@ -0,0 +1,298 @@
+package cwsbase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrKeyringPrimary is returned when the primary key of a keyring is missing or would be removed
+var ErrKeyringPrimary = errors.New("keyring primary key not set")
+
+// ErrBlindIndexKey is returned by BlindIndex when the keyring has no index key
+var ErrBlindIndexKey = errors.New("keyring index key not set")
+
+// Keyring holds the AES keys of the token format by key id
+// New tokens are encrypted with the primary key, tokens of every key in the keyring can be decrypted,
+// so a key is rotated by adding a new primary key and keeping the older keys until their tokens are gone
+// The index key of blind indexes is kept apart, it is not rotated since the indexes of stored values depend on it
+type Keyring struct {
+	lock     sync.RWMutex
+	primary  string
+	keys     map[string][]byte
+	indexKey []byte
+}
+
+// KeyringConfig is the stored form of a keyring, keys are hex encoded, e.g. in JSON:
+//
+//	{"primary": "2024-07", "keys": {"2024-07": "<64 hex characters>", "2024-01": "<64 hex characters>"}}
+type KeyringConfig struct {
+	// Primary is the id of the key encrypting new tokens
+	Primary string `json:"primary" yaml:"primary" toml:"primary"`
+	// Keys are the keys by id
+	Keys map[string]string `json:"keys" yaml:"keys" toml:"keys"`
+	// IndexKey is the optional key of blind indexes, see BlindIndex
+	IndexKey string `json:"index_key,omitempty" yaml:"index_key,omitempty" toml:"index_key,omitempty"`
+}
+
+// NewKeyring returns a keyring with the keys by id, primaryId must be one of them
+// Keys must be 16, 24 or 32 bytes long for AES-128, AES-192 or AES-256
+func NewKeyring(primaryId string, keys map[string][]byte) (*Keyring, error) {
+	k := &Keyring{keys: map[string][]byte{}}
+	for id, key := range keys {
+		if err := k.AddKey(id, key); err != nil {
+			return nil, err
+		}
+	}
+	if err := k.SetPrimary(primaryId); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Primary returns the id and the key encrypting new tokens
+func (k *Keyring) Primary() (string, []byte) {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return k.primary, k.keys[k.primary]
+}
+
+// PrimaryId returns the id of the key encrypting new tokens
+func (k *Keyring) PrimaryId() string {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return k.primary
+}
+
+// Key returns the key with the id
+func (k *Keyring) Key(id string) ([]byte, bool) {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	key, ok := k.keys[id]
+	return key, ok
+}
+
+// Ids returns the sorted ids of all keys
+func (k *Keyring) Ids() []string {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// AddKey adds a key for decryption, the id must not contain "."
+func (k *Keyring) AddKey(id string, key []byte) error {
+	if id == "" || strings.Contains(id, ".") {
+		return fmt.Errorf("invalid key id %q", id)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return fmt.Errorf("invalid length %d of key %q, want 16, 24 or 32 bytes", len(key), id)
+	}
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if k.keys == nil {
+		k.keys = map[string][]byte{}
+	}
+	k.keys[id] = append([]byte{}, key...)
+	return nil
+}
+
+// SetPrimary makes the key with the id encrypt new tokens
+func (k *Keyring) SetPrimary(id string) error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("%w: no key %q", ErrKeyringPrimary, id)
+	}
+	k.primary = id
+	return nil
+}
+
+// Rotate adds a key and makes it the primary key, the former primary key stays for decryption
+func (k *Keyring) Rotate(id string, key []byte) error {
+	if err := k.AddKey(id, key); err != nil {
+		return err
+	}
+	return k.SetPrimary(id)
+}
+
+// RemoveKey removes a retired key, tokens of the key can no longer be decrypted
+func (k *Keyring) RemoveKey(id string) error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if id == k.primary {
+		return fmt.Errorf("%w: cannot remove primary key %q", ErrKeyringPrimary, id)
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+// SetIndexKey sets the key of blind indexes, it must be at least 16 bytes long
+func (k *Keyring) SetIndexKey(key []byte) error {
+	if len(key) < 16 {
+		return fmt.Errorf("invalid length %d of index key, want at least 16 bytes", len(key))
+	}
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.indexKey = append([]byte{}, key...)
+	return nil
+}
+
+// Keyring decodes the hex keys into a keyring
+func (c KeyringConfig) Keyring() (*Keyring, error) {
+	keys := map[string][]byte{}
+	for id, value := range c.Keys {
+		key, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex of key %q: %w", id, err)
+		}
+		keys[id] = key
+	}
+	k, err := NewKeyring(c.Primary, keys)
+	if err != nil {
+		return nil, err
+	}
+	if c.IndexKey != "" {
+		indexKey, err := hex.DecodeString(c.IndexKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex of index key: %w", err)
+		}
+		if err := k.SetIndexKey(indexKey); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// ParseKeyringConfig decodes a keyring config in JSON, YAML or TOML format, the format is chosen by the extension of name
+func ParseKeyringConfig(name string, content []byte) (KeyringConfig, error) {
+	var config KeyringConfig
+	var err error
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		err = json.Unmarshal(content, &config)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &config)
+	case ".toml":
+		err = toml.Unmarshal(content, &config)
+	default:
+		return KeyringConfig{}, fmt.Errorf("unsupported keyring file format: %s", name)
+	}
+	if err != nil {
+		return KeyringConfig{}, fmt.Errorf("failed to parse keyring file %s: %w", name, err)
+	}
+	return config, nil
+}
+
+// LoadKeyringFile reads a keyring config file in JSON, YAML or TOML format, see KeyringConfig
+func LoadKeyringFile(filename string) (*Keyring, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config, err := ParseKeyringConfig(filename, content)
+	if err != nil {
+		return nil, err
+	}
+	return config.Keyring()
+}
+
+// LoadKeyringFromEnv builds a keyring from the environment
+// CRYPTO_KEY_HEX is the primary key with the id CRYPTO_KEY_ID, or an id derived from the key when it is not set
+// CRYPTO_PREVIOUS_KEYS lists older keys for decryption as comma separated "id:hex" pairs
+// CRYPTO_INDEX_KEY_HEX is the optional key of blind indexes
+func LoadKeyringFromEnv() (*Keyring, error) {
+	return keyringFromEnv(keyringEnvValues())
+}
+
+// keyringEnvValues returns the environment variables of the keyring
+func keyringEnvValues() [4]string {
+	return [4]string{os.Getenv("CRYPTO_KEY_HEX"), os.Getenv("CRYPTO_KEY_ID"), os.Getenv("CRYPTO_PREVIOUS_KEYS"), os.Getenv("CRYPTO_INDEX_KEY_HEX")}
+}
+
+// keyringFromEnv builds the keyring of the environment values
+func keyringFromEnv(values [4]string) (*Keyring, error) {
+	keyHex, keyId, previousKeys := values[0], values[1], values[2]
+	if keyHex == "" {
+		return nil, fmt.Errorf("%w: missing environment variable CRYPTO_KEY_HEX", ErrKeyringPrimary)
+	}
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CRYPTO_KEY_HEX: %w", err)
+	}
+	if keyId == "" {
+		keyId = keyFingerprint(key)
+	}
+	config := KeyringConfig{Primary: keyId, Keys: map[string]string{keyId: keyHex}, IndexKey: values[3]}
+	for _, pair := range strings.Split(previousKeys, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		id, value, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid CRYPTO_PREVIOUS_KEYS entry, want id:hex")
+		}
+		if id != keyId {
+			config.Keys[id] = value
+		}
+	}
+	return config.Keyring()
+}
+
+// keyFingerprint derives a short key id from a key, the key cannot be recovered from it
+func keyFingerprint(key []byte) string {
+	sum := sha256.Sum256(append([]byte("cwsutil key id "), key...))
+	return hex.EncodeToString(sum[:4])
+}
+
+var keyringLock sync.Mutex
+var keyring *Keyring
+
+// envKeyring caches the keyring of the environment until the variables change
+var envKeyring struct {
+	values  [4]string
+	keyring *Keyring
+}
+
+// SetKeyring sets the keyring of EncryptMap, DecryptToMap and the token functions, nil restores the environment keyring
+// e.g. keyring, err := cwsbase.LoadKeyringFile("keys.yaml"); cwsbase.SetKeyring(keyring)
+func SetKeyring(k *Keyring) {
+	keyringLock.Lock()
+	defer keyringLock.Unlock()
+	keyring = k
+}
+
+// GetKeyring returns the keyring set with SetKeyring, by default the keyring of LoadKeyringFromEnv
+func GetKeyring() (*Keyring, error) {
+	keyringLock.Lock()
+	defer keyringLock.Unlock()
+	if keyring != nil {
+		return keyring, nil
+	}
+	values := keyringEnvValues()
+	if envKeyring.keyring == nil || envKeyring.values != values {
+		k, err := keyringFromEnv(values)
+		if err != nil {
+			return nil, err
+		}
+		envKeyring.values = values
+		envKeyring.keyring = k
+	}
+	return envKeyring.keyring, nil
+}
@@ -0,0 +1,154 @@
+package cwsbase
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyringRotation(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+	keyring, err := NewKeyring("k1", map[string][]byte{"k1": oldKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := keyring.EncryptToken(map[string]any{"a": "b"}, WithTokenAudience("session"), WithTokenTTL(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keyring.Rotate("k2", newKey); err != nil {
+		t.Fatal(err)
+	}
+	newToken, err := keyring.EncryptToken(map[string]any{"a": "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(newToken, "v2.k2.") {
+		t.Errorf("new token header = %q", newToken)
+	}
+	if data, err := keyring.DecryptToken(oldToken, "session"); err != nil || data["a"] != "b" {
+		t.Errorf("DecryptToken(old) = %v, %v", data, err)
+	}
+
+	reEncrypted, changed, err := keyring.ReEncryptToken(oldToken)
+	if err != nil || !changed || !strings.HasPrefix(reEncrypted, "v2.k2.") {
+		t.Fatalf("ReEncryptToken(old) = %q, %v, %v", reEncrypted, changed, err)
+	}
+	if _, err := keyring.DecryptToken(reEncrypted); !errors.Is(err, ErrTokenAudience) {
+		t.Errorf("re-encrypted token lost its audience: %v", err)
+	}
+	if same, changed, err := keyring.ReEncryptToken(newToken); err != nil || changed || same != newToken {
+		t.Errorf("ReEncryptToken(new) = %q, %v, %v", same, changed, err)
+	}
+
+	if err := keyring.RemoveKey("k2"); !errors.Is(err, ErrKeyringPrimary) {
+		t.Errorf("RemoveKey(primary) error = %v", err)
+	}
+	if err := keyring.RemoveKey("k1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keyring.DecryptToken(oldToken, "session"); !errors.Is(err, ErrTokenKeyNotFound) {
+		t.Errorf("DecryptToken(removed key) error = %v", err)
+	}
+	if _, err := keyring.DecryptToken(reEncrypted, "session"); err != nil {
+		t.Errorf("DecryptToken(re-encrypted) error = %v", err)
+	}
+}
+
+func TestNewKeyringErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		primary string
+		keys    map[string][]byte
+	}{
+		{name: "short key", primary: "k1", keys: map[string][]byte{"k1": []byte("short")}},
+		{name: "missing primary", primary: "k2", keys: map[string][]byte{"k1": bytes.Repeat([]byte{1}, 16)}},
+		{name: "dotted id", primary: "k.1", keys: map[string][]byte{"k.1": bytes.Repeat([]byte{1}, 16)}},
+	}
+	for _, tt := range tests {
+		if _, err := NewKeyring(tt.primary, tt.keys); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	k1 := strings.Repeat("01", 32)
+	k2 := strings.Repeat("02", 32)
+	dir := t.TempDir()
+	files := map[string]string{
+		"keys.json": `{"primary": "k2", "keys": {"k1": "` + k1 + `", "k2": "` + k2 + `"}}`,
+		"keys.yaml": "primary: k2\nkeys:\n  k1: \"" + k1 + "\"\n  k2: \"" + k2 + "\"\n",
+		"keys.toml": "primary = \"k2\"\n[keys]\nk1 = \"" + k1 + "\"\nk2 = \"" + k2 + "\"\n",
+	}
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		keyring, err := LoadKeyringFile(filename)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if keyring.PrimaryId() != "k2" || len(keyring.Ids()) != 2 {
+			t.Errorf("%s: primary %q, ids %v", name, keyring.PrimaryId(), keyring.Ids())
+		}
+	}
+
+	t.Setenv("CRYPTO_KEY_HEX", k2)
+	t.Setenv("CRYPTO_KEY_ID", "k2")
+	t.Setenv("CRYPTO_PREVIOUS_KEYS", "k1:"+k1+", k0:"+strings.Repeat("00", 16))
+	keyring, err := LoadKeyringFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := strings.Join(keyring.Ids(), ","); keyring.PrimaryId() != "k2" || ids != "k0,k1,k2" {
+		t.Errorf("primary %q, ids %v", keyring.PrimaryId(), ids)
+	}
+}
+
+func TestSetKeyring(t *testing.T) {
+	setTokenKey(t)
+	data, _ := json.Marshal(map[string]any{"a": "b"})
+	legacy, err := aESCBCPKCS5PaddingEncrypt(data, aes.BlockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envToken, err := EncryptMap(map[string]any{"a": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envKeyring, err := GetKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary, key := envKeyring.Primary()
+	keyring, err := NewKeyring(primary, map[string][]byte{primary: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keyring.Rotate("next", bytes.Repeat([]byte{3}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	SetKeyring(keyring)
+	defer SetKeyring(nil)
+
+	for _, token := range []string{legacy, envToken} {
+		reEncrypted, changed, err := ReEncryptToken(token)
+		if err != nil || !changed || !strings.HasPrefix(reEncrypted, "v2.next.") {
+			t.Errorf("ReEncryptToken() = %q, %v, %v", reEncrypted, changed, err)
+		}
+		if output, err := DecryptToMap(reEncrypted); err != nil || output["a"] != "b" {
+			t.Errorf("DecryptToMap() = %v, %v", output, err)
+		}
+	}
+}
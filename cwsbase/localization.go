@@ -11,8 +11,11 @@
 package cwsbase
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -31,21 +34,33 @@ const (
 	Chinese   LocalizationLanguage = "zh_cn"
 )
 
-var localInitLock sync.Mutex
+var localInitLock sync.RWMutex
 var localmap map[LocalizationLanguage]map[LocalizationCode]string = map[LocalizationLanguage]map[LocalizationCode]string{}
 
+// localFallback is the ordered chain of languages tried when a code is missing in the requested language
+var localFallback []LocalizationLanguage
+
+// localizationLanguageKey is the context key for the request scoped localization language
+type localizationLanguageKey struct{}
+
 // UpdateLocalizationData updates the global localization map with new data from JSON
 // This function is thread-safe and merges new data with existing localization data
 // The JSON structure should be: {"language": {"code": "message"}}
 func UpdateLocalizationData(jsonData []byte) error {
-	localInitLock.Lock()
-	defer localInitLock.Unlock()
-
 	var data map[LocalizationLanguage]map[LocalizationCode]string
 	if err := json.Unmarshal(jsonData, &data); err != nil {
 		return err
 	}
 
+	mergeLocalizationData(data)
+	return nil
+}
+
+// mergeLocalizationData merges parsed localization data into the global localization map
+func mergeLocalizationData(data map[LocalizationLanguage]map[LocalizationCode]string) {
+	localInitLock.Lock()
+	defer localInitLock.Unlock()
+
 	for k, v := range data {
 		if val, ok := localmap[k]; ok {
 			for k2, v2 := range v {
@@ -55,21 +70,145 @@ func UpdateLocalizationData(jsonData []byte) error {
 			localmap[k] = v
 		}
 	}
-	return nil
+}
+
+// SetLocalizationFallback sets the ordered chain of languages tried when a code does not exist in the requested language
+// Example: SetLocalizationFallback(Taiwanese, Chinese, English) makes zh_tw fall back to zh_cn and then en
+// The default language from LOCALIZATION_LANGUAGE is always tried last
+func SetLocalizationFallback(languages ...LocalizationLanguage) {
+	localInitLock.Lock()
+	defer localInitLock.Unlock()
+	localFallback = languages
+}
+
+// GetLocalizationLanguages returns all languages that currently have localization data, sorted by name
+func GetLocalizationLanguages() []LocalizationLanguage {
+	localInitLock.RLock()
+	defer localInitLock.RUnlock()
+
+	languages := make([]LocalizationLanguage, 0, len(localmap))
+	for lang := range localmap {
+		languages = append(languages, lang)
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		return languages[i] < languages[j]
+	})
+	return languages
+}
+
+// GetDefaultLocalizationLanguage returns the process wide language from the LOCALIZATION_LANGUAGE environment variable (defaults to "en")
+func GetDefaultLocalizationLanguage() LocalizationLanguage {
+	return LocalizationLanguage(GetEnv("LOCALIZATION_LANGUAGE", "en"))
+}
+
+// NormalizeLocalizationLanguage converts a BCP 47 language tag such as "zh-TW", "zh-Hant" or "en-US" into a LocalizationLanguage
+// Traditional Chinese regions and scripts map to zh_tw, Simplified Chinese ones to zh_cn
+// Tags of other languages keep their full form if it has localization data, otherwise only the primary subtag is kept
+func NormalizeLocalizationLanguage(tag string) LocalizationLanguage {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "-", "_"))
+	if tag == "" {
+		return ""
+	}
+
+	parts := strings.Split(tag, "_")
+	if parts[0] == "zh" {
+		for _, p := range parts[1:] {
+			switch p {
+			case "hant", "tw", "hk", "mo":
+				return Taiwanese
+			case "hans", "cn", "sg":
+				return Chinese
+			}
+		}
+		return Chinese
+	}
+
+	localInitLock.RLock()
+	_, ok := localmap[LocalizationLanguage(tag)]
+	localInitLock.RUnlock()
+	if ok {
+		return LocalizationLanguage(tag)
+	}
+	return LocalizationLanguage(parts[0])
+}
+
+// ContextWithLocalizationLanguage returns a copy of ctx that carries the given localization language
+// Messages resolved with GetLocalizationMessageWithContext use this language instead of LOCALIZATION_LANGUAGE
+func ContextWithLocalizationLanguage(ctx context.Context, lang LocalizationLanguage) context.Context {
+	return context.WithValue(ctx, localizationLanguageKey{}, lang)
+}
+
+// LocalizationLanguageFromContext returns the localization language stored in ctx
+// Falls back to the default language when ctx is nil or carries no language
+func LocalizationLanguageFromContext(ctx context.Context) LocalizationLanguage {
+	if ctx != nil {
+		if lang, ok := ctx.Value(localizationLanguageKey{}).(LocalizationLanguage); ok && lang != "" {
+			return lang
+		}
+	}
+	return GetDefaultLocalizationLanguage()
+}
+
+// LookupLocalizationMessage returns the raw message of a code in exactly the given language without any fallback
+func LookupLocalizationMessage(lang LocalizationLanguage, code LocalizationCode) (string, bool) {
+	localInitLock.RLock()
+	defer localInitLock.RUnlock()
+
+	if langMap, ok := localmap[lang]; ok {
+		message, ok := langMap[code]
+		return message, ok
+	}
+	return "", false
+}
+
+// localizationChain returns the requested language followed by the fallback chain and the default language without duplicates
+func localizationChain(lang LocalizationLanguage) []LocalizationLanguage {
+	localInitLock.RLock()
+	chain := append([]LocalizationLanguage{lang}, localFallback...)
+	localInitLock.RUnlock()
+	chain = append(chain, GetDefaultLocalizationLanguage())
+
+	result := make([]LocalizationLanguage, 0, len(chain))
+	for _, l := range chain {
+		found := false
+		for _, r := range result {
+			if r == l {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, l)
+		}
+	}
+	return result
 }
 
 // GetLocalizationMessage retrieves a localized message by code and language
 // The language is determined by the LOCALIZATION_LANGUAGE environment variable (defaults to "en")
 // If additional string parameters are provided, they are formatted into the message using sprintf
+// A single map[string]any parameter fills ICU style named placeholders instead, see FormatLocalizationMessage
 func GetLocalizationMessage(code LocalizationCode, strs ...any) string {
-	lang := GetEnv("LOCALIZATION_LANGUAGE", "en")
+	return GetLocalizationMessageByLanguage(GetDefaultLocalizationLanguage(), code, strs...)
+}
 
-	if langMap, ok := localmap[LocalizationLanguage(lang)]; ok {
-		if message, ok := langMap[LocalizationCode(code)]; ok {
+// GetLocalizationMessageWithContext retrieves a localized message in the language carried by ctx
+// See ContextWithLocalizationLanguage for attaching a language to a context
+func GetLocalizationMessageWithContext(ctx context.Context, code LocalizationCode, strs ...any) string {
+	return GetLocalizationMessageByLanguage(LocalizationLanguageFromContext(ctx), code, strs...)
+}
+
+// GetLocalizationMessageByLanguage retrieves a localized message by code in the given language
+// If the code does not exist in that language, the fallback chain and then the default language are tried
+// If additional string parameters are provided, they are formatted into the message using sprintf
+// A single map[string]any parameter fills ICU style named placeholders instead, see FormatLocalizationMessage
+func GetLocalizationMessageByLanguage(lang LocalizationLanguage, code LocalizationCode, strs ...any) string {
+	for _, l := range localizationChain(lang) {
+		if message, ok := LookupLocalizationMessage(l, code); ok {
 			if len(strs) == 0 {
 				return message
 			}
-			return fmt.Sprintf(message, strs...)
+			return formatLocalizationValues(l, message, strs)
 		}
 	}
 
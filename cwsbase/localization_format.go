@@ -0,0 +1,447 @@
+package cwsbase
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PluralRule returns the CLDR plural category ("zero", "one", "two", "few", "many" or "other") of a number
+// ordinal selects the ordinal rules used by selectordinal instead of the cardinal rules used by plural
+type PluralRule func(n float64, ordinal bool) string
+
+var pluralRuleLock sync.RWMutex
+
+// pluralRules holds the plural rules of the supported languages, languages without a rule use the English one
+var pluralRules = map[LocalizationLanguage]PluralRule{
+	English:   englishPluralRule,
+	Taiwanese: chinesePluralRule,
+	Chinese:   chinesePluralRule,
+}
+
+// englishPluralRule implements the CLDR cardinal and ordinal rules of English
+func englishPluralRule(n float64, ordinal bool) string {
+	if n != math.Trunc(n) {
+		return "other"
+	}
+	if !ordinal {
+		if n == 1 {
+			return "one"
+		}
+		return "other"
+	}
+	i := int64(math.Abs(n))
+	switch {
+	case i%10 == 1 && i%100 != 11:
+		return "one"
+	case i%10 == 2 && i%100 != 12:
+		return "two"
+	case i%10 == 3 && i%100 != 13:
+		return "few"
+	}
+	return "other"
+}
+
+// chinesePluralRule implements the CLDR rules of Chinese which has no plural forms
+func chinesePluralRule(n float64, ordinal bool) string {
+	return "other"
+}
+
+// SetPluralRule registers the plural rule of a language used by plural and selectordinal arguments
+func SetPluralRule(lang LocalizationLanguage, rule PluralRule) {
+	pluralRuleLock.Lock()
+	defer pluralRuleLock.Unlock()
+	pluralRules[lang] = rule
+}
+
+// getPluralRule returns the plural rule of a language, defaulting to the English rule
+func getPluralRule(lang LocalizationLanguage) PluralRule {
+	pluralRuleLock.RLock()
+	defer pluralRuleLock.RUnlock()
+	if rule, ok := pluralRules[lang]; ok {
+		return rule
+	}
+	return englishPluralRule
+}
+
+// messagePart is a node of a parsed ICU style message
+type messagePart struct {
+	// text is the literal text of a text part
+	text string
+	// arg is the argument name, empty for text and pound parts
+	arg string
+	// kind is the argument type: "", "number", "plural", "selectordinal" or "select"
+	kind string
+	// pound marks a '#' placeholder inside a plural branch
+	pound bool
+	// offset is the plural offset subtracted before choosing a category
+	offset float64
+	// branches maps selectors ("one", "=0", "male", "other"...) to sub messages
+	branches map[string][]messagePart
+}
+
+// messageParser parses ICU MessageFormat style messages
+type messageParser struct {
+	src []rune
+	pos int
+}
+
+// parseICUMessage parses a message with {name} placeholders, plural, selectordinal and select arguments
+func parseICUMessage(message string) ([]messagePart, error) {
+	p := &messageParser{src: []rune(message)}
+	parts, err := p.parseMessage(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected '}' at position %d", p.pos)
+	}
+	return parts, nil
+}
+
+// parseMessage parses text and arguments until an unmatched '}' or the end of input
+func (p *messageParser) parseMessage(inPlural bool) ([]messagePart, error) {
+	var parts []messagePart
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			parts = append(parts, messagePart{text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '\'':
+			p.parseQuoted(&text, inPlural)
+		case c == '{':
+			flush()
+			p.pos++
+			part, err := p.parseArgument(inPlural)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
+		case c == '}':
+			flush()
+			return parts, nil
+		case c == '#' && inPlural:
+			flush()
+			parts = append(parts, messagePart{pound: true})
+			p.pos++
+		default:
+			text.WriteRune(c)
+			p.pos++
+		}
+	}
+	flush()
+	return parts, nil
+}
+
+// parseQuoted handles apostrophes: a doubled apostrophe is a literal one and an apostrophe before { or } quotes up to the next apostrophe
+func (p *messageParser) parseQuoted(text *strings.Builder, inPlural bool) {
+	p.pos++
+	if p.pos >= len(p.src) {
+		text.WriteRune('\'')
+		return
+	}
+	next := p.src[p.pos]
+	if next == '\'' {
+		text.WriteRune('\'')
+		p.pos++
+		return
+	}
+	if next != '{' && next != '}' && !(next == '#' && inPlural) {
+		text.WriteRune('\'')
+		return
+	}
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		if c == '\'' {
+			if p.pos < len(p.src) && p.src[p.pos] == '\'' {
+				text.WriteRune('\'')
+				p.pos++
+				continue
+			}
+			return
+		}
+		text.WriteRune(c)
+	}
+}
+
+// skipSpaces advances past white space
+func (p *messageParser) skipSpaces() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n' || p.src[p.pos] == '\r') {
+		p.pos++
+	}
+}
+
+// readToken reads characters until one of the stop characters or white space
+func (p *messageParser) readToken(stops string) string {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(stops, p.src[p.pos]) && p.src[p.pos] != ' ' && p.src[p.pos] != '\t' && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+		p.pos++
+	}
+	token := string(p.src[start:p.pos])
+	p.skipSpaces()
+	return token
+}
+
+// expect consumes the given character or returns an error
+func (p *messageParser) expect(c rune) error {
+	p.skipSpaces()
+	if p.pos >= len(p.src) || p.src[p.pos] != c {
+		return fmt.Errorf("expected '%c' at position %d", c, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+// parseArgument parses an argument after its opening '{' up to and including the closing '}'
+func (p *messageParser) parseArgument(inPlural bool) (messagePart, error) {
+	part := messagePart{arg: p.readToken(",}")}
+	if part.arg == "" {
+		return part, fmt.Errorf("missing argument name at position %d", p.pos)
+	}
+	if p.pos < len(p.src) && p.src[p.pos] == '}' {
+		p.pos++
+		return part, nil
+	}
+	if err := p.expect(','); err != nil {
+		return part, err
+	}
+
+	part.kind = p.readToken(",}")
+	switch part.kind {
+	case "number":
+		// number styles are accepted but formatted with the default style
+		for p.pos < len(p.src) && p.src[p.pos] != '}' {
+			p.pos++
+		}
+		return part, p.expect('}')
+	case "plural", "selectordinal", "select":
+	default:
+		return part, fmt.Errorf("unsupported argument type %q of argument %s", part.kind, part.arg)
+	}
+	if err := p.expect(','); err != nil {
+		return part, err
+	}
+
+	part.branches = map[string][]messagePart{}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.src) {
+			return part, fmt.Errorf("unterminated argument %s", part.arg)
+		}
+		if p.src[p.pos] == '}' {
+			p.pos++
+			break
+		}
+		selector := p.readToken("{}")
+		if part.kind != "select" && strings.HasPrefix(selector, "offset:") {
+			offset, err := strconv.ParseFloat(strings.TrimPrefix(selector, "offset:"), 64)
+			if err != nil {
+				return part, fmt.Errorf("invalid offset of argument %s: %w", part.arg, err)
+			}
+			part.offset = offset
+			continue
+		}
+		if selector == "" {
+			return part, fmt.Errorf("missing selector of argument %s at position %d", part.arg, p.pos)
+		}
+		if err := p.expect('{'); err != nil {
+			return part, err
+		}
+		branch, err := p.parseMessage(inPlural || part.kind != "select")
+		if err != nil {
+			return part, err
+		}
+		if err := p.expect('}'); err != nil {
+			return part, err
+		}
+		part.branches[selector] = branch
+	}
+	if _, ok := part.branches["other"]; !ok {
+		return part, fmt.Errorf("argument %s has no other branch", part.arg)
+	}
+	return part, nil
+}
+
+// toNumber converts numeric values and numeric strings to float64
+func toNumber(value any) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		n, err := strconv.ParseFloat(v.String(), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// formatNumber formats a number without exponent and without trailing zeros
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// formatParts renders parsed message parts with the given arguments
+// pound is the number rendered for '#' in the innermost plural branch
+func formatParts(lang LocalizationLanguage, parts []messagePart, values map[string]any, pound *float64, out *strings.Builder) {
+	for _, part := range parts {
+		if part.pound {
+			if pound != nil {
+				out.WriteString(formatNumber(*pound))
+			} else {
+				out.WriteRune('#')
+			}
+			continue
+		}
+		if part.arg == "" {
+			out.WriteString(part.text)
+			continue
+		}
+
+		value, ok := values[part.arg]
+		if !ok {
+			// keep unknown placeholders visible so missing values are easy to spot
+			out.WriteString("{" + part.arg + "}")
+			continue
+		}
+
+		switch part.kind {
+		case "":
+			out.WriteString(fmt.Sprint(value))
+		case "number":
+			if n, ok := toNumber(value); ok {
+				out.WriteString(formatNumber(n))
+			} else {
+				out.WriteString(fmt.Sprint(value))
+			}
+		case "select":
+			branch, ok := part.branches[fmt.Sprint(value)]
+			if !ok {
+				branch = part.branches["other"]
+			}
+			formatParts(lang, branch, values, pound, out)
+		case "plural", "selectordinal":
+			n, _ := toNumber(value)
+			branch, ok := part.branches["="+formatNumber(n)]
+			if !ok {
+				branch, ok = part.branches[getPluralRule(lang)(n-part.offset, part.kind == "selectordinal")]
+			}
+			if !ok {
+				branch = part.branches["other"]
+			}
+			adjusted := n - part.offset
+			formatParts(lang, branch, values, &adjusted, out)
+		}
+	}
+}
+
+// FormatLocalizationMessage formats an ICU MessageFormat style message with named values
+// Supported syntax:
+//   - {name} and {name, number} placeholders
+//   - {count, plural, =0 {no items} one {# item} other {# items}} with optional offset:n
+//   - {place, selectordinal, one {#st} two {#nd} few {#rd} other {#th}}
+//   - {gender, select, male {he} female {she} other {they}}
+//   - a doubled apostrophe for a literal apostrophe, and apostrophes to quote braces as in '{literal}'
+//
+// Plural categories follow the CLDR rules of the given language, see SetPluralRule
+// Placeholders without a value are left as-is
+func FormatLocalizationMessage(lang LocalizationLanguage, message string, values map[string]any) (string, error) {
+	parts, err := parseICUMessage(message)
+	if err != nil {
+		return message, err
+	}
+	var out strings.Builder
+	formatParts(lang, parts, values, nil, &out)
+	return out.String(), nil
+}
+
+// messageArgumentNames returns the sorted distinct argument names used in an ICU style message
+func messageArgumentNames(message string) []string {
+	parts, err := parseICUMessage(message)
+	if err != nil {
+		return nil
+	}
+	names := map[string]bool{}
+	var collect func(parts []messagePart)
+	collect = func(parts []messagePart) {
+		for _, part := range parts {
+			if part.arg != "" {
+				names[part.arg] = true
+			}
+			for _, branch := range part.branches {
+				collect(branch)
+			}
+		}
+	}
+	collect(parts)
+
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// namedMessageValues returns the values as a name to value map when a single map with string keys is given
+// such as map[string]any or gin.H
+func namedMessageValues(values []any) (map[string]any, bool) {
+	if len(values) != 1 || values[0] == nil {
+		return nil, false
+	}
+	if m, ok := values[0].(map[string]any); ok {
+		return m, true
+	}
+	v := reflect.ValueOf(values[0])
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	m := make(map[string]any, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		m[iter.Key().String()] = iter.Value().Interface()
+	}
+	return m, true
+}
+
+// formatLocalizationValues formats a message with either named or positional values
+// A single map argument fills named placeholders, positional values use sprintf verbs when the message has any,
+// otherwise they fill the indexed placeholders {0}, {1}... so translators may reorder them
+// A map given to a message with sprintf verbs cannot fill them, the message is returned unchanged
+func formatLocalizationValues(lang LocalizationLanguage, message string, values []any) string {
+	if named, ok := namedMessageValues(values); ok {
+		if countFormatVerbs(message) > 0 {
+			return message
+		}
+		formatted, err := FormatLocalizationMessage(lang, message, named)
+		if err == nil {
+			return formatted
+		}
+		return message
+	}
+	if countFormatVerbs(message) == 0 && strings.ContainsRune(message, '{') {
+		indexed := make(map[string]any, len(values))
+		for i, v := range values {
+			indexed[strconv.Itoa(i)] = v
+		}
+		if formatted, err := FormatLocalizationMessage(lang, message, indexed); err == nil {
+			return formatted
+		}
+	}
+	return fmt.Sprintf(message, values...)
+}
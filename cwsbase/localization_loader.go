@@ -0,0 +1,286 @@
+package cwsbase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// LocalizationIssue describes a problem found by ValidateLocalizationData
+type LocalizationIssue struct {
+	// Language is the language in which the problem was found
+	Language LocalizationLanguage
+	// Code is the localization code with the problem
+	Code LocalizationCode
+	// Reason is a human readable description of the problem
+	Reason string
+}
+
+// String formats the issue as "language/code: reason"
+func (i LocalizationIssue) String() string {
+	return fmt.Sprintf("%s/%s: %s", i.Language, i.Code, i.Reason)
+}
+
+// matchFormatVerb matches sprintf verbs such as %s, %d, %v, %.2f or %[1]s, escaped %% is matched separately and ignored
+var matchFormatVerb = regexp.MustCompile(`%%|%[-+# 0]*(\[\d+\])?(\d+|\*)?(\.(\d+|\*)?)?[a-zA-Z]`)
+
+// isLocalizationFile reports whether the file extension is a supported catalog format
+func isLocalizationFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json", ".yaml", ".yml", ".toml":
+		return true
+	}
+	return false
+}
+
+// parseLocalizationCatalog decodes a per-language catalog in JSON, YAML or TOML format
+// Nested objects are flattened into dotted codes, e.g. {"validation": {"required": "..."}} becomes "validation.required"
+func parseLocalizationCatalog(name string, content []byte) (map[LocalizationCode]string, error) {
+	var raw map[string]any
+	var err error
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		err = json.Unmarshal(content, &raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &raw)
+	case ".toml":
+		err = toml.Unmarshal(content, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported localization file format: %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse localization file %s: %w", name, err)
+	}
+
+	catalog := map[LocalizationCode]string{}
+	flattenLocalizationCatalog("", raw, catalog)
+	return catalog, nil
+}
+
+// flattenLocalizationCatalog copies nested catalog values into a flat code to message map
+func flattenLocalizationCatalog(prefix string, raw map[string]any, catalog map[LocalizationCode]string) {
+	for k, v := range raw {
+		code := k
+		if prefix != "" {
+			code = prefix + "." + k
+		}
+		switch val := v.(type) {
+		case map[string]any:
+			flattenLocalizationCatalog(code, val, catalog)
+		case string:
+			catalog[LocalizationCode(code)] = val
+		default:
+			catalog[LocalizationCode(code)] = fmt.Sprint(val)
+		}
+	}
+}
+
+// localizationLanguageFromFileName derives the language from a catalog file name, e.g. "zh-TW.yaml" becomes "zh_tw"
+func localizationLanguageFromFileName(name string) LocalizationLanguage {
+	base := path.Base(name)
+	base = strings.TrimSuffix(base, path.Ext(base))
+	return LocalizationLanguage(strings.ToLower(strings.ReplaceAll(base, "-", "_")))
+}
+
+// LoadLocalizationFS loads every per-language catalog file in dir of the given file system, such as an embed.FS
+// Each file holds the messages of one language and is named after it, e.g. en.json, zh_tw.yaml or zh_cn.toml
+// The loaded messages are merged with the existing localization data
+func LoadLocalizationFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	data := map[LocalizationLanguage]map[LocalizationCode]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isLocalizationFile(entry.Name()) {
+			continue
+		}
+		name := path.Join(dir, entry.Name())
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		catalog, err := parseLocalizationCatalog(name, content)
+		if err != nil {
+			return err
+		}
+
+		lang := localizationLanguageFromFileName(name)
+		if _, ok := data[lang]; !ok {
+			data[lang] = map[LocalizationCode]string{}
+		}
+		for code, message := range catalog {
+			data[lang][code] = message
+		}
+	}
+
+	mergeLocalizationData(data)
+	return nil
+}
+
+// LoadLocalizationDir loads every per-language catalog file in a directory of the local file system
+// See LoadLocalizationFS for the file naming convention
+func LoadLocalizationDir(dir string) error {
+	return LoadLocalizationFS(os.DirFS(dir), ".")
+}
+
+// LoadLocalizationFile loads a single per-language catalog file, the language is taken from the file name
+func LoadLocalizationFile(filePath string) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	catalog, err := parseLocalizationCatalog(filePath, content)
+	if err != nil {
+		return err
+	}
+
+	mergeLocalizationData(map[LocalizationLanguage]map[LocalizationCode]string{
+		localizationLanguageFromFileName(filePath): catalog,
+	})
+	return nil
+}
+
+// countFormatVerbs returns the number of sprintf verbs in a message, ignoring escaped %%
+func countFormatVerbs(message string) int {
+	count := 0
+	for _, verb := range matchFormatVerb.FindAllString(message, -1) {
+		if verb != "%%" {
+			count++
+		}
+	}
+	return count
+}
+
+// ValidateLocalizationData checks the loaded localization data for codes missing in some languages,
+// for translations whose number of sprintf verbs or named placeholders differs from the reference language
+// and for malformed ICU style messages
+// The reference language is the given one, or the default language from LOCALIZATION_LANGUAGE
+// Returns the issues sorted by code and language, an empty slice means the data is consistent
+func ValidateLocalizationData(reference ...LocalizationLanguage) []LocalizationIssue {
+	ref := GetDefaultLocalizationLanguage()
+	if len(reference) > 0 {
+		ref = reference[0]
+	}
+
+	localInitLock.RLock()
+	defer localInitLock.RUnlock()
+
+	codes := map[LocalizationCode]bool{}
+	for _, messages := range localmap {
+		for code := range messages {
+			codes[code] = true
+		}
+	}
+
+	issues := []LocalizationIssue{}
+	for code := range codes {
+		refMessage, hasRef := localmap[ref][code]
+		for lang, messages := range localmap {
+			message, ok := messages[code]
+			if !ok {
+				issues = append(issues, LocalizationIssue{Language: lang, Code: code, Reason: "missing translation"})
+				continue
+			}
+			if hasRef && lang != ref {
+				if expected, actual := countFormatVerbs(refMessage), countFormatVerbs(message); expected != actual {
+					issues = append(issues, LocalizationIssue{
+						Language: lang,
+						Code:     code,
+						Reason:   fmt.Sprintf("has %d format verbs but %s has %d", actual, ref, expected),
+					})
+				}
+				if expected, actual := messageArgumentNames(refMessage), messageArgumentNames(message); strings.Join(expected, ",") != strings.Join(actual, ",") {
+					issues = append(issues, LocalizationIssue{
+						Language: lang,
+						Code:     code,
+						Reason:   fmt.Sprintf("has placeholders %v but %s has %v", actual, ref, expected),
+					})
+				}
+			}
+			if _, err := parseICUMessage(message); err != nil && countFormatVerbs(message) == 0 {
+				issues = append(issues, LocalizationIssue{Language: lang, Code: code, Reason: "invalid message format: " + err.Error()})
+			}
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Code != issues[j].Code {
+			return issues[i].Code < issues[j].Code
+		}
+		return issues[i].Language < issues[j].Language
+	})
+	return issues
+}
+
+// snapshotLocalizationDir returns the modification time and size of every catalog file in dir
+func snapshotLocalizationDir(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := map[string]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isLocalizationFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		snapshot[entry.Name()] = fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+	}
+	return snapshot, nil
+}
+
+// WatchLocalizationDir polls a catalog directory and reloads it whenever a file is added or modified
+// This allows translators to update messages without a redeploy
+// Reloaded messages are merged, so codes removed from a file stay available until the process restarts
+// onReload is optional and is called after every reload attempt with its error (nil on success)
+// The watcher stops when ctx is done
+func WatchLocalizationDir(ctx context.Context, dir string, interval time.Duration, onReload func(err error)) {
+	last, _ := snapshotLocalizationDir(dir)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				current, err := snapshotLocalizationDir(dir)
+				if err == nil && !localizationSnapshotChanged(last, current) {
+					continue
+				}
+				if err == nil {
+					last = current
+					err = LoadLocalizationDir(dir)
+				}
+				if onReload != nil {
+					onReload(err)
+				}
+			}
+		}
+	}()
+}
+
+// localizationSnapshotChanged reports whether any catalog file was added or modified between two snapshots
+func localizationSnapshotChanged(last map[string]string, current map[string]string) bool {
+	for name, stamp := range current {
+		if last[name] != stamp {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package cwsbase
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadLocalizationFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"locales/en.json":    {Data: []byte(`{"loader.hello": "Hello %s", "loader": {"nested": "Nested"}}`)},
+		"locales/zh-TW.yaml": {Data: []byte("loader.hello: \"你好 %s\"\nloader:\n  nested: 巢狀\n")},
+		"locales/zh_cn.toml": {Data: []byte("\"loader.hello\" = \"你好\"\n")},
+		"locales/README.md":  {Data: []byte("ignored")},
+	}
+
+	if err := LoadLocalizationFS(fsys, "locales"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		lang     LocalizationLanguage
+		code     LocalizationCode
+		expected string
+	}{
+		{lang: English, code: "loader.hello", expected: "Hello %s"},
+		{lang: English, code: "loader.nested", expected: "Nested"},
+		{lang: Taiwanese, code: "loader.hello", expected: "你好 %s"},
+		{lang: Taiwanese, code: "loader.nested", expected: "巢狀"},
+		{lang: Chinese, code: "loader.hello", expected: "你好"},
+	}
+	for _, tt := range tests {
+		message, ok := LookupLocalizationMessage(tt.lang, tt.code)
+		if !ok || message != tt.expected {
+			t.Errorf("LookupLocalizationMessage(%s, %s) = %q, %v, want %q", tt.lang, tt.code, message, ok, tt.expected)
+		}
+	}
+
+	issues := map[string]bool{}
+	for _, issue := range ValidateLocalizationData(English) {
+		issues[issue.String()] = true
+	}
+	expected := []string{
+		"zh_cn/loader.hello: has 0 format verbs but en has 1",
+		"zh_cn/loader.nested: missing translation",
+	}
+	for _, e := range expected {
+		if !issues[e] {
+			t.Errorf("ValidateLocalizationData() missing issue %q, got %v", e, issues)
+		}
+	}
+}
+
+func TestCountFormatVerbs(t *testing.T) {
+	tests := []struct {
+		message  string
+		expected int
+	}{
+		{message: "plain", expected: 0},
+		{message: "Id: %s", expected: 1},
+		{message: "100%% done by %s at %.2f", expected: 2},
+		{message: "%[2]s then %[1]d", expected: 2},
+	}
+	for _, tt := range tests {
+		if actual := countFormatVerbs(tt.message); actual != tt.expected {
+			t.Errorf("countFormatVerbs(%q) = %d, want %d", tt.message, actual, tt.expected)
+		}
+	}
+}
+
+func TestFormatLocalizationMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		lang     LocalizationLanguage
+		message  string
+		values   map[string]any
+		expected string
+	}{
+		{name: "named", lang: English, message: "Hello {name}", values: map[string]any{"name": "Ann"}, expected: "Hello Ann"},
+		{name: "missing value", lang: English, message: "Hello {name}", values: map[string]any{}, expected: "Hello {name}"},
+		{name: "plural one", lang: English, message: "{count, plural, one {# item} other {# items}}", values: map[string]any{"count": 1}, expected: "1 item"},
+		{name: "plural other", lang: English, message: "{count, plural, one {# item} other {# items}}", values: map[string]any{"count": 3}, expected: "3 items"},
+		{name: "plural exact", lang: English, message: "{count, plural, =0 {no items} one {# item} other {# items}}", values: map[string]any{"count": 0}, expected: "no items"},
+		{name: "plural chinese", lang: Taiwanese, message: "{count, plural, one {# 個} other {共 # 個}}", values: map[string]any{"count": 1}, expected: "共 1 個"},
+		{name: "plural offset", lang: English, message: "{n, plural, offset:1 =1 {only you} one {you and # other} other {you and # others}}", values: map[string]any{"n": 3}, expected: "you and 2 others"},
+		{name: "selectordinal", lang: English, message: "{place, selectordinal, one {#st} two {#nd} few {#rd} other {#th}}", values: map[string]any{"place": 22}, expected: "22nd"},
+		{name: "select", lang: English, message: "{gender, select, female {She} other {They}} replied", values: map[string]any{"gender": "female"}, expected: "She replied"},
+		{name: "nested", lang: English, message: "{g, select, other {{n, plural, one {# reply} other {# replies}} from {who}}}", values: map[string]any{"g": "x", "n": 2, "who": "Bo"}, expected: "2 replies from Bo"},
+		{name: "quoted", lang: English, message: "It''s '{literal}' {x}", values: map[string]any{"x": 1}, expected: "It's {literal} 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := FormatLocalizationMessage(tt.lang, tt.message, tt.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tt.expected {
+				t.Errorf("FormatLocalizationMessage() = %q, want %q", actual, tt.expected)
+			}
+		})
+	}
+
+	if _, err := FormatLocalizationMessage(English, "{count, plural, one {x}}", nil); err == nil {
+		t.Error("expected error for plural without other branch")
+	}
+}
+
+func TestGetLocalizationMessageValues(t *testing.T) {
+	UpdateLocalizationData([]byte(`{
+		"en": {"format.named": "{name} has {count, plural, one {# order} other {# orders}}", "format.indexed": "{1} then {0}", "format.verbs": "Id: %s"},
+		"zh_tw": {"format.named": "{name} 有 {count} 筆訂單"}
+	}`))
+
+	type values map[string]any
+	tests := []struct {
+		lang     LocalizationLanguage
+		code     LocalizationCode
+		values   []any
+		expected string
+	}{
+		{lang: English, code: "format.named", values: []any{map[string]any{"name": "Ann", "count": 2}}, expected: "Ann has 2 orders"},
+		{lang: English, code: "format.named", values: []any{values{"name": "Ann", "count": 1}}, expected: "Ann has 1 order"},
+		{lang: Taiwanese, code: "format.named", values: []any{map[string]any{"name": "Ann", "count": 2}}, expected: "Ann 有 2 筆訂單"},
+		{lang: English, code: "format.indexed", values: []any{"a", "b"}, expected: "b then a"},
+		{lang: English, code: "format.verbs", values: []any{"42"}, expected: "Id: 42"},
+		{lang: English, code: "format.verbs", values: []any{map[string]any{"id": "42"}}, expected: "Id: %s"},
+	}
+	for _, tt := range tests {
+		if actual := GetLocalizationMessageByLanguage(tt.lang, tt.code, tt.values...); actual != tt.expected {
+			t.Errorf("GetLocalizationMessageByLanguage(%s, %s) = %q, want %q", tt.lang, tt.code, actual, tt.expected)
+		}
+	}
+}
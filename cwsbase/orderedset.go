@@ -0,0 +1,150 @@
+package cwsbase
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+	"sync"
+)
+
+// OrderedSet is a generic set keeping its values sorted, safe for concurrent use
+// Values are kept in a sorted slice, so lookups are O(log n) and changes O(n)
+type OrderedSet[T any] struct {
+	lock    sync.RWMutex
+	values  []T
+	compare func(a, b T) int
+}
+
+// NewOrderedSet returns a set of values sorted in ascending order
+func NewOrderedSet[T cmp.Ordered](values ...T) *OrderedSet[T] {
+	return NewOrderedSetFunc(cmp.Compare[T], values...)
+}
+
+// NewOrderedSetFunc returns a set of values sorted by compare, values comparing equal are the same value of the set
+// e.g. cwsbase.NewOrderedSetFunc(func(a, b User) int { return strings.Compare(a.Id, b.Id) })
+func NewOrderedSetFunc[T any](compare func(a, b T) int, values ...T) *OrderedSet[T] {
+	s := &OrderedSet[T]{compare: compare}
+	s.Add(values...)
+	return s
+}
+
+// Add adds values to the set and returns the number of values that were not in the set
+func (s *OrderedSet[T]) Add(values ...T) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	added := 0
+	for _, value := range values {
+		i, found := slices.BinarySearchFunc(s.values, value, s.compare)
+		if !found {
+			s.values = slices.Insert(s.values, i, value)
+			added++
+		}
+	}
+	return added
+}
+
+// Remove removes values from the set and returns the number of values that were in the set
+func (s *OrderedSet[T]) Remove(values ...T) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	removed := 0
+	for _, value := range values {
+		i, found := slices.BinarySearchFunc(s.values, value, s.compare)
+		if found {
+			s.values = slices.Delete(s.values, i, i+1)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Contains reports whether value is in the set
+func (s *OrderedSet[T]) Contains(value T) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	_, found := slices.BinarySearchFunc(s.values, value, s.compare)
+	return found
+}
+
+// Len returns the number of values in the set
+func (s *OrderedSet[T]) Len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return len(s.values)
+}
+
+// Min returns the smallest value, false if the set is empty
+func (s *OrderedSet[T]) Min() (T, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if len(s.values) == 0 {
+		var zero T
+		return zero, false
+	}
+	return s.values[0], true
+}
+
+// Max returns the largest value, false if the set is empty
+func (s *OrderedSet[T]) Max() (T, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if len(s.values) == 0 {
+		var zero T
+		return zero, false
+	}
+	return s.values[len(s.values)-1], true
+}
+
+// Clear removes all values from the set
+func (s *OrderedSet[T]) Clear() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.values = nil
+}
+
+// Values returns a copy of the values in ascending order
+func (s *OrderedSet[T]) Values() []T {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return slices.Clone(s.values)
+}
+
+// All returns an iterator over the values in ascending order
+// The values are copied when the iteration starts, the set can be changed during the iteration
+func (s *OrderedSet[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, value := range s.Values() {
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// Backward returns an iterator over the values in descending order
+func (s *OrderedSet[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		values := s.Values()
+		for i := len(values) - 1; i >= 0; i-- {
+			if !yield(values[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Range returns an iterator over the values from from (inclusive) to to (exclusive) in ascending order
+func (s *OrderedSet[T]) Range(from T, to T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		s.lock.RLock()
+		start, _ := slices.BinarySearchFunc(s.values, from, s.compare)
+		end, _ := slices.BinarySearchFunc(s.values, to, s.compare)
+		values := slices.Clone(s.values[start:max(start, end)])
+		s.lock.RUnlock()
+		for _, value := range values {
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
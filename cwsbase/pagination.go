@@ -0,0 +1,109 @@
+package cwsbase
+
+import (
+	"errors"
+)
+
+// DefaultPageSize is the page size used when a request does not specify one
+var DefaultPageSize = 20
+
+// MaxPageSize is the largest page size a request may ask for
+var MaxPageSize = 100
+
+// ErrInvalidCursor is returned when a cursor token cannot be decrypted or decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageRequest describes the requested page of a list
+// In cursor mode (Cursor is set) the list continues after the position stored in the cursor,
+// otherwise Page and Size select the page by offset
+type PageRequest struct {
+	// Page is the 1-based page number used in offset mode
+	Page int
+	// Size is the maximum number of items of the page
+	Size int
+	// Cursor holds the position decoded from the cursor token, nil in offset mode
+	Cursor map[string]any
+	// WithTotal requests the total number of items, which costs an additional count query
+	WithTotal bool
+}
+
+// NewPageRequest returns the first page with the given size, or DefaultPageSize if size is not positive
+func NewPageRequest(size int) PageRequest {
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	return PageRequest{Page: 1, Size: size}
+}
+
+// IsCursor reports whether the request continues from a cursor
+func (p PageRequest) IsCursor() bool {
+	return p.Cursor != nil
+}
+
+// Offset returns the number of items to skip in offset mode
+func (p PageRequest) Offset() int {
+	if p.Page <= 1 {
+		return 0
+	}
+	return (p.Page - 1) * p.Size
+}
+
+// Limit returns the page size, or DefaultPageSize if it is not set
+func (p PageRequest) Limit() int {
+	if p.Size <= 0 {
+		return DefaultPageSize
+	}
+	return p.Size
+}
+
+// Page is a page of items with the information needed to request the next one
+type Page[T any] struct {
+	// Items are the items of the page
+	Items []T
+	// NextCursor is the opaque token of the next page, empty if there is none
+	NextCursor string
+	// HasMore reports whether more items follow this page
+	HasMore bool
+	// Total is the total number of items, nil unless requested with WithTotal
+	Total *int64
+}
+
+// EncodeCursor encrypts the position of the next page into an opaque cursor token with EncryptMap
+func EncodeCursor(position map[string]any) (string, error) {
+	return EncryptMap(position)
+}
+
+// DecodeCursor decrypts a cursor token created by EncodeCursor
+// Returns ErrInvalidCursor if the token was not created by EncodeCursor
+func DecodeCursor(token string) (map[string]any, error) {
+	position, err := DecryptToMap(token)
+	if err != nil || position == nil {
+		return nil, ErrInvalidCursor
+	}
+	return position, nil
+}
+
+// NewPage builds a page from items fetched with one extra item beyond the page size
+// The extra item only signals that more items follow and is dropped
+// position returns the cursor position of the last item of the page
+func NewPage[T any](request PageRequest, items []T, position func(last T) (map[string]any, error)) (Page[T], error) {
+	page := Page[T]{Items: items}
+	if len(items) > request.Limit() {
+		page.Items = items[:request.Limit()]
+		page.HasMore = true
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+
+	if page.HasMore && position != nil {
+		last, err := position(page.Items[len(page.Items)-1])
+		if err != nil {
+			return page, err
+		}
+		if page.NextCursor, err = EncodeCursor(last); err != nil {
+			return page, err
+		}
+	}
+	return page, nil
+}
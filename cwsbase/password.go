@@ -0,0 +1,293 @@
+package cwsbase
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PasswordAlgorithm is a password hashing algorithm
+type PasswordAlgorithm string
+
+const (
+	// PasswordArgon2id hashes into "$argon2id$v=19$m=<KiB>,t=<iterations>,p=<parallelism>$<salt>$<hash>"
+	PasswordArgon2id PasswordAlgorithm = "argon2id"
+	// PasswordBcrypt hashes into "$2a$<cost>$<salt and hash>", passwords are limited to 72 bytes
+	PasswordBcrypt PasswordAlgorithm = "bcrypt"
+	// PasswordScrypt hashes into "$scrypt$ln=<log2 N>,r=<block size>,p=<parallelism>$<salt>$<hash>"
+	PasswordScrypt PasswordAlgorithm = "scrypt"
+)
+
+var (
+	// ErrPasswordHashFormat is returned for hashes that are not in a supported PHC format
+	ErrPasswordHashFormat = errors.New("unsupported password hash format")
+	// ErrPasswordPolicy is returned for password policies with invalid parameters
+	ErrPasswordPolicy = errors.New("invalid password policy")
+)
+
+// PasswordPolicy selects the algorithm and parameters of new password hashes
+// Hashes of other algorithms or weaker parameters still verify, NeedsRehash reports them for upgrade on login
+type PasswordPolicy struct {
+	// Algorithm hashes new passwords
+	Algorithm PasswordAlgorithm
+	// Argon2Memory is the argon2id memory in KiB
+	Argon2Memory uint32
+	// Argon2Iterations is the argon2id number of passes
+	Argon2Iterations uint32
+	// Argon2Parallelism is the argon2id number of lanes
+	Argon2Parallelism uint8
+	// BcryptCost is the bcrypt cost, 4 to 31
+	BcryptCost int
+	// ScryptLogN is log2 of the scrypt CPU/memory cost N
+	ScryptLogN uint8
+	// ScryptR is the scrypt block size
+	ScryptR int
+	// ScryptP is the scrypt parallelism
+	ScryptP int
+	// SaltLength is the salt length in bytes of argon2id and scrypt
+	SaltLength int
+	// KeyLength is the hash length in bytes of argon2id and scrypt
+	KeyLength int
+}
+
+// DefaultPasswordPolicy is argon2id with 64 MiB, 3 passes and 2 lanes, as recommended by RFC 9106 for constrained memory
+// The bcrypt and scrypt parameters are used when Algorithm is changed to them
+var DefaultPasswordPolicy = PasswordPolicy{
+	Algorithm:         PasswordArgon2id,
+	Argon2Memory:      64 * 1024,
+	Argon2Iterations:  3,
+	Argon2Parallelism: 2,
+	BcryptCost:        12,
+	ScryptLogN:        15,
+	ScryptR:           8,
+	ScryptP:           1,
+	SaltLength:        16,
+	KeyLength:         32,
+}
+
+var passwordPolicyLock sync.RWMutex
+var passwordPolicy = DefaultPasswordPolicy
+
+// SetPasswordPolicy sets the policy of HashPassword and NeedsRehash
+func SetPasswordPolicy(policy PasswordPolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	passwordPolicyLock.Lock()
+	defer passwordPolicyLock.Unlock()
+	passwordPolicy = policy
+	return nil
+}
+
+// GetPasswordPolicy returns the policy set with SetPasswordPolicy, DefaultPasswordPolicy by default
+func GetPasswordPolicy() PasswordPolicy {
+	passwordPolicyLock.RLock()
+	defer passwordPolicyLock.RUnlock()
+	return passwordPolicy
+}
+
+// HashPassword hashes a password with the policy of GetPasswordPolicy into a PHC string
+func HashPassword(password string) (string, error) {
+	return GetPasswordPolicy().Hash(password)
+}
+
+// VerifyPassword reports whether password matches a hash of any supported algorithm, compared in constant time
+// Returns ErrPasswordHashFormat if the hash cannot be parsed
+func VerifyPassword(password string, hash string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("%w: %w", ErrPasswordHashFormat, err)
+		}
+		return true, nil
+	}
+
+	parsed, err := parsePasswordHash(hash)
+	if err != nil {
+		return false, err
+	}
+	key, err := parsed.policy.derive(password, parsed.salt, len(parsed.key))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(key, parsed.key) == 1, nil
+}
+
+// NeedsRehash reports whether a hash was made with another algorithm or parameters than the policy of GetPasswordPolicy
+func NeedsRehash(hash string) bool {
+	return GetPasswordPolicy().NeedsRehash(hash)
+}
+
+// VerifyPasswordAndRehash verifies a password and returns a new hash when the stored one needs a rehash
+// rehashed is empty if the password does not match or the hash is current, otherwise store it in place of hash, e.g.
+//
+//	ok, rehashed, err := cwsbase.VerifyPasswordAndRehash(password, account.Password)
+//	if ok && rehashed != "" {
+//		account.Password = rehashed
+//	}
+func VerifyPasswordAndRehash(password string, hash string) (ok bool, rehashed string, err error) {
+	ok, err = VerifyPassword(password, hash)
+	if err != nil || !ok || !NeedsRehash(hash) {
+		return ok, "", err
+	}
+	rehashed, err = HashPassword(password)
+	if err != nil {
+		return true, "", err
+	}
+	return true, rehashed, nil
+}
+
+// Hash hashes a password with the policy into a PHC string with a random salt
+func (p PasswordPolicy) Hash(password string) (string, error) {
+	if err := p.validate(); err != nil {
+		return "", err
+	}
+	if p.Algorithm == PasswordBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := p.derive(password, salt, p.KeyLength)
+	if err != nil {
+		return "", err
+	}
+	encode := base64.RawStdEncoding.EncodeToString
+	return fmt.Sprintf("$%s$%s$%s$%s", p.Algorithm, p.phcParams(), encode(salt), encode(key)), nil
+}
+
+// NeedsRehash reports whether a hash was made with another algorithm or parameters than the policy
+func (p PasswordPolicy) NeedsRehash(hash string) bool {
+	if p.Algorithm == PasswordBcrypt {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != p.BcryptCost
+	}
+	parsed, err := parsePasswordHash(hash)
+	if err != nil || parsed.policy.Algorithm != p.Algorithm || len(parsed.salt) < p.SaltLength || len(parsed.key) != p.KeyLength {
+		return true
+	}
+	return parsed.policy.phcParams() != p.phcParams()
+}
+
+// validate checks that the parameters of the selected algorithm are usable
+func (p PasswordPolicy) validate() error {
+	switch p.Algorithm {
+	case PasswordBcrypt:
+		if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("%w: bcrypt cost %d", ErrPasswordPolicy, p.BcryptCost)
+		}
+		return nil
+	case PasswordArgon2id:
+		if p.Argon2Memory < 8*uint32(p.Argon2Parallelism) || p.Argon2Iterations < 1 || p.Argon2Parallelism < 1 {
+			return fmt.Errorf("%w: argon2id m=%d,t=%d,p=%d", ErrPasswordPolicy, p.Argon2Memory, p.Argon2Iterations, p.Argon2Parallelism)
+		}
+	case PasswordScrypt:
+		if p.ScryptLogN < 1 || p.ScryptLogN > 31 || p.ScryptR < 1 || p.ScryptP < 1 {
+			return fmt.Errorf("%w: scrypt ln=%d,r=%d,p=%d", ErrPasswordPolicy, p.ScryptLogN, p.ScryptR, p.ScryptP)
+		}
+	default:
+		return fmt.Errorf("%w: unknown algorithm %q", ErrPasswordPolicy, p.Algorithm)
+	}
+	if p.SaltLength < 8 || p.KeyLength < 16 {
+		return fmt.Errorf("%w: salt length %d, key length %d", ErrPasswordPolicy, p.SaltLength, p.KeyLength)
+	}
+	return nil
+}
+
+// phcParams returns the parameter section of a PHC string
+func (p PasswordPolicy) phcParams() string {
+	if p.Algorithm == PasswordScrypt {
+		return fmt.Sprintf("ln=%d,r=%d,p=%d", p.ScryptLogN, p.ScryptR, p.ScryptP)
+	}
+	return fmt.Sprintf("v=%d$m=%d,t=%d,p=%d", argon2.Version, p.Argon2Memory, p.Argon2Iterations, p.Argon2Parallelism)
+}
+
+// derive computes the argon2id or scrypt key of a password
+func (p PasswordPolicy) derive(password string, salt []byte, keyLength int) ([]byte, error) {
+	if p.Algorithm == PasswordScrypt {
+		return scrypt.Key([]byte(password), salt, 1<<p.ScryptLogN, p.ScryptR, p.ScryptP, keyLength)
+	}
+	return argon2.IDKey([]byte(password), salt, p.Argon2Iterations, p.Argon2Memory, p.Argon2Parallelism, uint32(keyLength)), nil
+}
+
+// parsedPasswordHash is an argon2id or scrypt PHC string
+type parsedPasswordHash struct {
+	policy PasswordPolicy
+	salt   []byte
+	key    []byte
+}
+
+// parsePasswordHash parses an argon2id or scrypt PHC string
+func parsePasswordHash(hash string) (parsedPasswordHash, error) {
+	parts := strings.Split(hash, "$")
+	var parsed parsedPasswordHash
+	var params, salt, key string
+	switch {
+	case len(parts) == 6 && parts[0] == "" && parts[1] == string(PasswordArgon2id):
+		if parts[2] != "v="+strconv.Itoa(argon2.Version) {
+			return parsed, fmt.Errorf("%w: argon2 version %s", ErrPasswordHashFormat, parts[2])
+		}
+		parsed.policy.Algorithm = PasswordArgon2id
+		params, salt, key = parts[3], parts[4], parts[5]
+	case len(parts) == 5 && parts[0] == "" && parts[1] == string(PasswordScrypt):
+		parsed.policy.Algorithm = PasswordScrypt
+		params, salt, key = parts[2], parts[3], parts[4]
+	default:
+		return parsed, ErrPasswordHashFormat
+	}
+
+	values := map[string]uint64{}
+	for _, param := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(param, "=")
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return parsed, fmt.Errorf("%w: parameter %q", ErrPasswordHashFormat, param)
+		}
+		values[name] = n
+	}
+	if parsed.policy.Algorithm == PasswordArgon2id {
+		if values["p"] > 255 {
+			return parsed, fmt.Errorf("%w: parallelism %d", ErrPasswordHashFormat, values["p"])
+		}
+		parsed.policy.Argon2Memory = uint32(values["m"])
+		parsed.policy.Argon2Iterations = uint32(values["t"])
+		parsed.policy.Argon2Parallelism = uint8(values["p"])
+	} else {
+		if values["ln"] > 31 {
+			return parsed, fmt.Errorf("%w: ln %d", ErrPasswordHashFormat, values["ln"])
+		}
+		parsed.policy.ScryptLogN = uint8(values["ln"])
+		parsed.policy.ScryptR = int(values["r"])
+		parsed.policy.ScryptP = int(values["p"])
+	}
+
+	var err error
+	if parsed.salt, err = base64.RawStdEncoding.DecodeString(salt); err != nil {
+		return parsed, fmt.Errorf("%w: salt: %w", ErrPasswordHashFormat, err)
+	}
+	if parsed.key, err = base64.RawStdEncoding.DecodeString(key); err != nil {
+		return parsed, fmt.Errorf("%w: hash: %w", ErrPasswordHashFormat, err)
+	}
+	parsed.policy.SaltLength = len(parsed.salt)
+	parsed.policy.KeyLength = len(parsed.key)
+	if err := parsed.policy.validate(); err != nil {
+		return parsed, fmt.Errorf("%w: %w", ErrPasswordHashFormat, err)
+	}
+	return parsed, nil
+}
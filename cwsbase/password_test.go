@@ -0,0 +1,125 @@
+package cwsbase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testPasswordPolicy keeps the hashing parameters low so the tests run fast
+var testPasswordPolicy = PasswordPolicy{
+	Algorithm:         PasswordArgon2id,
+	Argon2Memory:      1024,
+	Argon2Iterations:  1,
+	Argon2Parallelism: 1,
+	BcryptCost:        4,
+	ScryptLogN:        10,
+	ScryptR:           8,
+	ScryptP:           1,
+	SaltLength:        16,
+	KeyLength:         32,
+}
+
+func TestPasswordHash(t *testing.T) {
+	for _, algorithm := range []PasswordAlgorithm{PasswordArgon2id, PasswordBcrypt, PasswordScrypt} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			policy := testPasswordPolicy
+			policy.Algorithm = algorithm
+			hash, err := policy.Hash("correct horse")
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, _ := policy.Hash("correct horse")
+			if hash == other {
+				t.Error("hashes of the same password are equal")
+			}
+			if algorithm != PasswordBcrypt && !strings.HasPrefix(hash, "$"+string(algorithm)+"$") {
+				t.Errorf("hash = %q", hash)
+			}
+
+			if ok, err := VerifyPassword("correct horse", hash); !ok || err != nil {
+				t.Errorf("VerifyPassword(correct) = %v, %v", ok, err)
+			}
+			if ok, err := VerifyPassword("wrong horse", hash); ok || err != nil {
+				t.Errorf("VerifyPassword(wrong) = %v, %v", ok, err)
+			}
+			if policy.NeedsRehash(hash) {
+				t.Error("NeedsRehash() = true for a hash of the policy")
+			}
+		})
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	hash, err := testPasswordPolicy.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stronger := testPasswordPolicy
+	stronger.Argon2Iterations = 2
+	scrypt := testPasswordPolicy
+	scrypt.Algorithm = PasswordScrypt
+	bcryptPolicy := testPasswordPolicy
+	bcryptPolicy.Algorithm = PasswordBcrypt
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		hash     string
+		expected bool
+	}{
+		{name: "same policy", policy: testPasswordPolicy, hash: hash, expected: false},
+		{name: "more iterations", policy: stronger, hash: hash, expected: true},
+		{name: "other algorithm", policy: scrypt, hash: hash, expected: true},
+		{name: "bcrypt policy", policy: bcryptPolicy, hash: hash, expected: true},
+		{name: "plain text", policy: testPasswordPolicy, hash: "XXXX", expected: true},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.NeedsRehash(tt.hash); got != tt.expected {
+			t.Errorf("%s: NeedsRehash() = %v, want %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestVerifyPasswordAndRehash(t *testing.T) {
+	bcryptPolicy := testPasswordPolicy
+	bcryptPolicy.Algorithm = PasswordBcrypt
+	old, err := bcryptPolicy.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetPasswordPolicy(testPasswordPolicy); err != nil {
+		t.Fatal(err)
+	}
+	defer SetPasswordPolicy(DefaultPasswordPolicy)
+
+	if ok, rehashed, err := VerifyPasswordAndRehash("wrong", old); ok || rehashed != "" || err != nil {
+		t.Errorf("VerifyPasswordAndRehash(wrong) = %v, %q, %v", ok, rehashed, err)
+	}
+	ok, rehashed, err := VerifyPasswordAndRehash("secret", old)
+	if !ok || err != nil || !strings.HasPrefix(rehashed, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("VerifyPasswordAndRehash(old) = %v, %q, %v", ok, rehashed, err)
+	}
+	if ok, again, err := VerifyPasswordAndRehash("secret", rehashed); !ok || again != "" || err != nil {
+		t.Errorf("VerifyPasswordAndRehash(current) = %v, %q, %v", ok, again, err)
+	}
+}
+
+func TestVerifyPasswordErrors(t *testing.T) {
+	hashes := []string{
+		"plain text",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaA",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!$aGFzaGhhc2hoYXNoaGFzaA",
+		"$scrypt$ln=99,r=8,p=1$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaA",
+		"$2a$04$short",
+	}
+	for _, hash := range hashes {
+		if ok, err := VerifyPassword("secret", hash); ok || !errors.Is(err, ErrPasswordHashFormat) {
+			t.Errorf("VerifyPassword(%q) = %v, %v", hash, ok, err)
+		}
+	}
+	if err := SetPasswordPolicy(PasswordPolicy{Algorithm: "md5"}); !errors.Is(err, ErrPasswordPolicy) {
+		t.Errorf("SetPasswordPolicy(md5) error = %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package cwsbase
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+	"sync"
+)
+
+// PriorityQueue is a generic binary heap popping the value of the highest priority first, safe for concurrent use
+// Values of equal priority are popped in no particular order
+type PriorityQueue[T any] struct {
+	lock   sync.Mutex
+	values []T
+	// less reports whether a has a higher priority than b
+	less func(a, b T) bool
+}
+
+// NewPriorityQueue returns a priority queue popping the smallest value first
+// e.g. cwsbase.NewPriorityQueue[time.Duration]() for the shortest delay first
+func NewPriorityQueue[T cmp.Ordered]() *PriorityQueue[T] {
+	return NewPriorityQueueFunc(cmp.Less[T])
+}
+
+// NewPriorityQueueFunc returns a priority queue popping first the value a for which less(a, b) is true
+// e.g. cwsbase.NewPriorityQueueFunc(func(a, b Job) bool { return a.Priority > b.Priority })
+func NewPriorityQueueFunc[T any](less func(a, b T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{less: less}
+}
+
+func (q *PriorityQueue[T]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !q.less(q.values[i], q.values[parent]) {
+			return
+		}
+		q.values[i], q.values[parent] = q.values[parent], q.values[i]
+		i = parent
+	}
+}
+
+func (q *PriorityQueue[T]) down(i int) {
+	for {
+		first := i
+		if left := 2*i + 1; left < len(q.values) && q.less(q.values[left], q.values[first]) {
+			first = left
+		}
+		if right := 2*i + 2; right < len(q.values) && q.less(q.values[right], q.values[first]) {
+			first = right
+		}
+		if first == i {
+			return
+		}
+		q.values[i], q.values[first] = q.values[first], q.values[i]
+		i = first
+	}
+}
+
+// Push adds values to the queue
+func (q *PriorityQueue[T]) Push(values ...T) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for _, value := range values {
+		q.values = append(q.values, value)
+		q.up(len(q.values) - 1)
+	}
+}
+
+// Pop removes and returns the value of the highest priority, false if the queue is empty
+func (q *PriorityQueue[T]) Pop() (T, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	var zero T
+	if len(q.values) == 0 {
+		return zero, false
+	}
+	value := q.values[0]
+	last := len(q.values) - 1
+	q.values[0] = q.values[last]
+	q.values[last] = zero
+	q.values = q.values[:last]
+	q.down(0)
+	return value, true
+}
+
+// Peek returns the value of the highest priority without removing it, false if the queue is empty
+func (q *PriorityQueue[T]) Peek() (T, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.values) == 0 {
+		var zero T
+		return zero, false
+	}
+	return q.values[0], true
+}
+
+// Len returns the number of values in the queue
+func (q *PriorityQueue[T]) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.values)
+}
+
+// Clear removes all values from the queue
+func (q *PriorityQueue[T]) Clear() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.values = nil
+}
+
+// All returns an iterator over the values from the highest priority to the lowest without removing them
+// The values are copied and sorted when the iteration starts, the queue can be changed during the iteration
+func (q *PriorityQueue[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		q.lock.Lock()
+		values := slices.Clone(q.values)
+		q.lock.Unlock()
+		slices.SortFunc(values, func(a, b T) int {
+			switch {
+			case q.less(a, b):
+				return -1
+			case q.less(b, a):
+				return 1
+			}
+			return 0
+		})
+		for _, value := range values {
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package cwsbase
+
+import (
+	"iter"
+	"sync"
+)
+
+// RingBuffer is a generic buffer of a fixed capacity overwriting its oldest value when full, safe for concurrent use
+// e.g. the last 100 events of a service for a debug endpoint
+type RingBuffer[T any] struct {
+	lock   sync.Mutex
+	values []T
+	// head is the index of the oldest value in values
+	head   int
+	length int
+}
+
+// NewRingBuffer returns an empty ring buffer of capacity values, it panics if capacity is not positive
+func NewRingBuffer[T any](capacity int) *RingBuffer[T] {
+	if capacity <= 0 {
+		panic("cwsbase: ring buffer capacity must be positive")
+	}
+	return &RingBuffer[T]{values: make([]T, capacity)}
+}
+
+// Push adds a value as the newest, when the buffer is full the oldest value is overwritten and returned with true
+func (r *RingBuffer[T]) Push(value T) (T, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.length < len(r.values) {
+		r.values[(r.head+r.length)%len(r.values)] = value
+		r.length++
+		var zero T
+		return zero, false
+	}
+	overwritten := r.values[r.head]
+	r.values[r.head] = value
+	r.head = (r.head + 1) % len(r.values)
+	return overwritten, true
+}
+
+// Pop removes and returns the oldest value, false if the buffer is empty
+func (r *RingBuffer[T]) Pop() (T, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var zero T
+	if r.length == 0 {
+		return zero, false
+	}
+	value := r.values[r.head]
+	r.values[r.head] = zero
+	r.head = (r.head + 1) % len(r.values)
+	r.length--
+	return value, true
+}
+
+// Len returns the number of values in the buffer
+func (r *RingBuffer[T]) Len() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.length
+}
+
+// Cap returns the capacity of the buffer
+func (r *RingBuffer[T]) Cap() int {
+	return len(r.values)
+}
+
+// Clear removes all values from the buffer
+func (r *RingBuffer[T]) Clear() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	clear(r.values)
+	r.head = 0
+	r.length = 0
+}
+
+// Values returns a copy of the values from the oldest to the newest
+func (r *RingBuffer[T]) Values() []T {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	values := make([]T, r.length)
+	for i := range values {
+		values[i] = r.values[(r.head+i)%len(r.values)]
+	}
+	return values
+}
+
+// All returns an iterator over the values from the oldest to the newest
+// The values are copied when the iteration starts, the buffer can be changed during the iteration
+func (r *RingBuffer[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, value := range r.Values() {
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
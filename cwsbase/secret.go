@@ -0,0 +1,90 @@
+package cwsbase
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+)
+
+// SecretResolver resolves the reference of a secret, e.g. "/prod/db/url" of "ssm:/prod/db/url", to its value
+type SecretResolver interface {
+	ResolveSecret(ctx context.Context, ref string) (string, error)
+}
+
+// SecretResolverFunc adapts a function to a SecretResolver
+type SecretResolverFunc func(ctx context.Context, ref string) (string, error)
+
+// ResolveSecret calls f
+func (f SecretResolverFunc) ResolveSecret(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+var secretResolverLock sync.RWMutex
+var secretResolvers = map[string]SecretResolver{}
+
+// RegisterSecretResolver registers the resolver of values "<scheme>:<ref>", a nil resolver removes the scheme
+// e.g. cwsaws.RegisterSecretResolvers registers "ssm" and "secretsmanager"
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolverLock.Lock()
+	defer secretResolverLock.Unlock()
+	if resolver == nil {
+		delete(secretResolvers, scheme)
+		return
+	}
+	secretResolvers[scheme] = resolver
+}
+
+// secretResolver returns the resolver of the scheme of value and the reference after the scheme
+func secretResolver(value string) (SecretResolver, string, bool) {
+	scheme, ref, ok := strings.Cut(value, ":")
+	if !ok {
+		return nil, "", false
+	}
+	secretResolverLock.RLock()
+	defer secretResolverLock.RUnlock()
+	resolver, ok := secretResolvers[scheme]
+	return resolver, ref, ok
+}
+
+// IsSecretReference reports whether value has the scheme of a registered resolver
+func IsSecretReference(value string) bool {
+	_, _, ok := secretResolver(value)
+	return ok
+}
+
+// ResolveSecret resolves a value "<scheme>:<ref>" with the resolver registered for the scheme
+// Values without a registered scheme are returned as is, so plain values and references can be mixed
+func ResolveSecret(ctx context.Context, value string) (string, error) {
+	resolver, ref, ok := secretResolver(value)
+	if !ok {
+		return value, nil
+	}
+	if ctx == nil {
+		ctx = context.TODO()
+	}
+	return resolver.ResolveSecret(ctx, ref)
+}
+
+// ResolveEnvSecrets replaces the environment variables holding secret references with their values
+// Call it at startup so code reading os.Getenv, e.g. CRYPTO_KEY_HEX or Local_DynamoDB_AWS_Secret, gets the values
+// The errors of all variables are returned together as *ConfigError
+func ResolveEnvSecrets(ctx context.Context) error {
+	var errs []error
+	for _, env := range os.Environ() {
+		key, value, _ := strings.Cut(env, "=")
+		if !IsSecretReference(value) {
+			continue
+		}
+		resolved, err := ResolveSecret(ctx, value)
+		if err != nil {
+			errs = append(errs, &ConfigError{Key: key, Err: err})
+			continue
+		}
+		if err := os.Setenv(key, resolved); err != nil {
+			errs = append(errs, &ConfigError{Key: key, Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package cwsbase
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	RegisterSecretResolver("test", SecretResolverFunc(func(ctx context.Context, ref string) (string, error) {
+		if ref == "missing" {
+			return "", errors.New("secret not found")
+		}
+		return "resolved " + ref, nil
+	}))
+	defer RegisterSecretResolver("test", nil)
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"test:/prod/db/url", "resolved /prod/db/url"},
+		{"test:db#password", "resolved db#password"},
+		{"plain", "plain"},
+		{"https://api.local", "https://api.local"},
+	}
+	for _, test := range tests {
+		if got, err := ResolveSecret(context.Background(), test.value); err != nil || got != test.want {
+			t.Errorf("ResolveSecret(%q) = %q, %v, want %q", test.value, got, err, test.want)
+		}
+	}
+
+	var config struct {
+		URL      string `env:"URL"`
+		Password string `env:"PASSWORD" default:"test:password"`
+		Missing  string `env:"MISSING"`
+	}
+	err := LoadConfig(&config, mapLookup(map[string]string{"URL": "test:url", "MISSING": "test:missing"}))
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) || configErr.Key != "MISSING" {
+		t.Errorf("LoadConfig() error = %v", err)
+	}
+	if config.URL != "resolved url" || config.Password != "resolved password" {
+		t.Errorf("config = %+v", config)
+	}
+
+	t.Setenv("SECRET_TEST_KEY", "test:key")
+	if err := ResolveEnvSecrets(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if v := os.Getenv("SECRET_TEST_KEY"); v != "resolved key" {
+		t.Errorf("SECRET_TEST_KEY = %q", v)
+	}
+}
@@ -1,10 +1,17 @@
 package cwsbase
 
+import (
+	"iter"
+	"sync"
+)
+
 type (
-	// Stack is a generic LIFO (Last In, First Out) data structure
+	// Stack is a generic LIFO (Last In, First Out) data structure, safe for concurrent use
+	// A Stack must not be copied after first use
 	Stack[T any] struct {
+		lock sync.Mutex
 		// top points to the top node in the stack
-		top    *node[T]
+		top *node[T]
 		// length stores the current number of items in the stack
 		length int
 	}
@@ -13,23 +20,34 @@ type (
 		// value holds the actual data
 		value *T
 		// prev points to the previous node in the stack
-		prev  *node[T]
+		prev *node[T]
 	}
 )
 
+// NewStack creates a new empty stack of type T
+func NewStack[T any]() *Stack[T] {
+	return &Stack[T]{}
+}
+
 // New creates a new empty stack of type T
+//
+// Deprecated: use NewStack, the returned Stack holds a lock and must not be copied
 func New[T any]() Stack[T] {
-	return Stack[T]{nil, 0}
+	return Stack[T]{}
 }
 
 // Len returns the number of items currently in the stack
 func (this *Stack[T]) Len() int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
 	return this.length
 }
 
 // Peek returns a pointer to the top item on the stack without removing it
 // Returns nil if the stack is empty
 func (this *Stack[T]) Peek() *T {
+	this.lock.Lock()
+	defer this.lock.Unlock()
 	if this.length == 0 {
 		return nil
 	}
@@ -39,6 +57,8 @@ func (this *Stack[T]) Peek() *T {
 // Pop removes and returns a pointer to the top item of the stack
 // Returns nil if the stack is empty
 func (this *Stack[T]) Pop() *T {
+	this.lock.Lock()
+	defer this.lock.Unlock()
 	if this.length == 0 {
 		return nil
 	}
@@ -51,7 +71,33 @@ func (this *Stack[T]) Pop() *T {
 
 // Push adds a new value to the top of the stack
 func (this *Stack[T]) Push(value *T) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
 	n := &node[T]{value, this.top}
 	this.top = n
 	this.length++
 }
+
+// Clear removes all items from the stack
+func (this *Stack[T]) Clear() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.top = nil
+	this.length = 0
+}
+
+// All returns an iterator over the items from the top to the bottom of the stack
+// The items are those in the stack when the iteration starts, the stack can be changed during the iteration
+func (this *Stack[T]) All() iter.Seq[*T] {
+	return func(yield func(*T) bool) {
+		this.lock.Lock()
+		top := this.top
+		this.lock.Unlock()
+		// nodes are never modified, so the list from top stays the same
+		for n := top; n != nil; n = n.prev {
+			if !yield(n.value) {
+				return
+			}
+		}
+	}
+}
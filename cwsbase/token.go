@@ -0,0 +1,283 @@
+package cwsbase
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// tokenVersion prefixes tokens in the authenticated format, legacy AES-CBC tokens have no prefix
+const tokenVersion = "v2"
+
+var (
+	// ErrTokenMalformed is returned for tokens that cannot be decoded or fail authentication
+	ErrTokenMalformed = errors.New("malformed or tampered token")
+	// ErrTokenExpired is returned for tokens past their expiry
+	ErrTokenExpired = errors.New("token expired")
+	// ErrTokenAudience is returned for tokens issued for another audience
+	ErrTokenAudience = errors.New("token audience mismatch")
+	// ErrTokenKeyNotFound is returned for tokens encrypted with an unknown key id
+	ErrTokenKeyNotFound = errors.New("token key not found")
+)
+
+// AllowLegacyTokens lets DecryptToMap and DecryptToken read the legacy AES-CBC tokens of CRYPTO_KEY_HEX and CRYPTO_IV_HEX
+// Disable it once all legacy tokens have expired
+var AllowLegacyTokens = true
+
+// TokenOption sets an optional claim of a token created by EncryptToken
+type TokenOption func(*tokenPayload)
+
+// tokenPayload is the encrypted content of a token
+type tokenPayload struct {
+	Data      map[string]any `json:"data"`
+	ExpiresAt int64          `json:"exp,omitempty"`
+	Audience  string         `json:"aud,omitempty"`
+}
+
+// WithTokenTTL lets the token expire after ttl
+func WithTokenTTL(ttl time.Duration) TokenOption {
+	return func(p *tokenPayload) {
+		p.ExpiresAt = time.Now().Add(ttl).Unix()
+	}
+}
+
+// WithTokenExpiresAt lets the token expire at t
+func WithTokenExpiresAt(t time.Time) TokenOption {
+	return func(p *tokenPayload) {
+		p.ExpiresAt = t.Unix()
+	}
+}
+
+// WithTokenAudience restricts the token to an audience, e.g. "session" or "password-reset"
+// Only DecryptToken with the same audience accepts the token
+func WithTokenAudience(audience string) TokenOption {
+	return func(p *tokenPayload) {
+		p.Audience = audience
+	}
+}
+
+// EncryptToken encrypts a map into an authenticated token "v2.<key id>.<nonce and ciphertext>" with the keyring of GetKeyring
+// The map is sealed with AES-GCM under the primary key and a random nonce, so equal maps give different tokens
+// and any modification, including of the version and key id, is detected on decryption
+func EncryptToken(input map[string]any, opts ...TokenOption) (string, error) {
+	keyring, err := GetKeyring()
+	if err != nil {
+		return "", err
+	}
+	return keyring.EncryptToken(input, opts...)
+}
+
+// DecryptToken decrypts a token of EncryptToken with the keyring of GetKeyring and validates its claims
+// Tokens with an audience are only accepted if it is one of the audiences, expired tokens are rejected
+// Legacy AES-CBC tokens are accepted while AllowLegacyTokens is set and no audience is required
+func DecryptToken(token string, audiences ...string) (map[string]any, error) {
+	keyring, err := GetKeyring()
+	if err != nil {
+		return nil, err
+	}
+	return keyring.DecryptToken(token, audiences...)
+}
+
+// ReEncryptToken re-encrypts a token with the primary key of GetKeyring, see Keyring.ReEncryptToken
+func ReEncryptToken(token string) (string, bool, error) {
+	keyring, err := GetKeyring()
+	if err != nil {
+		return "", false, err
+	}
+	return keyring.ReEncryptToken(token)
+}
+
+// EncryptToken encrypts a map into an authenticated token with the primary key, see the package level EncryptToken
+func (k *Keyring) EncryptToken(input map[string]any, opts ...TokenOption) (string, error) {
+	payload := tokenPayload{Data: input}
+	for _, opt := range opts {
+		opt(&payload)
+	}
+	return k.sealToken(payload)
+}
+
+// DecryptToken decrypts a token encrypted with any key of the keyring, see the package level DecryptToken
+func (k *Keyring) DecryptToken(token string, audiences ...string) (map[string]any, error) {
+	if !isToken(token) && len(audiences) > 0 {
+		return nil, ErrTokenMalformed
+	}
+	payload, err := k.openToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if payload.Audience != "" || len(audiences) > 0 {
+		matched := false
+		for _, audience := range audiences {
+			matched = matched || audience == payload.Audience
+		}
+		if !matched {
+			return nil, ErrTokenAudience
+		}
+	}
+	return payload.Data, nil
+}
+
+// ReEncryptToken re-encrypts a token of an older key or a legacy token with the primary key, keeping its claims
+// changed is false if the token already uses the primary key, then the token is returned as is
+// Run it over stored tokens after a rotation, once none is left the older key can be removed
+func (k *Keyring) ReEncryptToken(token string) (reEncrypted string, changed bool, err error) {
+	payload, err := k.openToken(token)
+	if err != nil {
+		return "", false, err
+	}
+	if kid, ok := tokenKeyId(token); ok && kid == k.PrimaryId() {
+		return token, false, nil
+	}
+	reEncrypted, err = k.sealToken(payload)
+	if err != nil {
+		return "", false, err
+	}
+	return reEncrypted, true, nil
+}
+
+// sealToken encrypts the payload with the primary key
+func (k *Keyring) sealToken(payload tokenPayload) (string, error) {
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return k.seal(plaintext, "")
+}
+
+// openToken decrypts a token or a legacy token and rejects it if it is expired
+func (k *Keyring) openToken(token string) (tokenPayload, error) {
+	if !isToken(token) {
+		if !AllowLegacyTokens {
+			return tokenPayload{}, ErrTokenMalformed
+		}
+		data, err := decryptLegacyToken(token)
+		return tokenPayload{Data: data}, err
+	}
+	plaintext, err := k.open(token, "")
+	if err != nil {
+		return tokenPayload{}, err
+	}
+
+	var payload tokenPayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return tokenPayload{}, ErrTokenMalformed
+	}
+	if payload.ExpiresAt != 0 && time.Now().Unix() >= payload.ExpiresAt {
+		return tokenPayload{}, ErrTokenExpired
+	}
+	if payload.Data == nil {
+		payload.Data = map[string]any{}
+	}
+	return payload, nil
+}
+
+// seal encrypts plaintext with the primary key into "v2.<key id>.<nonce and ciphertext>"
+// The header and the purpose are authenticated, so ciphertexts of one purpose cannot be opened as another,
+// e.g. an encrypted field value chosen by a user cannot be passed as token
+func (k *Keyring) seal(plaintext []byte, purpose string) (string, error) {
+	kid, key := k.Primary()
+	if key == nil {
+		return "", ErrTokenKeyNotFound
+	}
+	aead, err := newTokenAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	header := tokenVersion + "." + kid + "."
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(header+purpose))
+	return header + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a ciphertext of seal with the key named in its header
+func (k *Keyring) open(ciphertext string, purpose string) ([]byte, error) {
+	if !isToken(ciphertext) {
+		return nil, ErrTokenMalformed
+	}
+	header, sealed, ok := splitToken(ciphertext)
+	if !ok {
+		return nil, ErrTokenMalformed
+	}
+
+	kid, _ := tokenKeyId(ciphertext)
+	key, ok := k.Key(kid)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTokenKeyNotFound, kid)
+	}
+	aead, err := newTokenAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrTokenMalformed
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(header+purpose))
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	return plaintext, nil
+}
+
+// isToken reports whether token is in the authenticated format, legacy tokens are base64 without a version prefix
+func isToken(token string) bool {
+	return strings.HasPrefix(token, tokenVersion+".")
+}
+
+// tokenKeyId returns the key id of a token in the authenticated format
+func tokenKeyId(token string) (string, bool) {
+	if !isToken(token) {
+		return "", false
+	}
+	i := strings.LastIndex(token, ".")
+	if i <= len(tokenVersion) {
+		return "", false
+	}
+	return token[len(tokenVersion)+1 : i], true
+}
+
+// splitToken returns the authenticated header "v2.<key id>." and the decoded nonce and ciphertext of a token
+func splitToken(token string) (string, []byte, bool) {
+	i := strings.LastIndex(token, ".")
+	if i <= len(tokenVersion) {
+		return "", nil, false
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil {
+		return "", nil, false
+	}
+	return token[:i+1], sealed, true
+}
+
+// newTokenAEAD returns AES-GCM with a 16, 24 or 32 byte key
+func newTokenAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptLegacyToken decrypts a token of the former AES-CBC EncryptMap
+func decryptLegacyToken(token string) (map[string]any, error) {
+	if GetEnv("CRYPTO_KEY_HEX", "") == "" || GetEnv("CRYPTO_IV_HEX", "") == "" {
+		return nil, ErrTokenMalformed
+	}
+	data, err := aESCBCPKCS5PaddingDecrypt(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTokenMalformed, err)
+	}
+	var output map[string]any
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTokenMalformed, err)
+	}
+	return output, nil
+}
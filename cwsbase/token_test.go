@@ -0,0 +1,174 @@
+package cwsbase
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setTokenKey(t *testing.T) {
+	t.Setenv("CRYPTO_KEY_HEX", "4d8f1e227bc2115d1008e98965abd753a420dd0d27a2ee66c284606981867ee0")
+	t.Setenv("CRYPTO_IV_HEX", "fdbfcd1c11e7ec1a2d7073e0f45b39c4")
+}
+
+// aESCBCPKCS5PaddingEncrypt encrypts a legacy AES-CBC token with PKCS5 padding, the counterpart of aESCBCPKCS5PaddingDecrypt
+// Returns base64 URL-encoded ciphertext
+func aESCBCPKCS5PaddingEncrypt(plaintext []byte, blockSize int) (string, error) {
+	key, err := hex.DecodeString(GetEnv[string]("CRYPTO_KEY_HEX"))
+	if err != nil {
+		return "", err
+	}
+	iv, err := hex.DecodeString(GetEnv[string]("CRYPTO_IV_HEX"))
+	if err != nil {
+		return "", err
+	}
+
+	bKey := []byte(key)
+	bIV := []byte(iv)
+	bPlaintext := pKCS5Padding(plaintext, blockSize)
+	block, err := aes.NewCipher(bKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext := make([]byte, len(bPlaintext))
+	mode := cipher.NewCBCEncrypter(block, bIV)
+	mode.CryptBlocks(ciphertext, bPlaintext)
+	return base64.URLEncoding.EncodeToString(ciphertext), nil
+}
+
+// pKCS5Padding adds PKCS5 padding to the input data
+func pKCS5Padding(ciphertext []byte, blockSize int) []byte {
+	padding := (blockSize - len(ciphertext)%blockSize)
+	padtext := bytes.Repeat([]byte{byte(padding)}, padding)
+	return append(ciphertext, padtext...)
+}
+
+func TestEncryptToken(t *testing.T) {
+	setTokenKey(t)
+	input := map[string]any{"user": "u1", "n": float64(3)}
+
+	first, err := EncryptMap(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := EncryptMap(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Error("equal maps produced equal tokens")
+	}
+	if !strings.HasPrefix(first, "v2."+keyFingerprint(mustHex(t, "4d8f1e227bc2115d1008e98965abd753a420dd0d27a2ee66c284606981867ee0"))+".") {
+		t.Errorf("token header = %q", first)
+	}
+
+	output, err := DecryptToMap(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output["user"] != "u1" || output["n"] != float64(3) {
+		t.Errorf("DecryptToMap() = %v", output)
+	}
+}
+
+func TestDecryptTokenClaims(t *testing.T) {
+	setTokenKey(t)
+	token := func(opts ...TokenOption) string {
+		s, err := EncryptToken(map[string]any{"a": "b"}, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	valid := token()
+	i := strings.LastIndex(valid, ".")
+	sealed, _ := base64.RawURLEncoding.DecodeString(valid[i+1:])
+	sealed[len(sealed)-1] ^= 1
+	tampered := valid[:i+1] + base64.RawURLEncoding.EncodeToString(sealed)
+
+	tests := []struct {
+		name      string
+		token     string
+		audiences []string
+		err       error
+	}{
+		{name: "no claims", token: token()},
+		{name: "not expired", token: token(WithTokenTTL(time.Minute))},
+		{name: "expired", token: token(WithTokenExpiresAt(time.Now().Add(-time.Second))), err: ErrTokenExpired},
+		{name: "audience", token: token(WithTokenAudience("session")), audiences: []string{"reset", "session"}},
+		{name: "wrong audience", token: token(WithTokenAudience("reset")), audiences: []string{"session"}, err: ErrTokenAudience},
+		{name: "audience not expected", token: token(WithTokenAudience("reset")), err: ErrTokenAudience},
+		{name: "audience required", token: token(), audiences: []string{"session"}, err: ErrTokenAudience},
+		{name: "tampered", token: tampered, err: ErrTokenMalformed},
+		{name: "invalid encoding", token: valid[:i+1] + "!!", err: ErrTokenMalformed},
+		{name: "garbage", token: "not a token", err: ErrTokenMalformed},
+		{name: "changed key id", token: strings.Replace(token(), "v2.", "v2.x", 1), err: ErrTokenKeyNotFound},
+		{name: "truncated", token: "v2.abc.AAAA", err: ErrTokenKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecryptToken(tt.token, tt.audiences...)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("DecryptToken() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDecryptLegacyToken(t *testing.T) {
+	setTokenKey(t)
+	data, _ := json.Marshal(map[string]any{"a": "b"})
+	legacy, err := aESCBCPKCS5PaddingEncrypt(data, aes.BlockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := base64.URLEncoding.DecodeString(legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := DecryptToMap(legacy)
+	if err != nil || output["a"] != "b" {
+		t.Fatalf("DecryptToMap() = %v, %v", output, err)
+	}
+	if _, err := DecryptToken(legacy, "session"); !errors.Is(err, ErrTokenMalformed) {
+		t.Errorf("legacy token accepted for an audience: %v", err)
+	}
+
+	AllowLegacyTokens = false
+	defer func() { AllowLegacyTokens = true }()
+	if _, err := DecryptToMap(legacy); !errors.Is(err, ErrTokenMalformed) {
+		t.Errorf("legacy token accepted after migration: %v", err)
+	}
+}
+
+func TestTokenKeyId(t *testing.T) {
+	setTokenKey(t)
+	t.Setenv("CRYPTO_KEY_ID", "2024-06")
+	token, err := EncryptMap(map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, "v2.2024-06.") {
+		t.Errorf("token header = %q", token)
+	}
+	t.Setenv("CRYPTO_KEY_ID", "2024-07")
+	if _, err := DecryptToMap(token); !errors.Is(err, ErrTokenKeyNotFound) {
+		t.Errorf("DecryptToMap() error = %v, want ErrTokenKeyNotFound", err)
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
@@ -0,0 +1,37 @@
+package cwslazymongo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// CommandMonitor is set on the clients created by GetMongoSingletonClient, nil disables command monitoring
+// e.g. cwslazymongo.CommandMonitor = cwslazymongo.NewSlogCommandMonitor(time.Second)
+var CommandMonitor *event.CommandMonitor
+
+// NewSlogCommandMonitor returns a command monitor logging failed commands at error level
+// and commands slower than slowThreshold at warn level, zero disables slow command logging
+// The records carry the request_id and trace_id of the operation context
+func NewSlogCommandMonitor(slowThreshold time.Duration) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			if slowThreshold > 0 && e.Duration > slowThreshold {
+				cwsbase.Logger(ctx).LogAttrs(ctx, slog.LevelWarn, "slow mongo command",
+					slog.String("command", e.CommandName),
+					slog.String("database", e.DatabaseName),
+					slog.Duration("elapsed", e.Duration))
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			cwsbase.Logger(ctx).LogAttrs(ctx, slog.LevelError, "mongo command failed",
+				slog.String("command", e.CommandName),
+				slog.String("database", e.DatabaseName),
+				slog.Duration("elapsed", e.Duration),
+				slog.String("error", e.Failure))
+		},
+	}
+}
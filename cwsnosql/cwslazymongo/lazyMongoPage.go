@@ -0,0 +1,73 @@
+package cwslazymongo
+
+import (
+	"context"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SelectPage selects a page of documents matching the filter ordered by _id and decodes them into T
+// In cursor mode the page continues after the _id stored in the cursor, otherwise it is selected by offset
+// The _id is stored as extended JSON in the cursor, so ObjectIDs and other id types keep their type
+func SelectPage[T any](ctx context.Context, repo *LazyMongoRepository, filter LazyMongoFilter, page cwsbase.PageRequest, opts ...*options.FindOptions) (cwsbase.Page[T], error) {
+	collection, err := repo.GetCollection(ctx)
+	if err != nil {
+		return cwsbase.Page[T]{}, err
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := collection.CountDocuments(ctx, filter.Build())
+		if err != nil {
+			return cwsbase.Page[T]{}, err
+		}
+		total = &count
+	}
+
+	pageOpts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(page.Limit() + 1))
+	if page.IsCursor() {
+		extJSON, ok := page.Cursor["_id"].(string)
+		if !ok {
+			return cwsbase.Page[T]{}, cwsbase.ErrInvalidCursor
+		}
+		var position bson.M
+		if err := bson.UnmarshalExtJSON([]byte(extJSON), true, &position); err != nil {
+			return cwsbase.Page[T]{}, cwsbase.ErrInvalidCursor
+		}
+		filter = append(filter[:len(filter):len(filter)], Gt("_id", position["_id"])...)
+	} else {
+		pageOpts.SetSkip(int64(page.Offset()))
+	}
+
+	cursor, err := collection.Find(ctx, filter.Build(), append(opts, pageOpts)...)
+	if err != nil {
+		return cwsbase.Page[T]{}, err
+	}
+	defer cursor.Close(ctx)
+
+	items := []T{}
+	ids := []any{}
+	for cursor.Next(ctx) {
+		var item T
+		if err := cursor.Decode(&item); err != nil {
+			return cwsbase.Page[T]{}, err
+		}
+		items = append(items, item)
+		ids = append(ids, cursor.Current.Lookup("_id"))
+	}
+	if err := cursor.Err(); err != nil {
+		return cwsbase.Page[T]{}, err
+	}
+
+	result, err := cwsbase.NewPage(page, items, func(last T) (map[string]any, error) {
+		extJSON, err := bson.MarshalExtJSON(bson.M{"_id": ids[page.Limit()-1]}, true, false)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"_id": string(extJSON)}, nil
+	})
+	result.Total = total
+	return result, err
+}
@@ -29,7 +29,11 @@ func GetMongoSingletonClient(url string, ctx context.Context) (*mongo.Client, er
 	defer lock.Unlock()
 
 	if _, ok := clients[url]; !ok {
-		client, err := mongo.Connect(ctx, options.Client().ApplyURI(url))
+		opts := options.Client().ApplyURI(url)
+		if CommandMonitor != nil {
+			opts.SetMonitor(CommandMonitor)
+		}
+		client, err := mongo.Connect(ctx, opts)
 		if err != nil {
 			return client, err
 		}
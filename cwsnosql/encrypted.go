@@ -0,0 +1,124 @@
+package cwsnosql
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// EncryptedString is a document field stored encrypted with the keyring of cwsbase.GetKeyring, e.g. national ids
+// The value is plain in memory and in JSON, only the stored field holds the ciphertext; the empty string is stored as is
+// Equal values give different ciphertexts, store a blind index field to search by value, e.g.
+//
+//	type Customer struct {
+//		Phone      cwsnosql.EncryptedString `bson:"phone"`
+//		PhoneIndex string                   `bson:"phone_index"`
+//	}
+//
+//	customer.PhoneIndex, err = customer.Phone.BlindIndex("phone")
+//	filter := cwslazymongo.Eq("phone_index", customer.PhoneIndex)
+type EncryptedString string
+
+// MarshalBSONValue encrypts the string into a BSON string
+func (s EncryptedString) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if s == "" {
+		return bson.MarshalValue("")
+	}
+	ciphertext, err := cwsbase.EncryptValue([]byte(s))
+	if err != nil {
+		return 0, nil, err
+	}
+	return bson.MarshalValue(ciphertext)
+}
+
+// UnmarshalBSONValue decrypts a BSON string
+func (s *EncryptedString) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	plaintext, err := unmarshalEncrypted(t, data)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
+
+// BlindIndex returns the blind index of the string in a domain, see cwsbase.BlindIndex
+func (s EncryptedString) BlindIndex(domain string) (string, error) {
+	return cwsbase.BlindIndex(domain, string(s))
+}
+
+// EncryptedDocument is a value of type T stored as encrypted BSON with the keyring of cwsbase.GetKeyring
+// Data is plain in memory and marshals to JSON as T, only the stored field holds the ciphertext
+type EncryptedDocument[T any] struct {
+	Data T
+}
+
+// encryptedDocumentValue wraps the value of an EncryptedDocument, so T need not be a document
+type encryptedDocumentValue[T any] struct {
+	Value T `bson:"v"`
+}
+
+// NewEncryptedDocument returns an EncryptedDocument holding data
+func NewEncryptedDocument[T any](data T) EncryptedDocument[T] {
+	return EncryptedDocument[T]{Data: data}
+}
+
+// MarshalBSONValue encrypts the BSON of Data into a BSON string
+func (e EncryptedDocument[T]) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	data, err := bson.Marshal(encryptedDocumentValue[T]{Value: e.Data})
+	if err != nil {
+		return 0, nil, err
+	}
+	ciphertext, err := cwsbase.EncryptValue(data)
+	if err != nil {
+		return 0, nil, err
+	}
+	return bson.MarshalValue(ciphertext)
+}
+
+// UnmarshalBSONValue decrypts a BSON string into Data
+func (e *EncryptedDocument[T]) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	plaintext, err := unmarshalEncrypted(t, data)
+	if err != nil {
+		return err
+	}
+	var value encryptedDocumentValue[T]
+	if len(plaintext) > 0 {
+		if err := bson.Unmarshal(plaintext, &value); err != nil {
+			return err
+		}
+	}
+	e.Data = value.Value
+	return nil
+}
+
+// MarshalJSON marshals Data
+func (e EncryptedDocument[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.Data)
+}
+
+// UnmarshalJSON unmarshals into Data
+func (e *EncryptedDocument[T]) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &e.Data)
+}
+
+// unmarshalEncrypted decrypts a BSON string, null and the empty string are empty
+func unmarshalEncrypted(t bsontype.Type, data []byte) ([]byte, error) {
+	switch t {
+	case bson.TypeNull, bson.TypeUndefined:
+		return nil, nil
+	case bson.TypeString:
+	default:
+		return nil, fmt.Errorf("failed to decode encrypted value of BSON type %s", t)
+	}
+	var ciphertext string
+	if err := (bson.RawValue{Type: t, Value: data}).Unmarshal(&ciphertext); err != nil {
+		return nil, err
+	}
+	if ciphertext == "" {
+		return nil, nil
+	}
+	return cwsbase.DecryptValue(ciphertext)
+}
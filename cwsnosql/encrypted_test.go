@@ -0,0 +1,65 @@
+package cwsnosql
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type encryptedTestAddress struct {
+	City string `bson:"city"`
+}
+
+type encryptedTestCustomer struct {
+	Id         string                                  `bson:"id"`
+	Phone      EncryptedString                         `bson:"phone"`
+	PhoneIndex string                                  `bson:"phone_index"`
+	Address    EncryptedDocument[encryptedTestAddress] `bson:"address"`
+	Tags       EncryptedDocument[[]string]             `bson:"tags"`
+	Note       EncryptedString                         `bson:"note"`
+}
+
+func TestEncryptedBSON(t *testing.T) {
+	t.Setenv("CRYPTO_KEY_HEX", "000102030405060708090a0b0c0d0e0f000102030405060708090a0b0c0d0e0f")
+	t.Setenv("CRYPTO_INDEX_KEY_HEX", "0f0e0d0c0b0a09080706050403020100")
+
+	customer := encryptedTestCustomer{
+		Id:      "c1",
+		Phone:   "+886912345678",
+		Address: NewEncryptedDocument(encryptedTestAddress{City: "Taipei"}),
+		Tags:    NewEncryptedDocument([]string{"vip"}),
+	}
+	var err error
+	if customer.PhoneIndex, err = customer.Phone.BlindIndex("phone"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := bson.Marshal(customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw bson.M
+	if err := bson.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"phone", "address", "tags"} {
+		if value, _ := raw[field].(string); !strings.HasPrefix(value, "v2.") {
+			t.Errorf("%s is stored as %v", field, raw[field])
+		}
+	}
+	if raw["note"] != "" {
+		t.Errorf("empty note is stored as %v", raw["note"])
+	}
+
+	var decoded encryptedTestCustomer
+	if err := bson.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Phone != customer.Phone || decoded.Address.Data.City != "Taipei" || len(decoded.Tags.Data) != 1 || decoded.Tags.Data[0] != "vip" {
+		t.Errorf("decoded = %+v", decoded)
+	}
+	if index, _ := EncryptedString("+886912345678").BlindIndex("phone"); index != decoded.PhoneIndex {
+		t.Error("blind index of the same value differs")
+	}
+}
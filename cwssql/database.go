@@ -1,13 +1,14 @@
 package cwssql
 
 import (
-	"encoding/json"
+	"reflect"
 	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 var db_instance *gorm.DB = nil
@@ -58,16 +59,10 @@ func GetPrimaryKeyValueMap(db *gorm.DB, model any) (WhereCaluse, error) {
 		return nil, err
 	}
 
-	var inInterface map[string]any
-	inrec, _ := json.Marshal(model)
-	json.Unmarshal(inrec, &inInterface)
 	var wc WhereCaluse
 	for _, field := range stmt.Schema.Fields {
 		if field.TagSettings["PRIMARYKEY"] == "PRIMARYKEY" {
-			value := inInterface[field.DBName]
-			if value == nil {
-				value = inInterface[field.Name] // field name might affect by json field tag
-			}
+			value := fieldValue(db, field, model)
 			if wc == nil {
 				wc = Eq(field.Name, value)
 				continue
@@ -98,18 +93,9 @@ func GetNonPrimaryKeyAssignments(db *gorm.DB, model any, excludeColumns ...strin
 		return nil, err
 	}
 
-	var inInterface map[string]any
-	inrec, _ := json.Marshal(model)
-	err := json.Unmarshal(inrec, &inInterface)
-	if err != nil {
-		return nil, err
-	}
 	var assignments []clause.Assignment
 	for _, field := range stmt.Schema.Fields {
 		if field.TagSettings["PRIMARYKEY"] != "PRIMARYKEY" && field.DBName != "" && field.DBName != "created_at" {
-			if field.DBName == "updated_at" {
-				inInterface[field.DBName] = time.Now()
-			}
 			exclue := false
 			for _, excludeColumn := range excludeColumns {
 				if field.DBName == excludeColumn || field.Name == excludeColumn {
@@ -120,19 +106,9 @@ func GetNonPrimaryKeyAssignments(db *gorm.DB, model any, excludeColumns ...strin
 			if exclue {
 				continue
 			}
-			value := inInterface[field.DBName]
-			if value == nil {
-				value = inInterface[field.Name] // field name might affect by json field tag
-			}
-			// When field type is json/jsonb, the value deserialized from JSON
-			// becomes []any or map[string]any. GORM will expand these as SQL
-			// records/tuples instead of a JSON string. Re-marshal back to a
-			// JSON string so GORM treats it as a scalar value.
-			switch value.(type) {
-			case []any, map[string]any:
-				if jsonBytes, err := json.Marshal(value); err == nil {
-					value = string(jsonBytes)
-				}
+			var value any = time.Now()
+			if field.DBName != "updated_at" {
+				value = fieldValue(db, field, model)
 			}
 			assignments = append(assignments, clause.Assignment{
 				Column: clause.Column{Name: field.DBName},
@@ -149,19 +125,10 @@ func GetPrimaryKeyAssignments(db *gorm.DB, model any) (clause.Set, error) {
 		return nil, err
 	}
 
-	var inInterface map[string]any
-	inrec, _ := json.Marshal(model)
-	err := json.Unmarshal(inrec, &inInterface)
-	if err != nil {
-		return nil, err
-	}
 	var assignments []clause.Assignment
 	for _, field := range stmt.Schema.Fields {
 		if field.TagSettings["PRIMARYKEY"] == "PRIMARYKEY" {
-			value := inInterface[field.DBName]
-			if value == nil {
-				value = inInterface[field.Name] // field name might affect by json field tag
-			}
+			value := fieldValue(db, field, model)
 			assignments = append(assignments, clause.Assignment{
 				Column: clause.Column{Name: field.DBName},
 				Value:  value,
@@ -170,3 +137,10 @@ func GetPrimaryKeyAssignments(db *gorm.DB, model any) (clause.Set, error) {
 	}
 	return assignments, nil
 }
+
+// fieldValue returns the value of field in model the way GORM writes it, e.g. a Valuer or serializer of the field
+// encrypts or encodes it when the statement is executed, unlike the JSON form of model
+func fieldValue(db *gorm.DB, field *schema.Field, model any) any {
+	value, _ := field.ValueOf(db.Statement.Context, reflect.Indirect(reflect.ValueOf(model)))
+	return value
+}
@@ -0,0 +1,123 @@
+package cwssql
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+)
+
+// EncryptedString is a string column stored encrypted with the keyring of cwsbase.GetKeyring, e.g. phone numbers
+// The value is plain in memory and in JSON, only the column holds the ciphertext; the empty string is stored as is
+// Equal values give different ciphertexts, add a blind index column to search by value, e.g.
+//
+//	type Customer struct {
+//		Phone      cwssql.EncryptedString `gorm:"type:text"`
+//		PhoneIndex string                 `gorm:"type:text;index"`
+//	}
+//
+//	func (c *Customer) BeforeSave(tx *gorm.DB) (err error) {
+//		c.PhoneIndex, err = c.Phone.BlindIndex("phone")
+//		return err
+//	}
+type EncryptedString string
+
+// Value encrypts the string for the column
+func (s EncryptedString) Value() (driver.Value, error) {
+	if s == "" {
+		return "", nil
+	}
+	return cwsbase.EncryptValue([]byte(s))
+}
+
+// Scan decrypts the column value
+func (s *EncryptedString) Scan(value any) error {
+	plaintext, err := scanEncrypted(value)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
+
+// GormDataType stores the ciphertext in a string column
+func (EncryptedString) GormDataType() string {
+	return "string"
+}
+
+// BlindIndex returns the blind index of the string in a domain, see cwsbase.BlindIndex
+// Search with the index of the value, e.g. repo.Get(cwssql.Eq("PhoneIndex", index))
+func (s EncryptedString) BlindIndex(domain string) (string, error) {
+	return cwsbase.BlindIndex(domain, string(s))
+}
+
+// EncryptedJSON is a value of type T stored as encrypted JSON with the keyring of cwsbase.GetKeyring
+// Data is plain in memory and marshals to JSON as T, only the column holds the ciphertext
+type EncryptedJSON[T any] struct {
+	Data T
+}
+
+// NewEncryptedJSON returns an EncryptedJSON holding data
+func NewEncryptedJSON[T any](data T) EncryptedJSON[T] {
+	return EncryptedJSON[T]{Data: data}
+}
+
+// Value encrypts the JSON of Data for the column
+func (e EncryptedJSON[T]) Value() (driver.Value, error) {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return nil, err
+	}
+	return cwsbase.EncryptValue(data)
+}
+
+// Scan decrypts the column value into Data
+func (e *EncryptedJSON[T]) Scan(value any) error {
+	plaintext, err := scanEncrypted(value)
+	if err != nil {
+		return err
+	}
+	var data T
+	if len(plaintext) > 0 {
+		if err := json.Unmarshal(plaintext, &data); err != nil {
+			return err
+		}
+	}
+	e.Data = data
+	return nil
+}
+
+// GormDataType stores the ciphertext in a string column
+func (EncryptedJSON[T]) GormDataType() string {
+	return "string"
+}
+
+// MarshalJSON marshals Data
+func (e EncryptedJSON[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.Data)
+}
+
+// UnmarshalJSON unmarshals into Data
+func (e *EncryptedJSON[T]) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &e.Data)
+}
+
+// scanEncrypted decrypts a scanned ciphertext, NULL and the empty string are empty
+func scanEncrypted(value any) ([]byte, error) {
+	var ciphertext string
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		ciphertext = v
+	case []byte:
+		ciphertext = string(v)
+	default:
+		return nil, fmt.Errorf("failed to scan encrypted value of type %T", value)
+	}
+	if ciphertext == "" {
+		return nil, nil
+	}
+	return cwsbase.DecryptValue(ciphertext)
+}
@@ -0,0 +1,102 @@
+package cwssql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"gorm.io/gorm/logger"
+)
+
+// SlogLogger is a GORM logger writing structured slog records correlated with the request id of the query context
+// Repository passes its context to every query, so SQL logged during a request carries its request_id and trace_id
+// e.g. gorm.Open(dialector, &gorm.Config{Logger: cwssql.NewSlogLogger(logger.Config{SlowThreshold: time.Second, LogLevel: logger.Warn})})
+type SlogLogger struct {
+	logger.Config
+	// Logger is the destination logger, nil means slog.Default()
+	Logger *slog.Logger
+}
+
+// NewSlogLogger returns a GORM logger writing to slog.Default(), Colorful is ignored
+func NewSlogLogger(config logger.Config) *SlogLogger {
+	return &SlogLogger{Config: config}
+}
+
+// logger returns the destination logger with the correlation attributes of ctx
+func (l *SlogLogger) logger(ctx context.Context) *slog.Logger {
+	if l.Logger != nil {
+		return cwsbase.LoggerWithContext(l.Logger, ctx)
+	}
+	return cwsbase.Logger(ctx)
+}
+
+// LogMode returns a copy of the logger with the given level
+func (l *SlogLogger) LogMode(level logger.LogLevel) logger.Interface {
+	copied := *l
+	copied.LogLevel = level
+	return &copied
+}
+
+// Info logs a message at info level
+func (l *SlogLogger) Info(ctx context.Context, msg string, data ...any) {
+	if l.LogLevel >= logger.Info {
+		l.logger(ctx).InfoContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+// Warn logs a message at warn level
+func (l *SlogLogger) Warn(ctx context.Context, msg string, data ...any) {
+	if l.LogLevel >= logger.Warn {
+		l.logger(ctx).WarnContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+// Error logs a message at error level
+func (l *SlogLogger) Error(ctx context.Context, msg string, data ...any) {
+	if l.LogLevel >= logger.Error {
+		l.logger(ctx).ErrorContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+// Trace logs failed queries at error level, slow queries at warn level and, at info log level, every query
+func (l *SlogLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.LogLevel <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	level := slog.LevelInfo
+	msg := "sql query"
+	switch {
+	case err != nil && l.LogLevel >= logger.Error && (!errors.Is(err, logger.ErrRecordNotFound) || !l.IgnoreRecordNotFoundError):
+		level = slog.LevelError
+		msg = "sql query failed"
+	case l.SlowThreshold != 0 && elapsed > l.SlowThreshold && l.LogLevel >= logger.Warn:
+		level = slog.LevelWarn
+		msg = "slow sql query"
+	case l.LogLevel < logger.Info:
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Duration("elapsed", elapsed),
+		slog.Int64("rows", rows),
+	}
+	if level == slog.LevelError {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	l.logger(ctx).LogAttrs(ctx, level, msg, attrs...)
+}
+
+// ParamsFilter leaves the query parameters out of the logged SQL when ParameterizedQueries is set
+func (l *SlogLogger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	if l.ParameterizedQueries {
+		return sql, nil
+	}
+	return sql, params
+}
@@ -0,0 +1,61 @@
+package cwssql
+
+import (
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+)
+
+// PasswordHash is a password hash column in the PHC format of cwsbase.HashPassword
+// It is never the plain password, set it with NewPasswordHash or SetPassword, e.g.
+//
+//	type Account struct {
+//		Account  string              `gorm:"type:text;primaryKey"`
+//		Password cwssql.PasswordHash `gorm:"type:text;not null" json:"-"`
+//	}
+type PasswordHash string
+
+// NewPasswordHash hashes a password with the policy of cwsbase.GetPasswordPolicy
+func NewPasswordHash(password string) (PasswordHash, error) {
+	hash, err := cwsbase.HashPassword(password)
+	return PasswordHash(hash), err
+}
+
+// SetPassword replaces the hash with a hash of password
+func (h *PasswordHash) SetPassword(password string) error {
+	hash, err := NewPasswordHash(password)
+	if err != nil {
+		return err
+	}
+	*h = hash
+	return nil
+}
+
+// Verify reports whether password matches the hash, see cwsbase.VerifyPassword
+func (h PasswordHash) Verify(password string) (bool, error) {
+	return cwsbase.VerifyPassword(password, string(h))
+}
+
+// NeedsRehash reports whether the hash was made with an outdated algorithm or parameters, see cwsbase.NeedsRehash
+func (h PasswordHash) NeedsRehash() bool {
+	return cwsbase.NeedsRehash(string(h))
+}
+
+// VerifyAndUpgrade verifies password and replaces an outdated hash with a hash of the current policy
+// upgraded reports that the hash changed and the model should be saved, e.g. on login:
+//
+//	ok, upgraded, err := account.Password.VerifyAndUpgrade(password)
+//	if ok && upgraded {
+//		err = repo.Upsert(account)
+//	}
+func (h *PasswordHash) VerifyAndUpgrade(password string) (ok bool, upgraded bool, err error) {
+	ok, rehashed, err := cwsbase.VerifyPasswordAndRehash(password, string(*h))
+	if err != nil || !ok || rehashed == "" {
+		return ok, false, err
+	}
+	*h = PasswordHash(rehashed)
+	return true, true, nil
+}
+
+// String hides the hash in logs and formatted output
+func (h PasswordHash) String() string {
+	return "[REDACTED]"
+}
@@ -6,9 +6,12 @@ import (
 	"encoding/json"
 	"errors"
 	"reflect"
+	"strings"
 
+	"github.com/codeworks-tw/cwsutil/cwsbase"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 // IRepository is a generic interface for database operations using GORM
@@ -24,6 +27,12 @@ type IRepository[T any] interface {
 	DeleteAll(whereClause ...WhereCaluse) ([]*T, error) // Delete all entities matching the where clauses
 	Refresh(entity *T) error                            // Refresh entity with latest data from database
 	Count(whereClause ...WhereCaluse) (int64, error)    // Count entities matching the where clauses
+	// Get a page of entities matching the where clauses ordered by primary key
+	GetPage(page cwsbase.PageRequest, whereClause ...WhereCaluse) (cwsbase.Page[*T], error)
+	// Get the rows of the entities matching the where clauses, the caller must close them
+	Rows(whereClause ...WhereCaluse) (*sql.Rows, error)
+	// Get an iterator over the entities matching the where clauses, the caller must close it
+	Iterate(whereClause ...WhereCaluse) (*RowIterator[T], error)
 	Transaction(fc func(repo Repository[T]) error, opts ...*sql.TxOptions) error
 }
 
@@ -45,8 +54,12 @@ func (r *Repository[T]) isGenericPointer() bool {
 
 // GetGorm returns a GORM DB instance with applied where clauses and context
 // whereClauses: Optional where conditions to apply to the query
+// The context carries the request id to the GORM logger, see NewSlogLogger
 func (r *Repository[T]) GetGorm(whereClauses ...WhereCaluse) *gorm.DB {
 	query := r.db
+	if r.context != nil {
+		query = query.WithContext(r.context)
+	}
 	for _, wc := range whereClauses {
 		for key, values := range wc {
 			query = query.Where(key, values...)
@@ -92,9 +105,94 @@ func (r *Repository[T]) GetAll(whereClauses ...WhereCaluse) ([]*T, error) {
 	return entities, result.Error
 }
 
+// GetPage retrieves a page of entities matching the given where clauses, ordered by primary key
+// In cursor mode the page continues after the primary key stored in the cursor (keyset pagination),
+// otherwise the page is selected by offset
+// The next cursor holds the primary key of the last entity, so clients can switch from offset to cursor mode
+func (r *Repository[T]) GetPage(page cwsbase.PageRequest, whereClauses ...WhereCaluse) (cwsbase.Page[*T], error) {
+	if r.isGenericPointer() {
+		return cwsbase.Page[*T]{}, errors.New("generic type T must be a struct")
+	}
+	var model T
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(&model); err != nil {
+		return cwsbase.Page[*T]{}, err
+	}
+	primaryFields := stmt.Schema.PrimaryFields
+	if len(primaryFields) == 0 {
+		return cwsbase.Page[*T]{}, errors.New("entity has no primary key")
+	}
+
+	var total *int64
+	if page.WithTotal {
+		count, err := r.Count(whereClauses...)
+		if err != nil {
+			return cwsbase.Page[*T]{}, err
+		}
+		total = &count
+	}
+
+	query := r.GetGorm(whereClauses...)
+	columns := make([]string, 0, len(primaryFields))
+	values := make([]any, 0, len(primaryFields))
+	for _, field := range primaryFields {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: field.DBName}})
+		columns = append(columns, stmt.Quote(field.DBName))
+		if page.IsCursor() {
+			value, err := decodeCursorValue(field, page.Cursor[field.DBName])
+			if err != nil {
+				return cwsbase.Page[*T]{}, err
+			}
+			values = append(values, value)
+		}
+	}
+	if page.IsCursor() {
+		// row value comparison continues after the last primary key, also for composite keys
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+		query = query.Where("("+strings.Join(columns, ", ")+") > ("+placeholders+")", values...)
+	} else {
+		query = query.Offset(page.Offset())
+	}
+
+	var entities []*T
+	if err := query.Limit(page.Limit() + 1).Find(&entities).Error; err != nil {
+		return cwsbase.Page[*T]{}, err
+	}
+	result, err := cwsbase.NewPage(page, entities, func(last *T) (map[string]any, error) {
+		position := map[string]any{}
+		for _, field := range primaryFields {
+			value, _ := field.ValueOf(context.Background(), reflect.ValueOf(last).Elem())
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			position[field.DBName] = string(encoded)
+		}
+		return position, nil
+	})
+	result.Total = total
+	return result, err
+}
+
+// decodeCursorValue decodes a primary key value of a cursor into the type of the field
+// The cursor stores the JSON text of each value, since the cursor token itself is JSON and would turn
+// int64 keys into float64 and times or UUIDs into strings
+func decodeCursorValue(field *schema.Field, value any) (any, error) {
+	text, ok := value.(string)
+	if !ok {
+		return nil, cwsbase.ErrInvalidCursor
+	}
+	typed := reflect.New(field.FieldType)
+	if err := json.Unmarshal([]byte(text), typed.Interface()); err != nil {
+		return nil, cwsbase.ErrInvalidCursor
+	}
+	return typed.Elem().Interface(), nil
+}
+
 // Upsert performs an insert or update operation (create or replace)
 // If the entity exists (based on primary key), it updates the record
 // If the entity doesn't exist, it creates a new record
+// The updated values are the GORM field values, so Valuers, serializers and fields tagged json:"-" are written as on insert
 // excludeColumns: Column names to exclude from the update operation
 func (r *Repository[T]) Upsert(entity *T, excludeColumns ...string) error {
 	if entity == nil {
@@ -170,6 +268,27 @@ func (r *Repository[T]) DeleteAll(whereClauses ...WhereCaluse) ([]*T, error) {
 	return entities, result.Error
 }
 
+// Rows queries the entities matching the given where clauses without loading them into memory
+// The rows are not scanned, use Iterate or GetGorm().ScanRows to read entities from them
+// The caller must close the returned rows
+func (r *Repository[T]) Rows(whereClauses ...WhereCaluse) (*sql.Rows, error) {
+	if r.isGenericPointer() {
+		return nil, errors.New("generic type T must be a struct")
+	}
+	var model T
+	return r.GetGorm(whereClauses...).Model(&model).Rows()
+}
+
+// Iterate returns an iterator over the entities matching the given where clauses, reading one row at a time
+// The iterator can be streamed with cwsutil.WriteStream, the caller must close it
+func (r *Repository[T]) Iterate(whereClauses ...WhereCaluse) (*RowIterator[T], error) {
+	rows, err := r.Rows(whereClauses...)
+	if err != nil {
+		return nil, err
+	}
+	return NewRowIterator[T](r.GetGorm(), rows), nil
+}
+
 // Count returns the number of entities matching the given where clauses
 // Returns the count as int64 and any error that occurred during counting
 func (r *Repository[T]) Count(whereClauses ...WhereCaluse) (int64, error) {
@@ -0,0 +1,55 @@
+package cwssql
+
+import (
+	"context"
+	"database/sql"
+
+	"gorm.io/gorm"
+)
+
+// RowIterator iterates *sql.Rows and scans each row into an entity of type T
+// It satisfies cwsutil.StreamIterator[*T], so query results can be streamed with cwsutil.WriteStream
+type RowIterator[T any] struct {
+	db   *gorm.DB
+	rows *sql.Rows
+	err  error
+}
+
+// NewRowIterator returns an iterator over rows, db is the GORM DB the rows were queried with and scans the rows
+func NewRowIterator[T any](db *gorm.DB, rows *sql.Rows) *RowIterator[T] {
+	return &RowIterator[T]{db: db, rows: rows}
+}
+
+// Next advances to the next row, the iteration stops when ctx is done
+func (it *RowIterator[T]) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+	return it.rows.Next()
+}
+
+// Value scans the current row into a new entity
+func (it *RowIterator[T]) Value() (*T, error) {
+	var entity T
+	if err := it.db.ScanRows(it.rows, &entity); err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// Err returns the error that stopped the iteration
+func (it *RowIterator[T]) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.rows.Err()
+}
+
+// Close closes the rows
+func (it *RowIterator[T]) Close() error {
+	return it.rows.Close()
+}
@@ -3,18 +3,23 @@ package cwssql
 import (
 	"context"
 	"log"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	_ "ariga.io/atlas-provider-gorm/gormschema"
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 type Account struct {
-	WorkId   string `gorm:"type:text;primaryKey"`
-	Account  string `gorm:"type:text;primaryKey"`
-	Password string `gorm:"type:text;not null"`
-	Enable   bool   `gorm:"default:true"`
+	WorkId   string       `gorm:"type:text;primaryKey"`
+	Account  string       `gorm:"type:text;primaryKey"`
+	Password PasswordHash `gorm:"type:text;not null" json:"-"`
+	Enable   bool         `gorm:"default:true"`
 	BaseTimeModel
 }
 
@@ -45,6 +50,10 @@ func TestSqlRepository(t *testing.T) {
 
 	ctx := context.Background()
 	repo := NewAccountRepository(ctx, db)
+	password, err := NewPasswordHash("XXXX")
+	if err != nil {
+		panic(err)
+	}
 	err = repo.GetGorm().Transaction(func(tx *gorm.DB) error {
 
 		// err = repo.Begin()
@@ -53,13 +62,13 @@ func TestSqlRepository(t *testing.T) {
 		// }
 
 		account := &Account{
-			Password: "XXXX",
+			Password: password,
 			Account:  "test@xxx.com",
 			WorkId:   "XXXXXX",
 		}
 
 		account2 := &Account{
-			Password: "XXXX",
+			Password: password,
 			Account:  "test2@xxx.com",
 			WorkId:   "XXXXXX",
 		}
@@ -93,6 +102,9 @@ func TestSqlRepository(t *testing.T) {
 			panic(err)
 		}
 		log.Printf("Retrieved account: %+v", account)
+		if ok, err := account.Password.Verify("XXXX"); !ok || err != nil {
+			t.Errorf("Verify() = %v, %v", ok, err)
+		}
 
 		account, err = repo.GetAccount("")
 		if err != nil {
@@ -158,3 +170,143 @@ func TestSqlRepository(t *testing.T) {
 		log.Printf("Error rolling back transaction: %v", err)
 	}
 }
+
+// openTestDB opens a sqlite database of the test, apart from the shared instance of NewSQLiteDB
+func openTestDB(t *testing.T, models ...any) *gorm.DB {
+	t.Setenv("CRYPTO_KEY_HEX", "4d8f1e227bc2115d1008e98965abd753a420dd0d27a2ee66c284606981867ee0")
+	t.Setenv("CRYPTO_IV_HEX", "fdbfcd1c11e7ec1a2d7073e0f45b39c4")
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+type upsertTestItem struct {
+	Id     string    `gorm:"type:text;primaryKey" json:"id"`
+	Name   string    `json:"item_name"`
+	Tags   []string  `gorm:"serializer:json" json:"tags"`
+	Note   string    `json:"-"`
+	Locked string    `json:"locked"`
+	Due    time.Time `json:"due"`
+	BaseTimeModel
+}
+
+func TestUpsert(t *testing.T) {
+	db := openTestDB(t, &upsertTestItem{})
+	repo := NewRepository[upsertTestItem](context.Background(), db)
+
+	due := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := repo.Upsert(&upsertTestItem{Id: "i1", Name: "first", Tags: []string{"a"}, Note: "n1", Locked: "kept", Due: due}); err != nil {
+		t.Fatal(err)
+	}
+	inserted, err := repo.Get(Eq("Id", "i1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	// the update keeps created_at and the excluded column, and refreshes updated_at
+	if err := repo.Upsert(&upsertTestItem{Id: "i1", Name: "second", Tags: []string{"b", "c"}, Note: "n2", Locked: "changed", Due: due.Add(time.Hour)}, "locked"); err != nil {
+		t.Fatal(err)
+	}
+	item, err := repo.Get(Eq("Id", "i1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Name != "second" || strings.Join(item.Tags, ",") != "b,c" || item.Note != "n2" || item.Locked != "kept" || !item.Due.Equal(due.Add(time.Hour)) {
+		t.Errorf("item = %+v", item)
+	}
+	if !item.CreatedAt.Equal(inserted.CreatedAt) || !item.UpdatedAt.After(inserted.UpdatedAt) {
+		t.Errorf("created_at = %v, updated_at = %v, inserted %+v", item.CreatedAt, item.UpdatedAt, inserted.BaseTimeModel)
+	}
+
+	refreshed := &upsertTestItem{Id: "i1"}
+	if err := repo.Refresh(refreshed); err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.Name != "second" {
+		t.Errorf("refreshed = %+v", refreshed)
+	}
+}
+
+type pageTestEvent struct {
+	Id   int64     `gorm:"primaryKey;autoIncrement:false"`
+	At   time.Time `gorm:"primaryKey"`
+	Name string
+}
+
+func TestGetPageCursor(t *testing.T) {
+	repo := NewRepository[pageTestEvent](context.Background(), openTestDB(t, &pageTestEvent{}))
+	// ids above 2^53 differ only beyond float64 precision
+	base := int64(1<<53) + 1
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := int64(0); i < 5; i++ {
+		if err := repo.GetGorm().Create(&pageTestEvent{Id: base + i/2, At: at.Add(time.Duration(i) * time.Second)}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []int64
+	request := cwsbase.NewPageRequest(2)
+	for len(ids) <= 5 {
+		page, err := repo.GetPage(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, event := range page.Items {
+			ids = append(ids, event.Id-base)
+		}
+		if !page.HasMore {
+			break
+		}
+		if request.Cursor, err = cwsbase.DecodeCursor(page.NextCursor); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(ids, []int64{0, 0, 1, 1, 2}) {
+		t.Errorf("paged ids = %v, want [0 0 1 1 2]", ids)
+	}
+}
+
+type encryptedTestCustomer struct {
+	Id      string                           `gorm:"type:text;primaryKey"`
+	Phone   EncryptedString                  `gorm:"type:text"`
+	Profile EncryptedJSON[map[string]string] `gorm:"type:text"`
+}
+
+func TestUpsertEncrypted(t *testing.T) {
+	db := openTestDB(t, &encryptedTestCustomer{})
+	repo := NewRepository[encryptedTestCustomer](context.Background(), db)
+
+	// the second Upsert updates the existing row through the ON CONFLICT assignments
+	for _, phone := range []string{"+886911111111", "+886922222222"} {
+		customer := &encryptedTestCustomer{Id: "c1", Phone: EncryptedString(phone), Profile: NewEncryptedJSON(map[string]string{"phone": phone})}
+		if err := repo.Upsert(customer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var raw struct {
+		Phone   string
+		Profile string
+	}
+	if err := db.Raw("SELECT phone, profile FROM encrypted_test_customers WHERE id = ?", "c1").Scan(&raw).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, column := range []string{raw.Phone, raw.Profile} {
+		if !strings.HasPrefix(column, "v2.") || strings.Contains(column, "922222222") {
+			t.Errorf("column = %q, want a v2. ciphertext", column)
+		}
+	}
+	customer, err := repo.Get(Eq("Id", "c1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if customer.Phone != "+886922222222" || customer.Profile.Data["phone"] != "+886922222222" {
+		t.Errorf("customer = %+v", customer)
+	}
+}
@@ -0,0 +1,122 @@
+package cwswebhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codeworks-tw/cwsutil"
+	"github.com/gin-gonic/gin"
+)
+
+// ReplayCache remembers accepted signatures until they expire
+type ReplayCache interface {
+	// Seen records key until expiresAt and reports whether it was recorded already
+	Seen(ctx context.Context, key string, expiresAt time.Time) (bool, error)
+}
+
+// MemoryReplayCache is a ReplayCache of one instance, share a store between instances behind a load balancer
+type MemoryReplayCache struct {
+	lock    sync.Mutex
+	keys    map[string]time.Time
+	inserts int
+}
+
+// NewMemoryReplayCache returns an empty MemoryReplayCache
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{keys: map[string]time.Time{}}
+}
+
+// Seen records key until expiresAt, expired keys are dropped from time to time
+func (c *MemoryReplayCache) Seen(ctx context.Context, key string, expiresAt time.Time) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	if expiry, ok := c.keys[key]; ok && now.Before(expiry) {
+		return true, nil
+	}
+	c.keys[key] = expiresAt
+	if c.inserts++; c.inserts%1024 == 0 {
+		for k, expiry := range c.keys {
+			if !now.Before(expiry) {
+				delete(c.keys, k)
+			}
+		}
+	}
+	return false, nil
+}
+
+// VerifyConfig configures VerifyMiddleware
+type VerifyConfig struct {
+	// Secrets are the accepted signing secrets, several during a rotation
+	Secrets [][]byte
+	// Tolerance is the maximum age of a signature, DefaultTolerance if 0
+	Tolerance time.Duration
+	// ReplayCache rejects signatures accepted before, a MemoryReplayCache if nil
+	ReplayCache ReplayCache
+	// MaxBodySize limits the body read for verification, 1 MiB if 0
+	MaxBodySize int64
+}
+
+// VerifyMiddleware returns a middleware rejecting webhooks without a valid signature of SignatureHeader, or whose
+// signature was already accepted, with 401 Unauthorized; the body is restored for the handlers, e.g. for ParseBody
+// e.g. router.POST("/webhooks", cwswebhook.VerifyMiddleware(cwswebhook.VerifyConfig{Secrets: [][]byte{secret}}), handler)
+func VerifyMiddleware(config VerifyConfig) gin.HandlerFunc {
+	if config.Tolerance <= 0 {
+		config.Tolerance = DefaultTolerance
+	}
+	if config.ReplayCache == nil {
+		config.ReplayCache = NewMemoryReplayCache()
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 1 << 20
+	}
+	return func(c *gin.Context) {
+		if err := verifyRequest(c, config); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				cwsutil.BadRequestErrorResponse.EmbedError(err).WriteResponse(c)
+			} else {
+				cwsutil.UnauthorizedErrorResponse.EmbedError(err).WriteResponse(c)
+			}
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// verifyRequest checks the signature of the request and records it in the replay cache
+func verifyRequest(c *gin.Context, config VerifyConfig) error {
+	header := c.GetHeader(SignatureHeader)
+	if header == "" {
+		return ErrSignatureMissing
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, config.MaxBodySize))
+	if err != nil {
+		return err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(payload))
+
+	signedAt, signature, err := verifySignature(header, payload, config.Tolerance, time.Now(), config.Secrets)
+	if err != nil {
+		return err
+	}
+	// the key is the parsed timestamp and matched signature rather than the header, so reordered or
+	// extra header entries cannot pass the same signature again
+	key := strconv.FormatInt(signedAt.Unix(), 10) + "." + signature
+	// a signature older than the tolerance is rejected anyway, so it only needs to be remembered until then
+	seen, err := config.ReplayCache.Seen(c.Request.Context(), key, signedAt.Add(config.Tolerance))
+	if err != nil {
+		return err
+	}
+	if seen {
+		return ErrReplayed
+	}
+	return nil
+}
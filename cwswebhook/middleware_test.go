@@ -0,0 +1,59 @@
+package cwswebhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codeworks-tw/cwsutil"
+	"github.com/gin-gonic/gin"
+)
+
+func TestVerifyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cwsutil.InitBasicLocalizationData()
+
+	secret := []byte("whsec_test")
+	router := gin.New()
+	router.POST("/webhooks", VerifyMiddleware(VerifyConfig{Secrets: [][]byte{secret}, MaxBodySize: 64}), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+
+	payload := `{"order_id":"1"}`
+	signature := Sign(secret, time.Now(), []byte(payload))
+	timestamp, v1, _ := strings.Cut(signature, ",")
+	large := strings.Repeat("x", 65)
+	tests := []struct {
+		name      string
+		signature string
+		body      string
+		status    int
+	}{
+		{"valid", signature, payload, http.StatusOK},
+		{"replayed", signature, payload, http.StatusUnauthorized},
+		{"replayed reordered", v1 + "," + timestamp, payload, http.StatusUnauthorized},
+		{"replayed extra entry", signature + ",v1=00", payload, http.StatusUnauthorized},
+		{"missing signature", "", payload, http.StatusUnauthorized},
+		{"wrong secret", Sign([]byte("other"), time.Now(), []byte(payload)), payload, http.StatusUnauthorized},
+		{"tampered body", Sign(secret, time.Now().Add(-time.Second), []byte(payload)), `{"order_id":"2"}`, http.StatusUnauthorized},
+		{"too large", Sign(secret, time.Now(), []byte(large)), large, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(test.body))
+		if test.signature != "" {
+			req.Header.Set(SignatureHeader, test.signature)
+		}
+		router.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("%s: status = %d, want %d: %s", test.name, w.Code, test.status, w.Body.String())
+		}
+		if test.status == http.StatusOK && w.Body.String() != test.body {
+			t.Errorf("%s: handler read %q, want %q", test.name, w.Body.String(), test.body)
+		}
+	}
+}
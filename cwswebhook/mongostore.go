@@ -0,0 +1,85 @@
+package cwswebhook
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/codeworks-tw/cwsutil/cwsnosql/cwslazymongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore is a Store in two MongoDB collections, deliveries are keyed by _id
+// e.g. &cwswebhook.MongoStore{DeliveryRepo: &cwslazymongo.LazyMongoRepository{Url: url, DbName: "app", CollectionName: "webhook_deliveries"}, ...}
+type MongoStore struct {
+	DeliveryRepo *cwslazymongo.LazyMongoRepository
+	AttemptRepo  *cwslazymongo.LazyMongoRepository
+}
+
+// CreateIndexes creates the index of due deliveries and the unique index of attempts
+func (s *MongoStore) CreateIndexes(ctx context.Context) error {
+	_, err := s.DeliveryRepo.CreateIndex(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.AttemptRepo.CreateIndex(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "delivery_id", Value: 1}, {Key: "number", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// SaveDelivery upserts the delivery
+func (s *MongoStore) SaveDelivery(ctx context.Context, delivery *Delivery) error {
+	_, err := s.DeliveryRepo.Upsert(ctx, cwslazymongo.Eq("_id", delivery.Id), cwslazymongo.MarshalToUpdater(delivery))
+	return err
+}
+
+// GetDelivery returns the delivery with id
+func (s *MongoStore) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	delivery := &Delivery{}
+	err := s.DeliveryRepo.Get(ctx, cwslazymongo.Eq("_id", id), delivery)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// DueDeliveries returns the due pending deliveries
+func (s *MongoStore) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]*Delivery, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	filter := cwslazymongo.And(cwslazymongo.Eq("status", DeliveryPending), cwslazymongo.Lte("next_attempt_at", now))
+	cursor, err := s.DeliveryRepo.Select(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []*Delivery{}
+	return deliveries, cursor.All(ctx, &deliveries)
+}
+
+// AddAttempt inserts the attempt
+func (s *MongoStore) AddAttempt(ctx context.Context, attempt *Attempt) error {
+	_, err := s.AttemptRepo.Add(ctx, attempt)
+	return err
+}
+
+// Attempts returns the attempts of a delivery
+func (s *MongoStore) Attempts(ctx context.Context, deliveryId string) ([]*Attempt, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "number", Value: 1}})
+	cursor, err := s.AttemptRepo.Select(ctx, cwslazymongo.Eq("delivery_id", deliveryId), opts)
+	if err != nil {
+		return nil, err
+	}
+	attempts := []*Attempt{}
+	return attempts, cursor.All(ctx, &attempts)
+}
@@ -0,0 +1,214 @@
+package cwswebhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+)
+
+// DefaultRetrySchedule is the delay before each retry of a failed delivery, it gives up after the last one
+var DefaultRetrySchedule = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+}
+
+// SenderOption configures NewSender
+type SenderOption func(*Sender)
+
+// WithHttpClient sets the client sending the webhooks, by default a 10 second timeout without retries or
+// circuit breaker, retries are scheduled by the Sender instead
+// A request refused by an open circuit breaker of the client is not counted as attempt, the delivery stays due
+func WithHttpClient(client *cwsbase.HttpClient) SenderOption {
+	return func(s *Sender) {
+		s.client = client
+	}
+}
+
+// WithRetrySchedule replaces DefaultRetrySchedule, without delays failed deliveries are not retried
+func WithRetrySchedule(delays ...time.Duration) SenderOption {
+	return func(s *Sender) {
+		s.schedule = delays
+	}
+}
+
+// WithSecretFunc sets the signing secret of each delivery, e.g. one secret per partner
+func WithSecretFunc(secret func(ctx context.Context, delivery *Delivery) ([]byte, error)) SenderOption {
+	return func(s *Sender) {
+		s.secret = secret
+	}
+}
+
+// Sender signs and sends webhooks, persisting deliveries and attempts in a Store and retrying failures
+// Deliveries are sent at least once, receivers deduplicate them with the IdHeader
+type Sender struct {
+	store    Store
+	client   *cwsbase.HttpClient
+	schedule []time.Duration
+	secret   func(ctx context.Context, delivery *Delivery) ([]byte, error)
+	now      func() time.Time
+}
+
+// NewSender returns a Sender signing webhooks with secret
+func NewSender(store Store, secret []byte, opts ...SenderOption) *Sender {
+	s := &Sender{
+		store: store,
+		client: cwsbase.NewHttpClient(
+			cwsbase.WithHttpTimeout(10*time.Second),
+			cwsbase.WithHttpRetry(cwsbase.HttpRetryPolicy{MaxAttempts: 1}),
+			cwsbase.WithHttpCircuitBreaker(cwsbase.HttpCircuitBreakerConfig{}),
+		),
+		schedule: DefaultRetrySchedule,
+		secret: func(ctx context.Context, delivery *Delivery) ([]byte, error) {
+			return secret, nil
+		},
+		now: time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Send persists a delivery of the event with payload marshaled to JSON and makes the first attempt
+// A failed attempt is not an error, the delivery stays pending and is retried by RetryDue; only errors of
+// marshaling and of the store, and ErrHttpCircuitOpen of a client with circuit breaker, are returned
+func (s *Sender) Send(ctx context.Context, url string, event string, payload any) (*Delivery, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	delivery := &Delivery{
+		Id:            cwsbase.NewRequestId(),
+		URL:           url,
+		Event:         event,
+		Payload:       string(body),
+		Status:        DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.store.SaveDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, s.Deliver(ctx, delivery)
+}
+
+// Deliver makes one attempt of a delivery and records it, scheduling the next attempt on failure
+// The outcome is in the Status, LastStatusCode and LastError of delivery
+func (s *Sender) Deliver(ctx context.Context, delivery *Delivery) error {
+	secret, err := s.secret(ctx, delivery)
+	if err != nil {
+		return err
+	}
+	now := s.now().UTC()
+	payload := []byte(delivery.Payload)
+	start := time.Now()
+	resp, sendErr := s.client.Do(ctx, cwsbase.HttpRequest{
+		Method: http.MethodPost,
+		URL:    delivery.URL,
+		Header: http.Header{
+			"Content-Type":  {"application/json"},
+			SignatureHeader: {Sign(secret, now, payload)},
+			IdHeader:        {delivery.Id},
+			EventHeader:     {delivery.Event},
+		},
+		Body: payload,
+	})
+	if ctx.Err() != nil {
+		// the attempt was interrupted, the delivery stays due
+		return ctx.Err()
+	}
+	if errors.Is(sendErr, cwsbase.ErrHttpCircuitOpen) {
+		// nothing was sent, the delivery waits for the circuit without using up the retry schedule,
+		// so it does not hold back the due deliveries of other hosts
+		delivery.NextAttemptAt = now.Add(s.client.CircuitBreaker().OpenTimeout)
+		delivery.UpdatedAt = now
+		if err := s.store.SaveDelivery(ctx, delivery); err != nil {
+			return err
+		}
+		return sendErr
+	}
+
+	delivery.Attempts++
+	attempt := &Attempt{
+		DeliveryId:  delivery.Id,
+		Number:      delivery.Attempts,
+		DurationMs:  time.Since(start).Milliseconds(),
+		AttemptedAt: now,
+	}
+	var statusErr *cwsbase.HttpStatusError
+	switch {
+	case sendErr == nil:
+		attempt.StatusCode = resp.StatusCode
+	case errors.As(sendErr, &statusErr):
+		attempt.StatusCode = statusErr.StatusCode
+		attempt.Error = sendErr.Error()
+	default:
+		attempt.Error = sendErr.Error()
+	}
+
+	delivery.LastStatusCode = attempt.StatusCode
+	delivery.LastError = attempt.Error
+	delivery.UpdatedAt = now
+	switch {
+	case sendErr == nil:
+		delivery.Status = DeliverySucceeded
+	case delivery.Attempts > len(s.schedule):
+		delivery.Status = DeliveryFailed
+	default:
+		delivery.NextAttemptAt = now.Add(s.schedule[delivery.Attempts-1])
+	}
+
+	if err := s.store.AddAttempt(ctx, attempt); err != nil {
+		return err
+	}
+	return s.store.SaveDelivery(ctx, delivery)
+}
+
+// RetryDue makes the next attempt of up to limit due deliveries and returns the number of attempts
+// The errors of all deliveries are returned together
+func (s *Sender) RetryDue(ctx context.Context, limit int) (int, error) {
+	due, err := s.store.DueDeliveries(ctx, s.now().UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	attempted := 0
+	for _, delivery := range due {
+		if err := s.Deliver(ctx, delivery); err != nil {
+			if ctx.Err() != nil {
+				return attempted, ctx.Err()
+			}
+			errs = append(errs, err)
+			continue
+		}
+		attempted++
+	}
+	return attempted, errors.Join(errs...)
+}
+
+// Run calls RetryDue every interval until ctx is done, logging errors with cwsbase.Logger
+// Run it in one instance, or accept that concurrent workers may send a delivery twice
+func (s *Sender) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RetryDue(ctx, 100); err != nil && ctx.Err() == nil {
+			cwsbase.Logger(ctx).Error("webhook retry failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
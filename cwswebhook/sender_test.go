@@ -0,0 +1,140 @@
+package cwswebhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+)
+
+func TestSenderRetry(t *testing.T) {
+	secret := []byte("whsec_test")
+	var failing atomic.Bool
+	failing.Store(true)
+	var verifyErr atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		// the sender signs with the fixed clock of the test
+		if _, err := VerifySignature(r.Header.Get(SignatureHeader), body, 100000*time.Hour, secret); err != nil || r.Header.Get(EventHeader) != "order.paid" {
+			verifyErr.Store(r.Header.Get(SignatureHeader))
+		}
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	sender := NewSender(store, secret, WithRetrySchedule(time.Minute, time.Hour))
+	sender.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	delivery, err := sender.Send(ctx, server.URL, "order.paid", map[string]string{"order_id": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != DeliveryPending || delivery.Attempts != 1 || delivery.LastStatusCode != http.StatusServiceUnavailable || !delivery.NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("delivery after a failed attempt = %+v", delivery)
+	}
+
+	if n, err := sender.RetryDue(ctx, 10); n != 0 || err != nil {
+		t.Errorf("RetryDue() before the retry = %d, %v", n, err)
+	}
+	now = now.Add(time.Minute)
+	if n, err := sender.RetryDue(ctx, 10); n != 1 || err != nil {
+		t.Errorf("RetryDue() = %d, %v", n, err)
+	}
+	failing.Store(false)
+	now = now.Add(time.Hour)
+	if n, err := sender.RetryDue(ctx, 10); n != 1 || err != nil {
+		t.Errorf("RetryDue() = %d, %v", n, err)
+	}
+
+	stored, err := store.GetDelivery(ctx, delivery.Id)
+	if err != nil || stored.Status != DeliverySucceeded || stored.Attempts != 3 {
+		t.Errorf("GetDelivery() = %+v, %v", stored, err)
+	}
+	attempts, _ := store.Attempts(ctx, delivery.Id)
+	if len(attempts) != 3 || attempts[0].StatusCode != http.StatusServiceUnavailable || attempts[2].StatusCode != http.StatusOK || attempts[2].Number != 3 {
+		t.Errorf("Attempts() = %+v", attempts)
+	}
+	if header := verifyErr.Load(); header != nil {
+		t.Errorf("the receiver rejected the signature %v", header)
+	}
+}
+
+func TestSenderGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	store := NewMemoryStore()
+	sender := NewSender(store, []byte("whsec_test"), WithRetrySchedule(0))
+	ctx := context.Background()
+	delivery, err := sender.Send(ctx, server.URL, "order.paid", nil)
+	if err != nil || delivery.Status != DeliveryPending {
+		t.Fatalf("Send() = %+v, %v", delivery, err)
+	}
+	if n, err := sender.RetryDue(ctx, 10); n != 1 || err != nil {
+		t.Errorf("RetryDue() = %d, %v", n, err)
+	}
+	stored, _ := store.GetDelivery(ctx, delivery.Id)
+	if stored.Status != DeliveryFailed || stored.Attempts != 2 || stored.LastError == "" {
+		t.Errorf("delivery after the last retry = %+v", stored)
+	}
+	if due, _ := store.DueDeliveries(ctx, time.Now().Add(time.Hour), 10); len(due) != 0 {
+		t.Errorf("failed delivery is still due: %+v", due)
+	}
+}
+
+func TestSenderCircuitOpen(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	store := NewMemoryStore()
+	client := cwsbase.NewHttpClient(
+		cwsbase.WithHttpRetry(cwsbase.HttpRetryPolicy{MaxAttempts: 1}),
+		cwsbase.WithHttpCircuitBreaker(cwsbase.HttpCircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Hour}),
+	)
+	sender := NewSender(store, []byte("whsec_test"), WithHttpClient(client), WithRetrySchedule(0))
+	ctx := context.Background()
+	delivery, err := sender.Send(ctx, server.URL, "order.paid", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the open circuit refuses the retry without sending it, so it is not counted as the last attempt
+	if n, err := sender.RetryDue(ctx, 10); n != 0 || !errors.Is(err, cwsbase.ErrHttpCircuitOpen) {
+		t.Errorf("RetryDue() = %d, %v", n, err)
+	}
+	stored, _ := store.GetDelivery(ctx, delivery.Id)
+	if stored.Status != DeliveryPending || stored.Attempts != 1 || requests.Load() != 1 {
+		t.Errorf("delivery after the refused retry = %+v, %d requests", stored, requests.Load())
+	}
+	// the next attempt waits for the open timeout of the circuit
+	if stored.NextAttemptAt.Before(time.Now().Add(59 * time.Minute)) {
+		t.Errorf("next attempt at %v, want after the open timeout", stored.NextAttemptAt)
+	}
+	if n, err := sender.RetryDue(ctx, 10); n != 0 || err != nil {
+		t.Errorf("RetryDue() of the waiting delivery = %d, %v", n, err)
+	}
+
+	// the default client has no circuit breaker
+	sender = NewSender(store, []byte("whsec_test"), WithRetrySchedule(0, 0, 0, 0, 0, 0))
+	for i := 0; i < 6; i++ {
+		if _, err := sender.Send(ctx, server.URL, "order.paid", nil); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package cwswebhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader carries the signature of a webhook, e.g. "t=1700000000,v1=5257a8..."
+	SignatureHeader = "X-Webhook-Signature"
+	// IdHeader carries the delivery id, the same for every attempt of a delivery so receivers can deduplicate
+	IdHeader = "X-Webhook-Id"
+	// EventHeader carries the event type of a webhook, e.g. "order.paid"
+	EventHeader = "X-Webhook-Event"
+	// DefaultTolerance is the maximum age of a signature accepted by VerifySignature
+	DefaultTolerance = 5 * time.Minute
+)
+
+var (
+	// ErrSignatureMissing is returned when the request has no signature header
+	ErrSignatureMissing = errors.New("webhook signature missing")
+	// ErrSignatureMalformed is returned when the signature header cannot be parsed
+	ErrSignatureMalformed = errors.New("webhook signature malformed")
+	// ErrSignatureMismatch is returned when no signature matches the payload with any secret
+	ErrSignatureMismatch = errors.New("webhook signature mismatch")
+	// ErrSignatureExpired is returned when the timestamp of the signature is outside the tolerance
+	ErrSignatureExpired = errors.New("webhook signature timestamp outside tolerance")
+	// ErrReplayed is returned by VerifyMiddleware for a signature that was already accepted
+	ErrReplayed = errors.New("webhook replayed")
+)
+
+// computeSignature returns the hex HMAC-SHA256 of "<unix timestamp>.<payload>"
+func computeSignature(secret []byte, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns the signature header value of payload at timestamp, "t=<unix timestamp>,v1=<hex HMAC-SHA256>"
+// The timestamp is signed with the payload, so a captured request cannot be replayed after the tolerance
+func Sign(secret []byte, timestamp time.Time, payload []byte) string {
+	t := timestamp.Unix()
+	return "t=" + strconv.FormatInt(t, 10) + ",v1=" + computeSignature(secret, t, payload)
+}
+
+// VerifySignature checks a signature header of payload against the secrets and returns its timestamp
+// Several secrets and several v1 entries are accepted so secrets can be rotated without dropping webhooks
+// A tolerance of 0 uses DefaultTolerance
+func VerifySignature(header string, payload []byte, tolerance time.Duration, secrets ...[]byte) (time.Time, error) {
+	signedAt, _, err := verifySignature(header, payload, tolerance, time.Now(), secrets)
+	return signedAt, err
+}
+
+// verifySignature is VerifySignature at now, it also returns the hex v1 signature matching the first secret that matches
+// The order of the header entries and extra v1 entries do not change the timestamp and matched signature
+func verifySignature(header string, payload []byte, tolerance time.Duration, now time.Time, secrets [][]byte) (time.Time, string, error) {
+	if header == "" {
+		return time.Time{}, "", ErrSignatureMissing
+	}
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+
+	var timestamp int64
+	var hasTimestamp bool
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return time.Time{}, "", ErrSignatureMalformed
+		}
+		switch key {
+		case "t":
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return time.Time{}, "", ErrSignatureMalformed
+			}
+			timestamp, hasTimestamp = t, true
+		case "v1":
+			signature, err := hex.DecodeString(value)
+			if err != nil {
+				return time.Time{}, "", ErrSignatureMalformed
+			}
+			signatures = append(signatures, signature)
+		}
+	}
+	if !hasTimestamp || len(signatures) == 0 {
+		return time.Time{}, "", ErrSignatureMalformed
+	}
+
+	signedAt := time.Unix(timestamp, 0)
+	if age := now.Sub(signedAt); age > tolerance || age < -tolerance {
+		return signedAt, "", ErrSignatureExpired
+	}
+	for _, secret := range secrets {
+		expectedHex := computeSignature(secret, timestamp, payload)
+		expected, _ := hex.DecodeString(expectedHex)
+		for _, signature := range signatures {
+			if hmac.Equal(expected, signature) {
+				return signedAt, expectedHex, nil
+			}
+		}
+	}
+	return signedAt, "", ErrSignatureMismatch
+}
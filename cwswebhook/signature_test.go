@@ -0,0 +1,43 @@
+package cwswebhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("whsec_current")
+	oldSecret := []byte("whsec_old")
+	payload := []byte(`{"order_id":"1"}`)
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name    string
+		header  string
+		payload []byte
+		secrets [][]byte
+		wantErr error
+	}{
+		{"valid", Sign(secret, now, payload), payload, [][]byte{secret}, nil},
+		{"rotated secret", Sign(oldSecret, now, payload), payload, [][]byte{secret, oldSecret}, nil},
+		{"several signatures", Sign(secret, now, payload) + ",v1=" + computeSignature(oldSecret, now.Unix(), payload), payload, [][]byte{oldSecret}, nil},
+		{"tampered payload", Sign(secret, now, payload), []byte(`{"order_id":"2"}`), [][]byte{secret}, ErrSignatureMismatch},
+		{"wrong secret", Sign(oldSecret, now, payload), payload, [][]byte{secret}, ErrSignatureMismatch},
+		{"expired", Sign(secret, now.Add(-6*time.Minute), payload), payload, [][]byte{secret}, ErrSignatureExpired},
+		{"future", Sign(secret, now.Add(6*time.Minute), payload), payload, [][]byte{secret}, ErrSignatureExpired},
+		{"missing", "", payload, [][]byte{secret}, ErrSignatureMissing},
+		{"no timestamp", "v1=abcd", payload, [][]byte{secret}, ErrSignatureMalformed},
+		{"no signature", "t=1700000000", payload, [][]byte{secret}, ErrSignatureMalformed},
+		{"invalid hex", "t=1700000000,v1=zz", payload, [][]byte{secret}, ErrSignatureMalformed},
+	}
+	for _, test := range tests {
+		signedAt, _, err := verifySignature(test.header, test.payload, 0, now, test.secrets)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("%s: error = %v, want %v", test.name, err, test.wantErr)
+		}
+		if err == nil && !signedAt.Equal(now) {
+			t.Errorf("%s: timestamp = %v, want %v", test.name, signedAt, now)
+		}
+	}
+}
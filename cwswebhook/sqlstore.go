@@ -0,0 +1,64 @@
+package cwswebhook
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/codeworks-tw/cwsutil/cwssql"
+	"gorm.io/gorm"
+)
+
+// SQLStore is a Store in the webhook_deliveries and webhook_attempts tables of a GORM database
+type SQLStore struct {
+	db *gorm.DB
+}
+
+// NewSQLStore returns a SQLStore of db, call Migrate to create the tables
+func NewSQLStore(db *gorm.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// Migrate creates or updates the tables of deliveries and attempts
+func (s *SQLStore) Migrate() error {
+	return s.db.AutoMigrate(&Delivery{}, &Attempt{})
+}
+
+// SaveDelivery upserts the delivery
+func (s *SQLStore) SaveDelivery(ctx context.Context, delivery *Delivery) error {
+	repo := cwssql.NewRepository[Delivery](ctx, s.db)
+	return repo.Upsert(delivery)
+}
+
+// GetDelivery returns the delivery with id
+func (s *SQLStore) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	repo := cwssql.NewRepository[Delivery](ctx, s.db)
+	delivery, err := repo.Get(cwssql.Eq("id", id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeliveryNotFound
+	}
+	return delivery, err
+}
+
+// DueDeliveries returns the due pending deliveries using the idx_webhook_due index
+func (s *SQLStore) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]*Delivery, error) {
+	repo := cwssql.NewRepository[Delivery](ctx, s.db)
+	query := repo.GetGorm(cwssql.Eq("status", DeliveryPending).Lte("next_attempt_at", now)).Order("next_attempt_at")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	deliveries := []*Delivery{}
+	return deliveries, query.Find(&deliveries).Error
+}
+
+// AddAttempt inserts the attempt
+func (s *SQLStore) AddAttempt(ctx context.Context, attempt *Attempt) error {
+	return s.db.WithContext(ctx).Create(attempt).Error
+}
+
+// Attempts returns the attempts of a delivery
+func (s *SQLStore) Attempts(ctx context.Context, deliveryId string) ([]*Attempt, error) {
+	repo := cwssql.NewRepository[Attempt](ctx, s.db)
+	attempts := []*Attempt{}
+	return attempts, repo.GetGorm(cwssql.Eq("delivery_id", deliveryId)).Order("number").Find(&attempts).Error
+}
@@ -0,0 +1,42 @@
+package cwswebhook
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSQLStoreDueDeliveries(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "webhook.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewSQLStore(db)
+	if err := store.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	delivery := &Delivery{Id: "d1", URL: "https://example.com", Event: "order.paid", Status: DeliveryPending, NextAttemptAt: now, CreatedAt: now, UpdatedAt: now}
+	if err := store.SaveDelivery(ctx, delivery); err != nil {
+		t.Fatal(err)
+	}
+	// the second save updates the row, the due time must stay comparable with the time of DueDeliveries
+	delivery.Attempts = 1
+	delivery.NextAttemptAt = now.Add(time.Minute)
+	if err := store.SaveDelivery(ctx, delivery); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := store.DueDeliveries(ctx, now.Add(30*time.Second), 10); err != nil || len(due) != 0 {
+		t.Errorf("DueDeliveries() before the next attempt = %+v, %v", due, err)
+	}
+	due, err := store.DueDeliveries(ctx, now.Add(2*time.Minute), 10)
+	if err != nil || len(due) != 1 || due[0].Attempts != 1 {
+		t.Fatalf("DueDeliveries() = %+v, %v", due, err)
+	}
+}
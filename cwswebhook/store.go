@@ -0,0 +1,150 @@
+package cwswebhook
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrDeliveryNotFound is returned by Store.GetDelivery for an unknown delivery id
+var ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+// DeliveryStatus is the state of a Delivery
+type DeliveryStatus string
+
+const (
+	// DeliveryPending deliveries are sent at NextAttemptAt
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliverySucceeded deliveries got a 2xx response
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryFailed deliveries failed every attempt of the retry schedule
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// Delivery is a webhook sent to one endpoint, retried until it succeeds or the retry schedule is exhausted
+type Delivery struct {
+	Id    string `gorm:"type:text;primaryKey" json:"id" bson:"_id" dynamodbav:"Id"`
+	URL   string `gorm:"type:text;not null" json:"url" bson:"url" dynamodbav:"URL"`
+	Event string `gorm:"type:text;not null" json:"event" bson:"event" dynamodbav:"Event"`
+	// Payload is the JSON body sent to the endpoint
+	Payload string         `gorm:"type:text;not null" json:"payload" bson:"payload" dynamodbav:"Payload"`
+	Status  DeliveryStatus `gorm:"type:text;not null;index:idx_webhook_due,priority:1" json:"status" bson:"status" dynamodbav:"Status"`
+	// Attempts is the number of attempts made so far
+	Attempts      int       `gorm:"not null" json:"attempts" bson:"attempts" dynamodbav:"Attempts"`
+	NextAttemptAt time.Time `gorm:"index:idx_webhook_due,priority:2" json:"next_attempt_at" bson:"next_attempt_at" dynamodbav:"NextAttemptAt,unixtime"`
+	// LastStatusCode and LastError describe the outcome of the last attempt
+	LastStatusCode int       `json:"last_status_code,omitempty" bson:"last_status_code" dynamodbav:"LastStatusCode"`
+	LastError      string    `gorm:"type:text" json:"last_error,omitempty" bson:"last_error" dynamodbav:"LastError"`
+	CreatedAt      time.Time `json:"created_at" bson:"created_at" dynamodbav:"CreatedAt"`
+	UpdatedAt      time.Time `json:"updated_at" bson:"updated_at" dynamodbav:"UpdatedAt"`
+}
+
+// TableName is the table of deliveries in SQL databases
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// Attempt is one attempt of sending a Delivery
+type Attempt struct {
+	DeliveryId string `gorm:"type:text;primaryKey" json:"delivery_id" bson:"delivery_id" dynamodbav:"DeliveryId"`
+	// Number counts the attempts of a delivery from 1
+	Number     int    `gorm:"primaryKey;autoIncrement:false" json:"number" bson:"number" dynamodbav:"Number"`
+	StatusCode int    `json:"status_code,omitempty" bson:"status_code" dynamodbav:"StatusCode"`
+	Error      string `gorm:"type:text" json:"error,omitempty" bson:"error" dynamodbav:"Error"`
+	// DurationMs is the duration of the request in milliseconds
+	DurationMs  int64     `json:"duration_ms" bson:"duration_ms" dynamodbav:"DurationMs"`
+	AttemptedAt time.Time `json:"attempted_at" bson:"attempted_at" dynamodbav:"AttemptedAt"`
+}
+
+// TableName is the table of attempts in SQL databases
+func (Attempt) TableName() string {
+	return "webhook_attempts"
+}
+
+// Store persists deliveries and their attempts for a Sender
+// Implementations: MemoryStore, SQLStore, MongoStore and the DynamoDB store of cwsaws
+type Store interface {
+	// SaveDelivery creates or replaces a delivery
+	SaveDelivery(ctx context.Context, delivery *Delivery) error
+	// GetDelivery returns the delivery with id or ErrDeliveryNotFound
+	GetDelivery(ctx context.Context, id string) (*Delivery, error)
+	// DueDeliveries returns up to limit pending deliveries with NextAttemptAt not after now, the oldest first
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]*Delivery, error)
+	// AddAttempt records an attempt of a delivery
+	AddAttempt(ctx context.Context, attempt *Attempt) error
+	// Attempts returns the attempts of a delivery ordered by number
+	Attempts(ctx context.Context, deliveryId string) ([]*Attempt, error)
+}
+
+// MemoryStore is a Store keeping deliveries in memory, for tests and single instance services
+type MemoryStore struct {
+	lock       sync.RWMutex
+	deliveries map[string]Delivery
+	attempts   map[string][]Attempt
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{deliveries: map[string]Delivery{}, attempts: map[string][]Attempt{}}
+}
+
+// SaveDelivery stores a copy of the delivery
+func (s *MemoryStore) SaveDelivery(ctx context.Context, delivery *Delivery) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.deliveries[delivery.Id] = *delivery
+	return nil
+}
+
+// GetDelivery returns a copy of the delivery with id
+func (s *MemoryStore) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	delivery, ok := s.deliveries[id]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+	return &delivery, nil
+}
+
+// DueDeliveries returns copies of the due pending deliveries
+func (s *MemoryStore) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]*Delivery, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	due := []*Delivery{}
+	for _, delivery := range s.deliveries {
+		if delivery.Status == DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			d := delivery
+			due = append(due, &d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+// AddAttempt stores a copy of the attempt
+func (s *MemoryStore) AddAttempt(ctx context.Context, attempt *Attempt) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.attempts[attempt.DeliveryId] = append(s.attempts[attempt.DeliveryId], *attempt)
+	return nil
+}
+
+// Attempts returns copies of the attempts of a delivery
+func (s *MemoryStore) Attempts(ctx context.Context, deliveryId string) ([]*Attempt, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	attempts := make([]*Attempt, 0, len(s.attempts[deliveryId]))
+	for _, attempt := range s.attempts[deliveryId] {
+		a := attempt
+		attempts = append(attempts, &a)
+	}
+	return attempts, nil
+}
@@ -0,0 +1,133 @@
+package cwsutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+)
+
+// ErrorMatcher reports whether an error should be mapped to a registered response
+type ErrorMatcher func(err error) bool
+
+// errorCoder is implemented by errors carrying a service error code, e.g. smithy.APIError of the AWS SDK
+type errorCoder interface {
+	ErrorCode() string
+}
+
+// sqlStater is implemented by errors carrying a SQLSTATE code, e.g. *pgconn.PgError of the Postgres driver
+type sqlStater interface {
+	SQLState() string
+}
+
+// errorMapping maps the errors accepted by match to a localized response
+type errorMapping struct {
+	match    ErrorMatcher
+	response CWSLocalizedErrorResponse
+}
+
+var errorRegistryLock sync.RWMutex
+
+// errorRegistry holds the registered mappings, later registrations take precedence
+var errorRegistry = []errorMapping{
+	{match: matchErrorIs(gorm.ErrRecordNotFound), response: NotFoundErrorResponse},
+	{match: matchErrorIs(mongo.ErrNoDocuments), response: NotFoundErrorResponse},
+	{match: matchErrorIs(gorm.ErrDuplicatedKey), response: ConflictErrorResponse},
+	{match: mongo.IsDuplicateKeyError, response: ConflictErrorResponse},
+	{match: matchErrorCode("23505"), response: ConflictErrorResponse},
+	{match: matchErrorCode("ConditionalCheckFailedException"), response: ConflictErrorResponse},
+	{match: matchErrorIs(context.DeadlineExceeded), response: GatewayTimeoutErrorResponse},
+}
+
+// matchErrorIs returns a matcher accepting errors that wrap target
+func matchErrorIs(target error) ErrorMatcher {
+	return func(err error) bool {
+		return errors.Is(err, target)
+	}
+}
+
+// matchErrorCode returns a matcher accepting errors whose ErrorCode or SQLState equals code
+func matchErrorCode(code string) ErrorMatcher {
+	return func(err error) bool {
+		var coder errorCoder
+		if errors.As(err, &coder) && coder.ErrorCode() == code {
+			return true
+		}
+		var stater sqlStater
+		return errors.As(err, &stater) && stater.SQLState() == code
+	}
+}
+
+// RegisterErrorMatcher maps every error accepted by match to a localized response
+// Later registrations take precedence over earlier ones and over the built-in mappings
+func RegisterErrorMatcher(match ErrorMatcher, response CWSLocalizedErrorResponse) {
+	errorRegistryLock.Lock()
+	defer errorRegistryLock.Unlock()
+	errorRegistry = append(errorRegistry, errorMapping{match: match, response: response})
+}
+
+// RegisterErrorResponse maps a sentinel error, and every error wrapping it, to a localized response
+// e.g. cwsutil.RegisterErrorResponse(ErrOrderClosed, OrderClosedErrorResponse)
+func RegisterErrorResponse(target error, response CWSLocalizedErrorResponse) {
+	RegisterErrorMatcher(matchErrorIs(target), response)
+}
+
+// RegisterErrorType maps every error whose chain contains an error of type T to a localized response
+// e.g. cwsutil.RegisterErrorType[*types.TransactionCanceledException](ConflictErrorResponse)
+func RegisterErrorType[T error](response CWSLocalizedErrorResponse) {
+	RegisterErrorMatcher(func(err error) bool {
+		var target T
+		return errors.As(err, &target)
+	}, response)
+}
+
+// RegisterErrorCode maps errors carrying a service error code (ErrorCode method, e.g. AWS SDK errors)
+// or a SQLSTATE code (SQLState method, e.g. Postgres errors) to a localized response
+// e.g. cwsutil.RegisterErrorCode("23503", BadRequestErrorResponse) for foreign key violations
+func RegisterErrorCode(code string, response CWSLocalizedErrorResponse) {
+	RegisterErrorMatcher(matchErrorCode(code), response)
+}
+
+// ResolveErrorResponse returns the localized response registered for err with err embedded
+// Built-in mappings report record not found errors as 404, duplicate keys, Postgres unique violations
+// and DynamoDB conditional check failures as 409, and context deadlines as 504
+// Returns false if no mapping accepts err
+func ResolveErrorResponse(err error) (CWSLocalizedErrorResponse, bool) {
+	if err == nil {
+		return CWSLocalizedErrorResponse{}, false
+	}
+
+	errorRegistryLock.RLock()
+	defer errorRegistryLock.RUnlock()
+	for i := len(errorRegistry) - 1; i >= 0; i-- {
+		if errorRegistry[i].match(err) {
+			return errorRegistry[i].response.EmbedError(err), true
+		}
+	}
+	return CWSLocalizedErrorResponse{}, false
+}
+
+// reportedErrorKey is the gin context key of the last error sent by reportError
+const reportedErrorKey = "cws_reported_error"
+
+// reportError sends an error of the current request to the configured cwsbase.ErrorSink
+func reportError(ctx *gin.Context, err error, statusCode int, stack []byte, panicked bool) {
+	ctx.Set(reportedErrorKey, err)
+	requestId := GetRequestId(ctx)
+	if requestId == "" {
+		requestId = ctx.GetHeader(RequestIdHeader)
+	}
+	cwsbase.ReportError(requestContext(ctx), cwsbase.ErrorReport{
+		Err:        err,
+		Method:     ctx.Request.Method,
+		Path:       ctx.Request.URL.Path,
+		RequestId:  requestId,
+		StatusCode: statusCode,
+		Stack:      stack,
+		Panic:      panicked,
+	})
+}
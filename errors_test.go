@@ -0,0 +1,155 @@
+package cwsutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// testSQLStateError mimics *pgconn.PgError
+type testSQLStateError struct {
+	code string
+}
+
+func (e *testSQLStateError) Error() string    { return "sqlstate " + e.code }
+func (e *testSQLStateError) SQLState() string { return e.code }
+
+// testAPIError mimics smithy.APIError
+type testAPIError struct {
+	code string
+}
+
+func (e testAPIError) Error() string     { return "api error " + e.code }
+func (e testAPIError) ErrorCode() string { return e.code }
+
+func TestWrapHandlerErrorRegistry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+
+	errOrderClosed := errors.New("order closed")
+	RegisterErrorResponse(errOrderClosed, CWSLocalizedErrorResponse{StatusCode: http.StatusUnprocessableEntity, LocalCode: LocalCode_BadRequest})
+
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{name: "wrapped response", err: fmt.Errorf("load order: %w", ForbiddenErrorResponse), expected: http.StatusForbidden},
+		{name: "record not found", err: fmt.Errorf("load order: %w", gorm.ErrRecordNotFound), expected: http.StatusNotFound},
+		{name: "embedded not found", err: InternalServerErrorResponse.EmbedError(gorm.ErrRecordNotFound), expected: http.StatusNotFound},
+		{name: "bad request embedding not found", err: BadRequestErrorResponse.EmbedError(fmt.Errorf("load order: %w", gorm.ErrRecordNotFound)), expected: http.StatusNotFound},
+		{name: "application code embedding not found", err: CWSLocalizedErrorResponse{StatusCode: http.StatusBadRequest, LocalCode: "10001"}.EmbedError(gorm.ErrRecordNotFound), expected: http.StatusBadRequest},
+		{name: "registered sentinel", err: fmt.Errorf("pay: %w", errOrderClosed), expected: http.StatusUnprocessableEntity},
+		{name: "unique violation", err: fmt.Errorf("insert: %w", &testSQLStateError{code: "23505"}), expected: http.StatusConflict},
+		{name: "conditional check", err: testAPIError{code: "ConditionalCheckFailedException"}, expected: http.StatusConflict},
+		{name: "deadline", err: fmt.Errorf("query: %w", context.DeadlineExceeded), expected: http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", WrapHandler(func(ctx *gin.Context) error {
+				return tt.err
+			}))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.expected {
+				t.Errorf("status = %d, want %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWrapHandlerRecoversPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+
+	var reports []cwsbase.ErrorReport
+	cwsbase.SetErrorSink(cwsbase.ErrorSinkFunc(func(ctx context.Context, report cwsbase.ErrorReport) {
+		reports = append(reports, report)
+	}))
+	defer cwsbase.SetErrorSink(cwsbase.SlogErrorSink{})
+
+	r := gin.New()
+	r.GET("/orders/:id", WrapHandler(func(ctx *gin.Context) error {
+		var m map[string]int
+		m["boom"] = 1
+		return nil
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("reports = %d, want 1", len(reports))
+	}
+	report := reports[0]
+	if !report.Panic || report.Method != http.MethodGet || report.Path != "/orders/1" || report.RequestId != "req-1" || len(report.Stack) == 0 {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestWrapHandlerReportsErrorsAfterWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+
+	var reports []cwsbase.ErrorReport
+	cwsbase.SetErrorSink(cwsbase.ErrorSinkFunc(func(ctx context.Context, report cwsbase.ErrorReport) {
+		reports = append(reports, report)
+	}))
+	defer cwsbase.SetErrorSink(cwsbase.SlogErrorSink{})
+
+	failure := errors.New("connection reset")
+	r := gin.New()
+	r.GET("/partial", WrapHandler(func(ctx *gin.Context) error {
+		ctx.String(http.StatusOK, "partial")
+		return failure
+	}))
+	r.GET("/stream", WrapHandler(func(ctx *gin.Context) error {
+		err := WriteStream(ctx, http.StatusOK, LocalCode_OK, &failingIterator{items: []string{"a"}, failAt: 0})
+		return fmt.Errorf("stream orders: %w", err)
+	}))
+
+	r.GET("/canceled", WrapHandler(func(ctx *gin.Context) error {
+		ctx.String(http.StatusOK, "partial")
+		return fmt.Errorf("write orders: %w", context.Canceled)
+	}))
+
+	for _, path := range []string{"/partial", "/stream"} {
+		reports = nil
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want %d", path, w.Code, http.StatusOK)
+		}
+		if len(reports) != 1 {
+			t.Errorf("%s: reports = %+v, want one report", path, reports)
+		}
+	}
+
+	reports = nil
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/canceled", nil),
+		httptest.NewRequest(http.MethodGet, "/partial", nil).WithContext(canceledCtx),
+	} {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if len(reports) != 0 {
+		t.Errorf("reports of canceled requests = %+v, want none", reports)
+	}
+}
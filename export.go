@@ -0,0 +1,556 @@
+package cwsutil
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"github.com/gin-gonic/gin"
+)
+
+// ExportFormat is the file format of an export response
+type ExportFormat string
+
+const (
+	ExportFormatNDJSON ExportFormat = "ndjson"
+	ExportFormatCSV    ExportFormat = "csv"
+	ExportFormatXLSX   ExportFormat = "xlsx"
+)
+
+// exportContentTypes are the content types of the export formats, also matched against the Accept header
+var exportContentTypes = map[ExportFormat]string{
+	ExportFormatNDJSON: "application/x-ndjson",
+	ExportFormatCSV:    "text/csv",
+	ExportFormatXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ExportFormatQueryKey is the query parameter selecting the export format, e.g. ?format=csv
+var ExportFormatQueryKey = "format"
+
+// ContentType returns the content type of the format
+func (f ExportFormat) ContentType() string {
+	if f == ExportFormatCSV {
+		return exportContentTypes[f] + "; charset=utf-8"
+	}
+	return exportContentTypes[f]
+}
+
+// NegotiateExportFormat selects the export format from the ?format= query parameter, then the Accept header
+// defaultFormat is used when neither selects a supported format
+// Returns a 400 Bad Request CWSLocalizedErrorResponse if ?format= names an unsupported format
+func NegotiateExportFormat(c *gin.Context, defaultFormat ExportFormat) (ExportFormat, error) {
+	if value := c.Query(ExportFormatQueryKey); value != "" {
+		format := ExportFormat(strings.ToLower(value))
+		if _, ok := exportContentTypes[format]; !ok {
+			return defaultFormat, BadRequestErrorResponse.EmbedError(fmt.Errorf("unsupported export format: %q", value))
+		}
+		return format, nil
+	}
+
+	for _, accept := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		for format, contentType := range exportContentTypes {
+			if mediaType == contentType {
+				return format, nil
+			}
+		}
+	}
+	return defaultFormat, nil
+}
+
+// exportColumn is a column of an export, read from a struct field
+type exportColumn struct {
+	name   string
+	header string
+	order  int
+	index  []int
+}
+
+// exportColumns returns the columns of the struct type t in export order
+// The export tag configures a column: `export:"name,order=1,header=export.order.name"`
+// name defaults to the json name, header is a localization code and defaults to the name,
+// columns with an order come first in ascending order, the others follow in field order
+// Fields tagged export:"-" or json:"-" are left out, embedded structs are flattened
+func exportColumns(t reflect.Type) []exportColumn {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return []exportColumn{{name: "value", header: "value", order: -1}}
+	}
+
+	columns := []exportColumn{}
+	var collect func(t reflect.Type, index []int)
+	collect = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			fieldIndex := append(append([]int{}, index...), i)
+			tag, hasTag := field.Tag.Lookup("export")
+			jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if tag == "-" || (!hasTag && jsonName == "-") {
+				continue
+			}
+			if field.Anonymous && !hasTag {
+				ft := field.Type
+				if ft.Kind() == reflect.Struct {
+					collect(ft, fieldIndex)
+					continue
+				}
+			}
+			if !field.IsExported() {
+				continue
+			}
+
+			column := exportColumn{name: jsonName, order: -1, index: fieldIndex}
+			options := strings.Split(tag, ",")
+			if options[0] != "" {
+				column.name = options[0]
+			}
+			if column.name == "" {
+				column.name = field.Name
+			}
+			column.header = column.name
+			for _, option := range options[1:] {
+				key, value, _ := strings.Cut(option, "=")
+				switch key {
+				case "order":
+					column.order, _ = strconv.Atoi(value)
+				case "header":
+					column.header = value
+				}
+			}
+			columns = append(columns, column)
+		}
+	}
+	collect(t, nil)
+
+	sort.SliceStable(columns, func(i, j int) bool {
+		if columns[i].order < 0 || columns[j].order < 0 {
+			return columns[j].order < 0 && columns[i].order >= 0
+		}
+		return columns[i].order < columns[j].order
+	})
+	return columns
+}
+
+// exportHeaders returns the localized column headers, headers that are no localization code are used as they are
+func exportHeaders(ctx context.Context, columns []exportColumn) []string {
+	lang := cwsbase.LocalizationLanguageFromContext(ctx)
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = column.header
+		if column.header != column.name {
+			headers[i] = cwsbase.GetLocalizationMessageByLanguage(lang, cwsbase.LocalizationCode(column.header))
+		} else if message, ok := cwsbase.LookupLocalizationMessage(lang, cwsbase.LocalizationCode(column.header)); ok {
+			headers[i] = message
+		}
+	}
+	return headers
+}
+
+// exportValues returns the column values of an item, nil where a nil pointer is on the way to a field
+func exportValues(item any, columns []exportColumn) []any {
+	v := reflect.ValueOf(item)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return make([]any, len(columns))
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return []any{v.Interface()}
+	}
+
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		field, err := v.FieldByIndexErr(column.index)
+		if err != nil {
+			continue
+		}
+		for field.Kind() == reflect.Pointer || field.Kind() == reflect.Interface {
+			if field.IsNil() {
+				break
+			}
+			field = field.Elem()
+		}
+		if (field.Kind() == reflect.Pointer || field.Kind() == reflect.Interface) && field.IsNil() {
+			continue
+		}
+		values[i] = field.Interface()
+	}
+	return values
+}
+
+// exportText returns the text of a cell value
+func exportText(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	case []byte:
+		return string(v)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64)
+	}
+	d, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(d)
+}
+
+// csvText returns the text of a CSV cell, text starting like a formula is escaped by escapeFormula
+// Numbers keep their sign
+func csvText(value any) string {
+	text := exportText(value)
+	if _, ok := value.(fmt.Stringer); ok || value == nil {
+		return escapeFormula(text)
+	}
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return text
+	}
+	return escapeFormula(text)
+}
+
+// escapeFormula prefixes text starting with =, +, -, @, tab or carriage return with a quote,
+// so spreadsheet applications show it as text instead of running it as a formula
+// XLSX cells are typed and never run as formulas, so only CSV is escaped
+func escapeFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// exportWriter writes the rows of one export format
+type exportWriter interface {
+	// writeHeader writes the column headers
+	writeHeader(headers []string) error
+	// writeItem writes one item
+	writeItem(item any, values []any) error
+	// flush flushes buffered rows to the response
+	flush() error
+	// close finishes the file after all items are written
+	close() error
+	// fail finishes the file after the iteration failed
+	fail(ctx context.Context) error
+}
+
+// ExportFilename returns the Content-Disposition header value of an attachment named filename with the extension of the format
+// Names that are not ASCII are encoded as RFC 2231 extended parameter
+func ExportFilename(filename string, format ExportFormat) string {
+	if !strings.HasSuffix(strings.ToLower(filename), "."+string(format)) {
+		filename += "." + string(format)
+	}
+	if disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filename}); disposition != "" {
+		return disposition
+	}
+	return "attachment"
+}
+
+// WriteExport streams the items of an iterator as a file download in the format selected by NegotiateExportFormat
+// The format defaults to CSV, the extension of the format is appended to filename
+// CSV and XLSX columns are read from the struct fields of T, see the export tag in the README,
+// and the headers are localized in the request language, NDJSON writes every item as one JSON line
+// If the iteration fails mid-stream, the code of the 500 response is sent in the X-Stream-Error trailer,
+// NDJSON additionally ends with an {"error": {...}} line and XLSX files are left unfinished so they cannot be opened
+// The iteration stops when the client disconnects and the iterator is closed when the function returns
+func WriteExport[T any](c *gin.Context, filename string, it StreamIterator[T]) error {
+	format, err := NegotiateExportFormat(c, ExportFormatCSV)
+	if err != nil {
+		it.Close()
+		return err
+	}
+	return WriteExportFormat(c, format, filename, it)
+}
+
+// WriteExportFormat streams the items of an iterator as a file download in the given format, see WriteExport
+func WriteExportFormat[T any](c *gin.Context, format ExportFormat, filename string, it StreamIterator[T]) error {
+	defer it.Close()
+	reqCtx := requestContext(c)
+
+	var writer exportWriter
+	switch format {
+	case ExportFormatNDJSON:
+		writer = &ndjsonExportWriter{w: c.Writer}
+	case ExportFormatCSV:
+		writer = newCSVExportWriter(c.Writer)
+	case ExportFormatXLSX:
+		writer = &xlsxExportWriter{zip: zip.NewWriter(c.Writer)}
+	default:
+		return BadRequestErrorResponse.EmbedError(fmt.Errorf("unsupported export format: %q", format))
+	}
+
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", ExportFilename(filename, format))
+	c.Header("Trailer", StreamErrorTrailer)
+	c.Status(http.StatusOK)
+
+	columns := exportColumns(reflect.TypeOf((*T)(nil)).Elem())
+	err := func() error {
+		if err := writer.writeHeader(exportHeaders(reqCtx, columns)); err != nil {
+			return err
+		}
+		count := 0
+		for it.Next(reqCtx) {
+			if err := reqCtx.Err(); err != nil {
+				return err
+			}
+			item, err := it.Value()
+			if err != nil {
+				return err
+			}
+			if err := writer.writeItem(item, exportValues(item, columns)); err != nil {
+				return err
+			}
+			count++
+			if count%streamFlushInterval == 0 {
+				if err := writer.flush(); err != nil {
+					return err
+				}
+				c.Writer.Flush()
+			}
+		}
+		if err := it.Err(); err != nil {
+			return err
+		}
+		return reqCtx.Err()
+	}()
+
+	if err == nil {
+		err = writer.close()
+		c.Writer.Flush()
+		return err
+	}
+
+	if errors.Is(err, context.Canceled) {
+		// the client is gone, there is nobody to write the rest of the file to
+		return err
+	}
+	reportError(c, err, http.StatusInternalServerError, nil, false)
+	writer.fail(reqCtx)
+	c.Writer.Header().Set(StreamErrorTrailer, string(LocalCode_InternalServerError))
+	c.Writer.Flush()
+	return err
+}
+
+// ndjsonExportWriter writes one JSON document per line
+type ndjsonExportWriter struct {
+	w io.Writer
+}
+
+func (e *ndjsonExportWriter) writeHeader(headers []string) error {
+	return nil
+}
+
+func (e *ndjsonExportWriter) writeItem(item any, values []any) error {
+	d, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(d, '\n'))
+	return err
+}
+
+func (e *ndjsonExportWriter) flush() error {
+	return nil
+}
+
+func (e *ndjsonExportWriter) close() error {
+	return nil
+}
+
+func (e *ndjsonExportWriter) fail(ctx context.Context) error {
+	d, err := json.Marshal(map[string]any{"error": map[string]any{
+		"code":    LocalCode_InternalServerError,
+		"message": cwsbase.GetLocalizationMessageWithContext(ctx, LocalCode_InternalServerError),
+	}})
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(d, '\n'))
+	return err
+}
+
+// csvExportWriter writes comma separated values with a byte order mark, so spreadsheet applications detect UTF-8
+type csvExportWriter struct {
+	w   io.Writer
+	csv *csv.Writer
+}
+
+// newCSVExportWriter returns a CSV writer to w
+func newCSVExportWriter(w io.Writer) *csvExportWriter {
+	return &csvExportWriter{w: w, csv: csv.NewWriter(w)}
+}
+
+func (e *csvExportWriter) writeHeader(headers []string) error {
+	if _, err := io.WriteString(e.w, "\ufeff"); err != nil {
+		return err
+	}
+	return e.csv.Write(headers)
+}
+
+func (e *csvExportWriter) writeItem(item any, values []any) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = csvText(value)
+	}
+	return e.csv.Write(record)
+}
+
+func (e *csvExportWriter) flush() error {
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+func (e *csvExportWriter) close() error {
+	return e.flush()
+}
+
+func (e *csvExportWriter) fail(ctx context.Context) error {
+	return e.flush()
+}
+
+// xlsxStaticParts are the package parts of a workbook with a single worksheet using inline strings
+var xlsxStaticParts = []struct {
+	name    string
+	content string
+}{
+	{name: "[Content_Types].xml", content: xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{name: "_rels/.rels", content: xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{name: "xl/workbook.xml", content: xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`},
+	{name: "xl/_rels/workbook.xml.rels", content: xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// xlsxExportWriter writes an Office Open XML workbook, the worksheet is streamed row by row into the zip archive
+type xlsxExportWriter struct {
+	zip   *zip.Writer
+	sheet io.Writer
+}
+
+func (e *xlsxExportWriter) writeHeader(headers []string) error {
+	for _, part := range xlsxStaticParts {
+		w, err := e.zip.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, part.content); err != nil {
+			return err
+		}
+	}
+
+	sheet, err := e.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	e.sheet = sheet
+	if _, err := io.WriteString(e.sheet, xml.Header+`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return err
+	}
+	values := make([]any, len(headers))
+	for i, header := range headers {
+		values[i] = header
+	}
+	return e.writeRow(values)
+}
+
+func (e *xlsxExportWriter) writeItem(item any, values []any) error {
+	return e.writeRow(values)
+}
+
+// writeRow writes a worksheet row, numbers and booleans are typed cells and everything else is an inline string
+func (e *xlsxExportWriter) writeRow(values []any) error {
+	var row strings.Builder
+	row.WriteString("<row>")
+	for _, value := range values {
+		kind := reflect.Invalid
+		if value != nil {
+			kind = reflect.TypeOf(value).Kind()
+		}
+		if _, ok := value.(fmt.Stringer); ok {
+			kind = reflect.String
+		}
+		switch kind {
+		case reflect.Invalid:
+			row.WriteString("<c/>")
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			row.WriteString("<c><v>" + exportText(value) + "</v></c>")
+		case reflect.Bool:
+			cell := "0"
+			if reflect.ValueOf(value).Bool() {
+				cell = "1"
+			}
+			row.WriteString(`<c t="b"><v>` + cell + "</v></c>")
+		default:
+			row.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			xml.EscapeText(&row, []byte(exportText(value)))
+			row.WriteString("</t></is></c>")
+		}
+	}
+	row.WriteString("</row>")
+	_, err := io.WriteString(e.sheet, row.String())
+	return err
+}
+
+func (e *xlsxExportWriter) flush() error {
+	return e.zip.Flush()
+}
+
+func (e *xlsxExportWriter) close() error {
+	if _, err := io.WriteString(e.sheet, "</sheetData></worksheet>"); err != nil {
+		return err
+	}
+	return e.zip.Close()
+}
+
+func (e *xlsxExportWriter) fail(ctx context.Context) error {
+	// the central directory is not written, so the truncated workbook is not mistaken for a complete one
+	return e.zip.Flush()
+}
@@ -0,0 +1,153 @@
+package cwsutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"github.com/gin-gonic/gin"
+)
+
+type exportTestBase struct {
+	Id int `json:"id" export:"id,order=1"`
+}
+
+type exportTestItem struct {
+	exportTestBase
+	Name     string  `json:"name" export:"name,order=2,header=export.test.name"`
+	Note     *string `json:"note"`
+	Secret   string  `json:"-"`
+	Internal string  `json:"internal" export:"-"`
+	Active   bool    `json:"active"`
+}
+
+func TestNegotiateExportFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		query    string
+		accept   string
+		expected ExportFormat
+		invalid  bool
+	}{
+		{query: "", accept: "", expected: ExportFormatCSV},
+		{query: "format=xlsx", accept: "text/csv", expected: ExportFormatXLSX},
+		{query: "format=NDJSON", expected: ExportFormatNDJSON},
+		{query: "", accept: "application/x-ndjson;q=0.9, */*", expected: ExportFormatNDJSON},
+		{query: "", accept: "application/json", expected: ExportFormatCSV},
+		{query: "format=pdf", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query+" "+tt.accept, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+			c.Request.Header.Set("Accept", tt.accept)
+
+			actual, err := NegotiateExportFormat(c, ExportFormatCSV)
+			if (err != nil) != tt.invalid {
+				t.Fatalf("NegotiateExportFormat() error = %v", err)
+			}
+			if !tt.invalid && actual != tt.expected {
+				t.Errorf("NegotiateExportFormat() = %q, want %q", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWriteExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+	cwsbase.UpdateLocalizationData([]byte(`{"en": {"export.test.name": "Name"}, "zh_tw": {"export.test.name": "名稱"}}`))
+
+	note := "a, \"quoted\"\nnote"
+	command := "\tcmd"
+	items := []*exportTestItem{
+		{exportTestBase: exportTestBase{Id: 1}, Name: "<first>", Note: &note, Secret: "s", Internal: "i", Active: true},
+		{exportTestBase: exportTestBase{Id: 2}, Name: "second"},
+		{exportTestBase: exportTestBase{Id: -3}, Name: "=HYPERLINK(\"http://evil\")", Note: &command},
+	}
+
+	tests := []struct {
+		format      ExportFormat
+		contentType string
+		check       func(t *testing.T, body []byte)
+	}{
+		{format: ExportFormatCSV, contentType: "text/csv; charset=utf-8", check: func(t *testing.T, body []byte) {
+			if !bytes.HasPrefix(body, []byte("\ufeff")) {
+				t.Error("missing byte order mark")
+			}
+			records, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte("\ufeff")))).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := [][]string{{"id", "名稱", "note", "active"}, {"1", "<first>", note, "true"}, {"2", "second", "", "false"}, {"-3", "'=HYPERLINK(\"http://evil\")", "'\tcmd", "false"}}
+			if len(records) != len(expected) {
+				t.Fatalf("records = %q", records)
+			}
+			for i := range expected {
+				if strings.Join(records[i], "|") != strings.Join(expected[i], "|") {
+					t.Errorf("record %d = %q, want %q", i, records[i], expected[i])
+				}
+			}
+		}},
+		{format: ExportFormatNDJSON, contentType: "application/x-ndjson", check: func(t *testing.T, body []byte) {
+			lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+			if len(lines) != 3 || !strings.Contains(lines[0], `"id":1`) || strings.Contains(lines[0], "secret") {
+				t.Errorf("lines = %q", lines)
+			}
+		}},
+		{format: ExportFormatXLSX, contentType: exportContentTypes[ExportFormatXLSX], check: func(t *testing.T, body []byte) {
+			reader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range reader.File {
+				if file.Name != "xl/worksheets/sheet1.xml" {
+					continue
+				}
+				rc, err := file.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				sheet, _ := io.ReadAll(rc)
+				rc.Close()
+				for _, expected := range []string{"名稱", "&lt;first&gt;", "<c><v>2</v></c>", `<c t="b"><v>1</v></c>`, "<c><v>-3</v></c>", `<t xml:space="preserve">=HYPERLINK(`} {
+					if !strings.Contains(string(sheet), expected) {
+						t.Errorf("sheet does not contain %q: %s", expected, sheet)
+					}
+				}
+				return
+			}
+			t.Error("worksheet not found")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			ctx := cwsbase.ContextWithLocalizationLanguage(context.Background(), "zh_tw")
+			c.Request = httptest.NewRequest(http.MethodGet, "/?format="+string(tt.format), nil).WithContext(ctx)
+
+			if err := WriteExport(c, "報表", NewSliceIterator(items)); err != nil {
+				t.Fatal(err)
+			}
+			if w.Header().Get("Content-Type") != tt.contentType {
+				t.Errorf("Content-Type = %q", w.Header().Get("Content-Type"))
+			}
+			expected := "attachment; filename*=utf-8''%E5%A0%B1%E8%A1%A8." + string(tt.format)
+			if w.Header().Get("Content-Disposition") != expected {
+				t.Errorf("Content-Disposition = %q, want %q", w.Header().Get("Content-Disposition"), expected)
+			}
+			tt.check(t, w.Body.Bytes())
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package cwsutil
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details documents
+const ProblemContentType = "application/problem+json"
+
+// responseFormatterKey is the gin context key holding the formatter selected by ResponseFormatterMiddleware
+const responseFormatterKey = "cws_response_formatter"
+
+// ResponsePayload carries everything a ResponseFormatter needs to render a localized response
+type ResponsePayload struct {
+	// StatusCode is the HTTP status code of the response
+	StatusCode int
+	// LocalCode is the localization code of the response
+	LocalCode cwsbase.LocalizationCode
+	// Message is the message localized in the language of the request
+	Message string
+	// Data is the payload of a success response
+	Data any
+	// Error is the underlying error shown to the client, nil when it is hidden (5xx outside debug mode)
+	Error any
+	// Details contains machine-readable error details such as field validation errors, nil when unset
+	Details any
+	// Extensions contains additional top-level members of the response body
+	Extensions map[string]any
+}
+
+// ResponseFormatter renders localized responses into the HTTP response
+// Implementations decide the envelope and the content type of success and error responses
+type ResponseFormatter interface {
+	// WriteResponse writes a success response built from CWSLocalizedResponse or WriteResponse
+	WriteResponse(ctx *gin.Context, payload ResponsePayload)
+	// WriteErrorResponse writes an error response built from CWSLocalizedErrorResponse
+	WriteErrorResponse(ctx *gin.Context, payload ResponsePayload)
+}
+
+// StandardResponseFormatter renders the {code, message, data} and {code, message, error, details} envelopes
+type StandardResponseFormatter struct{}
+
+// WriteResponse writes the success envelope with code, message and data
+func (StandardResponseFormatter) WriteResponse(ctx *gin.Context, payload ResponsePayload) {
+	body := responseExtensions(payload)
+	body["code"] = payload.LocalCode
+	body["message"] = payload.Message
+	body["data"] = payload.Data
+	ctx.JSON(payload.StatusCode, body)
+}
+
+// WriteErrorResponse writes the error envelope with code, message, error and, when set, details
+func (StandardResponseFormatter) WriteErrorResponse(ctx *gin.Context, payload ResponsePayload) {
+	body := responseExtensions(payload)
+	body["code"] = payload.LocalCode
+	body["message"] = payload.Message
+	body["error"] = payload.Error
+	if payload.Details != nil {
+		body["details"] = payload.Details
+	}
+	ctx.JSON(payload.StatusCode, body)
+}
+
+// ProblemResponseFormatter renders error responses as RFC 7807 problem details (application/problem+json)
+// The localized message becomes the detail member, the request path the instance member,
+// and the localization code, error and details are added as extension members
+// Success responses are not problems and keep the standard envelope
+type ProblemResponseFormatter struct {
+	// TypeBaseURI prefixes the localization code to build the type member, e.g. "https://api.example.com/problems/"
+	// When empty the type member is "about:blank"
+	TypeBaseURI string
+}
+
+// WriteResponse writes the standard success envelope
+func (f ProblemResponseFormatter) WriteResponse(ctx *gin.Context, payload ResponsePayload) {
+	StandardResponseFormatter{}.WriteResponse(ctx, payload)
+}
+
+// WriteErrorResponse writes the problem details document with type, title, status, detail and instance
+func (f ProblemResponseFormatter) WriteErrorResponse(ctx *gin.Context, payload ResponsePayload) {
+	problemType := "about:blank"
+	if f.TypeBaseURI != "" {
+		problemType = f.TypeBaseURI + string(payload.LocalCode)
+	}
+
+	body := responseExtensions(payload)
+	body["type"] = problemType
+	body["title"] = http.StatusText(payload.StatusCode)
+	body["status"] = payload.StatusCode
+	body["detail"] = payload.Message
+	body["instance"] = ctx.Request.URL.Path
+	body["code"] = payload.LocalCode
+	if payload.Error != nil {
+		body["error"] = payload.Error
+	}
+	if payload.Details != nil {
+		body["details"] = payload.Details
+	}
+	ctx.Render(payload.StatusCode, problemJSON{body: body})
+}
+
+// problemJSON renders a JSON body with the problem+json content type
+type problemJSON struct {
+	body gin.H
+}
+
+// Render writes the JSON encoded body, the content type is set first so the JSON renderer keeps it
+func (r problemJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return render.JSON{Data: r.body}.Render(w)
+}
+
+// WriteContentType sets the problem+json content type
+func (r problemJSON) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ProblemContentType)
+}
+
+// responseExtensions copies the extension members of the payload into a new response body
+// Members written by the formatter afterwards take precedence over extensions with the same name
+func responseExtensions(payload ResponsePayload) gin.H {
+	body := gin.H{}
+	for k, v := range payload.Extensions {
+		body[k] = v
+	}
+	return body
+}
+
+var responseFormatterLock sync.RWMutex
+var responseFormatter ResponseFormatter = StandardResponseFormatter{}
+
+// SetResponseFormatter selects the formatter used by all localized responses
+// Route groups can override it with ResponseFormatterMiddleware
+func SetResponseFormatter(formatter ResponseFormatter) {
+	responseFormatterLock.Lock()
+	defer responseFormatterLock.Unlock()
+	responseFormatter = formatter
+}
+
+// ResponseFormatterMiddleware selects the formatter used by localized responses of the routes it is attached to
+// e.g. partnerAPI.Use(cwsutil.ResponseFormatterMiddleware(cwsutil.ProblemResponseFormatter{}))
+func ResponseFormatterMiddleware(formatter ResponseFormatter) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set(responseFormatterKey, formatter)
+		ctx.Next()
+	}
+}
+
+// GetResponseFormatter returns the formatter selected for the request, or the global one
+func GetResponseFormatter(ctx *gin.Context) ResponseFormatter {
+	if value, ok := ctx.Get(responseFormatterKey); ok {
+		if formatter, ok := value.(ResponseFormatter); ok {
+			return formatter
+		}
+	}
+	responseFormatterLock.RLock()
+	defer responseFormatterLock.RUnlock()
+	return responseFormatter
+}
@@ -0,0 +1,83 @@
+package cwsutil
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResponseFormatterMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+
+	handler := WrapHandler(func(ctx *gin.Context) error {
+		return BadRequestErrorResponse.EmbedError(errors.New("bad id")).Extension("retryable", false)
+	})
+
+	r := gin.New()
+	r.Use(LocalizationMiddleware())
+	r.GET("/v1/orders", handler)
+	partner := r.Group("/partner", ResponseFormatterMiddleware(ProblemResponseFormatter{TypeBaseURI: "https://example.com/problems/"}))
+	partner.GET("/orders", handler)
+
+	tests := []struct {
+		path        string
+		contentType string
+		expected    map[string]any
+	}{
+		{
+			path:        "/v1/orders",
+			contentType: "application/json; charset=utf-8",
+			expected: map[string]any{
+				"code":      "400",
+				"message":   "Bad request",
+				"error":     "bad id",
+				"retryable": false,
+			},
+		},
+		{
+			path:        "/partner/orders",
+			contentType: ProblemContentType,
+			expected: map[string]any{
+				"type":      "https://example.com/problems/400",
+				"title":     "Bad Request",
+				"status":    float64(400),
+				"detail":    "Bad request",
+				"instance":  "/partner/orders",
+				"code":      "400",
+				"error":     "bad id",
+				"retryable": false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if actual := w.Header().Get("Content-Type"); actual != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", actual, tt.contentType)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body) != len(tt.expected) {
+				t.Errorf("body = %v, want %v", body, tt.expected)
+			}
+			for k, v := range tt.expected {
+				if body[k] != v {
+					t.Errorf("body[%s] = %v, want %v", k, body[k], v)
+				}
+			}
+		})
+	}
+}
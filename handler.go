@@ -0,0 +1,130 @@
+package cwsutil
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ginContextKey is the context key holding the gin context of a typed handler
+type ginContextKey struct{}
+
+// HandlerOption configures a typed handler created by Handle
+type HandlerOption func(*handlerConfig)
+
+// handlerConfig holds the options of a typed handler
+type handlerConfig struct {
+	// response is the success response the result is written with
+	response CWSLocalizedResponse
+	// errors are the error responses documented for the route
+	errors []CWSLocalizedErrorResponse
+	// summary is the short description of the documented operation
+	summary string
+	// description is the long description of the documented operation
+	description string
+	// tags group the documented operation
+	tags []string
+	// operationId overrides the documented operation id
+	operationId string
+}
+
+// WithSuccessResponse sets the response used to write the result, the default is OKResponse
+// e.g. cwsutil.WithSuccessResponse(cwsutil.CWSLocalizedResponse{StatusCode: http.StatusCreated, LocalCode: LocalCode_OrderCreated})
+func WithSuccessResponse(response CWSLocalizedResponse) HandlerOption {
+	return func(c *handlerConfig) {
+		c.response = response
+	}
+}
+
+// newHandlerConfig applies the options to the default handler configuration
+func newHandlerConfig(opts []HandlerOption) handlerConfig {
+	config := handlerConfig{response: OKResponse}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// Handle adapts a typed function into a gin handler built on WrapHandler
+// The request is bound into Req from the query (form tags), headers (header tags), path (uri tags)
+// and body (json, xml or form tags depending on Content-Type), then validated once with the binding tags
+// Binding and validation failures are written as 400 Bad Request with localized field errors
+// The result is written as data of the localized success envelope, unless it is a CWSLocalizedResponse itself
+// Errors are handled like errors returned to WrapHandler
+// fn receives the request context, use GinContext to access the gin context when needed
+func Handle[Req any, Resp any](fn func(ctx context.Context, req Req) (Resp, error), opts ...HandlerOption) gin.HandlerFunc {
+	config := newHandlerConfig(opts)
+	return WrapHandler(func(c *gin.Context) error {
+		var req Req
+		if err := BindRequest(c, &req); err != nil {
+			return err
+		}
+
+		resp, err := fn(context.WithValue(requestContext(c), ginContextKey{}, c), req)
+		if err != nil {
+			return err
+		}
+		if localized, ok := any(resp).(CWSLocalizedResponse); ok {
+			localized.WriteResponse(c)
+			return nil
+		}
+		config.response.ResponseData(resp).WriteResponse(c)
+		return nil
+	})
+}
+
+// GinContext returns the gin context of a request handled by Handle
+// Returns false for contexts created outside a handler, e.g. in unit tests of the business function
+func GinContext(ctx context.Context) (*gin.Context, bool) {
+	c, ok := ctx.Value(ginContextKey{}).(*gin.Context)
+	return c, ok
+}
+
+// BindRequest binds the body, query, headers and path parameters of the request into req and validates it once
+// Values of the query, headers and path take precedence over fields of the same name in the body
+// req must be a pointer to a struct, the uri, form, header, json and binding tags are applied as in gin
+// Returns a 400 Bad Request CWSLocalizedErrorResponse with localized field errors if binding or validation fails
+func BindRequest(c *gin.Context, req any) error {
+	// the body is bound first so it cannot overwrite the values of the query, headers and path, e.g. {"Id":"1"}
+	if hasRequestBody(c.Request) {
+		if err := ignoreValidation(c.ShouldBindWith(req, binding.Default(c.Request.Method, c.ContentType()))); err != nil {
+			return bindingErrorResponse(c, err)
+		}
+	}
+	if err := ignoreValidation(c.ShouldBindWith(req, binding.Query)); err != nil {
+		return bindingErrorResponse(c, err)
+	}
+	if err := ignoreValidation(c.ShouldBindWith(req, binding.Header)); err != nil {
+		return bindingErrorResponse(c, err)
+	}
+	if err := ignoreValidation(c.ShouldBindUri(req)); err != nil {
+		return bindingErrorResponse(c, err)
+	}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return bindingErrorResponse(c, err)
+	}
+	return nil
+}
+
+// hasRequestBody reports whether the request carries a body to bind
+func hasRequestBody(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodOptions:
+		return false
+	}
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+// ignoreValidation drops validation errors of a partial binding since BindRequest validates the complete request once
+func ignoreValidation(err error) error {
+	var validationErrs validator.ValidationErrors
+	var sliceErrs binding.SliceValidationError
+	if errors.As(err, &validationErrs) || errors.As(err, &sliceErrs) {
+		return nil
+	}
+	return err
+}
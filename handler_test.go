@@ -0,0 +1,86 @@
+package cwsutil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type handlerTestRequest struct {
+	Id     string `uri:"id" binding:"required"`
+	Expand bool   `form:"expand"`
+	Tenant string `header:"X-Tenant-Id" binding:"required"`
+	Name   string `json:"name" binding:"required,min=2"`
+}
+
+type handlerTestResponse struct {
+	Id     string `json:"id"`
+	Tenant string `json:"tenant"`
+	Name   string `json:"name"`
+	Expand bool   `json:"expand"`
+}
+
+func updateHandlerTestItem(ctx context.Context, req handlerTestRequest) (handlerTestResponse, error) {
+	if req.Id == "missing" {
+		return handlerTestResponse{}, NotFoundErrorResponse
+	}
+	return handlerTestResponse{Id: req.Id, Tenant: req.Tenant, Name: req.Name, Expand: req.Expand}, nil
+}
+
+func TestHandle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+
+	r := gin.New()
+	r.PUT("/items/:id", Handle(updateHandlerTestItem))
+
+	tests := []struct {
+		name     string
+		path     string
+		tenant   string
+		body     string
+		status   int
+		expected handlerTestResponse
+	}{
+		{name: "bound", path: "/items/7?expand=true", tenant: "acme", body: `{"name":"box"}`, status: http.StatusOK, expected: handlerTestResponse{Id: "7", Tenant: "acme", Name: "box", Expand: true}},
+		{name: "body cannot overwrite path and header", path: "/items/7", tenant: "acme", body: `{"Id":"999","Tenant":"evil","name":"box"}`, status: http.StatusOK, expected: handlerTestResponse{Id: "7", Tenant: "acme", Name: "box"}},
+		{name: "invalid body", path: "/items/7", tenant: "acme", body: `{"name":"b"}`, status: http.StatusBadRequest},
+		{name: "missing header", path: "/items/7", body: `{"name":"box"}`, status: http.StatusBadRequest},
+		{name: "malformed body", path: "/items/7", tenant: "acme", body: `{`, status: http.StatusBadRequest},
+		{name: "error", path: "/items/missing", tenant: "acme", body: `{"name":"box"}`, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.tenant != "" {
+				req.Header.Set("X-Tenant-Id", tt.tenant)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var body struct {
+				Code string              `json:"code"`
+				Data handlerTestResponse `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != string(LocalCode_OK) || body.Data != tt.expected {
+				t.Errorf("body = %+v, want data %+v", body, tt.expected)
+			}
+		})
+	}
+}
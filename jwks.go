@@ -0,0 +1,252 @@
+package cwsutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrJWKNotFound is returned when no key matches the key id of a token
+var ErrJWKNotFound = errors.New("signing key not found")
+
+// jsonWebKey is a JSON Web Key as defined in RFC 7517
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	K   string `json:"k,omitempty"`
+}
+
+// jwkCurves are the elliptic curves of EC keys by crv name
+var jwkCurves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// publicKey returns the key usable with jwt: *rsa.PublicKey, *ecdsa.PublicKey or []byte for oct keys
+func (k jsonWebKey) publicKey() (any, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA key %q", k.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		curve, ok := jwkCurves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q of key %q", k.Crv, k.Kid)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if _, err := key.ECDH(); err != nil {
+			return nil, fmt.Errorf("invalid EC key %q: %w", k.Kid, err)
+		}
+		return key, nil
+	case "oct":
+		secret, err := decode(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf("invalid oct key %q", k.Kid)
+		}
+		return secret, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q of key %q", k.Kty, k.Kid)
+}
+
+// jwksFetchTimeout bounds a fetch of the key set
+const jwksFetchTimeout = 10 * time.Second
+
+// JWKS is a JSON Web Key Set fetched from a URL and cached
+// The keys are refreshed after RefreshInterval, and earlier when a token names an unknown key id
+// or no key set was fetched yet, at most once per MinRefreshInterval so unknown key ids or an unavailable
+// key server cannot flood the key server
+// When a refresh fails, the previously fetched keys stay in use
+type JWKS struct {
+	// URL is the location of the key set, e.g. "https://issuer.example.com/.well-known/jwks.json"
+	URL string
+	// HTTPClient fetches the key set, nil means http.DefaultClient, fetches time out after 10 seconds
+	HTTPClient *http.Client
+	// RefreshInterval is the maximum age of the cached keys, 1 hour when zero
+	RefreshInterval time.Duration
+	// MinRefreshInterval is the minimum time between refreshes, 1 minute when zero
+	MinRefreshInterval time.Duration
+
+	// lock guards the fields below, it is not held while the key set is fetched
+	lock    sync.Mutex
+	keys    map[string]any
+	err     error
+	fetched time.Time
+	// fetching is closed when the running fetch ends, nil while no fetch runs
+	fetching chan struct{}
+}
+
+// NewJWKS returns a key set fetched from url on first use
+func NewJWKS(url string) *JWKS {
+	return &JWKS{URL: url}
+}
+
+// Key returns the key with the key id, a token without key id matches a key set with a single key
+// Returns ErrJWKNotFound if there is no such key after refreshing
+func (j *JWKS) Key(ctx context.Context, kid string) (any, error) {
+	refreshInterval := j.RefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = time.Hour
+	}
+	minRefreshInterval := j.MinRefreshInterval
+	if minRefreshInterval <= 0 {
+		minRefreshInterval = time.Minute
+	}
+
+	err := j.refreshWhen(ctx, func() bool {
+		age := time.Since(j.fetched)
+		return age > refreshInterval || (j.keys == nil && age > minRefreshInterval)
+	})
+	if key, ok := j.lookup(kid); ok {
+		return key, nil
+	}
+	if err == nil {
+		err = j.refreshWhen(ctx, func() bool {
+			return time.Since(j.fetched) > minRefreshInterval
+		})
+		if key, ok := j.lookup(kid); ok {
+			return key, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrJWKNotFound, err)
+	}
+	return nil, ErrJWKNotFound
+}
+
+// Refresh fetches the key set now, or waits for the fetch already running
+func (j *JWKS) Refresh(ctx context.Context) error {
+	return j.refreshWhen(ctx, func() bool { return true })
+}
+
+// lookup returns the cached key of kid
+func (j *JWKS) lookup(kid string) (any, bool) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+// refreshWhen fetches the key set if due reports true, due is called with the lock held
+// Concurrent calls wait for the running fetch instead of fetching again
+// Returns the error of the fetch, or of the last fetch while no key set was fetched yet
+func (j *JWKS) refreshWhen(ctx context.Context, due func() bool) error {
+	j.lock.Lock()
+	if fetching := j.fetching; fetching != nil {
+		j.lock.Unlock()
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		j.lock.Lock()
+		defer j.lock.Unlock()
+		return j.err
+	}
+	if !due() {
+		defer j.lock.Unlock()
+		if j.keys == nil {
+			return j.err
+		}
+		return nil
+	}
+	// failed attempts count as well, so an unavailable key server is not asked on every request
+	j.fetched = time.Now()
+	fetching := make(chan struct{})
+	j.fetching = fetching
+	j.lock.Unlock()
+
+	// waiting requests share the fetch, so it does not end with the request that started it
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksFetchTimeout)
+	keys, err := j.fetch(fetchCtx)
+	cancel()
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if err == nil {
+		j.keys = keys
+	}
+	j.err = err
+	j.fetching = nil
+	close(fetching)
+	return err
+}
+
+// fetch downloads and parses the key set
+// Keys that cannot be parsed or are not meant for signatures are skipped
+func (j *JWKS) fetch(ctx context.Context) (map[string]any, error) {
+	client := j.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching JWKS %s: status %d", j.URL, resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decoding JWKS %s: %w", j.URL, err)
+	}
+	keys := map[string]any{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,74 @@
+package cwsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWKSTestServer serves a JSON Web Key Set of generated RSA and EC keys and signs tokens with them
+// It lets JWT authentication be tested offline, e.g.
+//
+//	server, _ := cwsutil.NewJWKSTestServer()
+//	defer server.Close()
+//	router.Use(cwsutil.JWTMiddleware[cwsutil.Claims](cwsutil.JWTConfig{JWKS: server.JWKS()}))
+//	token, _ := server.Sign(jwt.SigningMethodRS256, cwsutil.Claims{...})
+type JWKSTestServer struct {
+	*httptest.Server
+	// RSAKey signs RS256 tokens with the key id "test-rsa"
+	RSAKey *rsa.PrivateKey
+	// ECKey signs ES256 tokens with the key id "test-ec"
+	ECKey *ecdsa.PrivateKey
+}
+
+// NewJWKSTestServer generates the keys and starts the server, close it when done
+func NewJWKSTestServer() (*JWKSTestServer, error) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	size := (ecKey.Curve.Params().BitSize + 7) / 8
+	set := map[string]any{"keys": []jsonWebKey{
+		{Kty: "RSA", Kid: "test-rsa", Use: "sig", Alg: "RS256", N: encode(rsaKey.N.Bytes()), E: encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{Kty: "EC", Kid: "test-ec", Use: "sig", Alg: "ES256", Crv: "P-256", X: encode(ecKey.X.FillBytes(make([]byte, size))), Y: encode(ecKey.Y.FillBytes(make([]byte, size)))},
+	}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(set)
+	}))
+	return &JWKSTestServer{Server: server, RSAKey: rsaKey, ECKey: ecKey}, nil
+}
+
+// JWKS returns a key set fetching the keys of the server
+func (s *JWKSTestServer) JWKS() *JWKS {
+	return &JWKS{URL: s.URL, HTTPClient: s.Client()}
+}
+
+// Sign returns a token signed with RS256 or ES256 and the matching key id
+func (s *JWKSTestServer) Sign(method jwt.SigningMethod, claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(method, claims)
+	switch method.Alg() {
+	case jwt.SigningMethodRS256.Alg():
+		token.Header["kid"] = "test-rsa"
+		return token.SignedString(s.RSAKey)
+	case jwt.SigningMethodES256.Alg():
+		token.Header["kid"] = "test-ec"
+		return token.SignedString(s.ECKey)
+	}
+	return "", fmt.Errorf("unsupported signing method %s", method.Alg())
+}
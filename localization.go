@@ -0,0 +1,141 @@
+package cwsutil
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"github.com/gin-gonic/gin"
+)
+
+// LocalizationConfig configures how LocalizationMiddleware negotiates the language of a request
+type LocalizationConfig struct {
+	// QueryKey is the query parameter holding an explicit language (e.g. ?lang=zh_tw), empty disables it
+	QueryKey string
+	// CookieKey is the cookie holding an explicit language, empty disables it
+	CookieKey string
+	// Supported limits negotiation to these languages, empty means all languages with localization data
+	Supported []cwsbase.LocalizationLanguage
+}
+
+// DefaultLocalizationConfig checks the "lang" query parameter, then the "lang" cookie, then Accept-Language
+var DefaultLocalizationConfig = LocalizationConfig{
+	QueryKey:  "lang",
+	CookieKey: "lang",
+}
+
+// LocalizationMiddleware negotiates the response language of each request and stores it on the request context
+// The language is taken from the query parameter, the cookie and the Accept-Language header in that order
+// When nothing matches, the default language from LOCALIZATION_LANGUAGE is used
+// All localized responses written afterwards use the negotiated language
+func LocalizationMiddleware(config ...LocalizationConfig) gin.HandlerFunc {
+	cfg := DefaultLocalizationConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	return func(ctx *gin.Context) {
+		supported := cfg.Supported
+		if len(supported) == 0 {
+			supported = cwsbase.GetLocalizationLanguages()
+		}
+
+		lang, ok := cwsbase.LocalizationLanguage(""), false
+		if cfg.QueryKey != "" {
+			lang, ok = matchLanguage(ctx.Query(cfg.QueryKey), supported)
+		}
+		if !ok && cfg.CookieKey != "" {
+			if cookie, err := ctx.Cookie(cfg.CookieKey); err == nil {
+				lang, ok = matchLanguage(cookie, supported)
+			}
+		}
+		if !ok {
+			lang, ok = NegotiateLanguage(ctx.GetHeader("Accept-Language"), supported)
+		}
+		if !ok {
+			lang = cwsbase.GetDefaultLocalizationLanguage()
+		}
+
+		ctx.Request = ctx.Request.WithContext(cwsbase.ContextWithLocalizationLanguage(ctx.Request.Context(), lang))
+		ctx.Header("Content-Language", strings.ReplaceAll(string(lang), "_", "-"))
+		ctx.Next()
+	}
+}
+
+// GetRequestLanguage returns the language negotiated by LocalizationMiddleware for the current request
+// Falls back to the default language when the middleware is not installed
+func GetRequestLanguage(ctx *gin.Context) cwsbase.LocalizationLanguage {
+	return cwsbase.LocalizationLanguageFromContext(requestContext(ctx))
+}
+
+// NegotiateLanguage picks the best supported language from an Accept-Language header value
+// Language ranges are ordered by their q-values, ranges with q=0 are ignored and "*" matches the first supported language
+// Returns false if no range matches a supported language
+func NegotiateLanguage(acceptLanguage string, supported []cwsbase.LocalizationLanguage) (cwsbase.LocalizationLanguage, bool) {
+	type languageRange struct {
+		tag     string
+		quality float64
+	}
+
+	var ranges []languageRange
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.TrimSpace(key) == "q" {
+				q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, languageRange{tag: strings.TrimSpace(tag), quality: quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	for _, r := range ranges {
+		if r.tag == "*" {
+			if len(supported) > 0 {
+				return supported[0], true
+			}
+			continue
+		}
+		if lang, ok := matchLanguage(r.tag, supported); ok {
+			return lang, true
+		}
+	}
+	return "", false
+}
+
+// matchLanguage normalizes a language tag and reports whether it is one of the supported languages
+func matchLanguage(tag string, supported []cwsbase.LocalizationLanguage) (cwsbase.LocalizationLanguage, bool) {
+	lang := cwsbase.NormalizeLocalizationLanguage(tag)
+	if lang == "" {
+		return "", false
+	}
+	for _, s := range supported {
+		if s == lang {
+			return lang, true
+		}
+	}
+	return "", false
+}
+
+// requestContext returns the context of the underlying HTTP request, or a background context if there is none
+func requestContext(ctx *gin.Context) context.Context {
+	if ctx == nil || ctx.Request == nil {
+		return context.Background()
+	}
+	return ctx.Request.Context()
+}
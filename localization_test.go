@@ -0,0 +1,90 @@
+package cwsutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"github.com/gin-gonic/gin"
+)
+
+func TestNegotiateLanguage(t *testing.T) {
+	supported := []cwsbase.LocalizationLanguage{cwsbase.English, cwsbase.Taiwanese, cwsbase.Chinese}
+	tests := []struct {
+		name     string
+		header   string
+		expected cwsbase.LocalizationLanguage
+		ok       bool
+	}{
+		{name: "exact match", header: "zh_tw", expected: cwsbase.Taiwanese, ok: true},
+		{name: "region tag", header: "zh-TW,zh;q=0.9,en;q=0.8", expected: cwsbase.Taiwanese, ok: true},
+		{name: "script tag", header: "zh-Hans-CN", expected: cwsbase.Chinese, ok: true},
+		{name: "q-values reorder", header: "en;q=0.5, zh-TW;q=0.8", expected: cwsbase.Taiwanese, ok: true},
+		{name: "primary subtag", header: "en-US,en;q=0.9", expected: cwsbase.English, ok: true},
+		{name: "zero quality ignored", header: "zh-TW;q=0, en;q=0.1", expected: cwsbase.English, ok: true},
+		{name: "wildcard", header: "fr, *;q=0.1", expected: cwsbase.English, ok: true},
+		{name: "unsupported", header: "fr-FR, de", expected: "", ok: false},
+		{name: "empty", header: "", expected: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lang, ok := NegotiateLanguage(tt.header, supported)
+			if lang != tt.expected || ok != tt.ok {
+				t.Errorf("NegotiateLanguage(%q) = %v, %v, want %v, %v", tt.header, lang, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLocalizationMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+	cwsbase.SetLocalizationFallback(cwsbase.Taiwanese, cwsbase.Chinese, cwsbase.English)
+	defer cwsbase.SetLocalizationFallback()
+	cwsbase.UpdateLocalizationData([]byte(`{"zh_cn": {"test_fallback": "简体"}}`))
+
+	r := gin.New()
+	r.Use(LocalizationMiddleware())
+	r.GET("/ok", func(ctx *gin.Context) {
+		OKResponse.WriteResponse(ctx)
+	})
+	r.GET("/fallback", func(ctx *gin.Context) {
+		WriteResponse(ctx, http.StatusOK, "test_fallback", nil)
+	})
+
+	tests := []struct {
+		name     string
+		url      string
+		header   string
+		cookie   string
+		expected string
+	}{
+		{name: "accept-language", url: "/ok", header: "zh-TW,en;q=0.5", expected: "成功"},
+		{name: "query overrides header", url: "/ok?lang=en", header: "zh-TW", expected: "OK"},
+		{name: "cookie overrides header", url: "/ok", header: "en", cookie: "zh_cn", expected: "成功"},
+		{name: "fallback chain", url: "/fallback", header: "zh-TW", expected: "简体"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.Header.Set("Accept-Language", tt.header)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "lang", Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body["message"] != tt.expected {
+				t.Errorf("message = %v, want %v", body["message"], tt.expected)
+			}
+		})
+	}
+}
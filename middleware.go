@@ -0,0 +1,113 @@
+package cwsutil
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RequestIdHeader is the header carrying the request id in requests and responses
+	RequestIdHeader = "X-Request-ID"
+	// TraceParentHeader is the W3C trace context header
+	TraceParentHeader = "traceparent"
+)
+
+// responseLocalCodeKey is the gin context key holding the localization code of the written response
+const responseLocalCodeKey = "cws_response_local_code"
+
+// maxRequestIdLength limits request ids accepted from clients
+const maxRequestIdLength = 128
+
+// RequestIdMiddleware assigns or propagates the request id and the W3C traceparent of each request
+// The X-Request-ID header of the request is kept if it is a reasonable id, otherwise the trace id of a valid
+// traceparent or a new random id is used; a request without valid traceparent starts a new trace
+// Both are stored in the request context, see cwsbase.RequestIdFromContext and cwsbase.TraceParentFromContext,
+// and the request id is returned in the X-Request-ID response header and in the body of error responses
+func RequestIdMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		traceParent := ctx.GetHeader(TraceParentHeader)
+		traceId, _, ok := cwsbase.ParseTraceParent(traceParent)
+		if !ok {
+			traceParent = cwsbase.NewTraceParent()
+			traceId, _, _ = cwsbase.ParseTraceParent(traceParent)
+		}
+
+		requestId := ctx.GetHeader(RequestIdHeader)
+		if !isValidRequestId(requestId) {
+			requestId = traceId
+		}
+
+		reqCtx := cwsbase.ContextWithRequestId(ctx.Request.Context(), requestId)
+		reqCtx = cwsbase.ContextWithTraceParent(reqCtx, traceParent)
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+		ctx.Header(RequestIdHeader, requestId)
+		ctx.Next()
+	}
+}
+
+// isValidRequestId reports whether a client supplied request id is short and printable ASCII
+func isValidRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > maxRequestIdLength {
+		return false
+	}
+	for i := 0; i < len(requestId); i++ {
+		if requestId[i] < 0x21 || requestId[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// GetRequestId returns the request id assigned by RequestIdMiddleware, empty if the middleware is not used
+func GetRequestId(ctx *gin.Context) string {
+	return cwsbase.RequestIdFromContext(requestContext(ctx))
+}
+
+// AccessLogMiddleware logs one structured line per request after it is handled
+// The line holds method, path, route, status, localized response code, latency, size, client ip and the correlation ids
+// Server errors are logged at error level, client errors at warn level and everything else at info level
+// logger defaults to slog.Default(), attach the middleware after RequestIdMiddleware to include the request id
+func AccessLogMiddleware(logger ...*slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		path := ctx.Request.URL.Path
+		ctx.Next()
+
+		l := slog.Default()
+		if len(logger) > 0 && logger[0] != nil {
+			l = logger[0]
+		}
+
+		status := ctx.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		} else if status >= 400 {
+			level = slog.LevelWarn
+		}
+
+		reqCtx := requestContext(ctx)
+		attrs := []slog.Attr{
+			slog.String("method", ctx.Request.Method),
+			slog.String("path", path),
+			slog.String("route", ctx.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("size", max(ctx.Writer.Size(), 0)),
+			slog.String("client_ip", ctx.ClientIP()),
+		}
+		if code := ctx.GetString(responseLocalCodeKey); code != "" {
+			attrs = append(attrs, slog.String("code", code))
+		}
+		if requestId := cwsbase.RequestIdFromContext(reqCtx); requestId != "" {
+			attrs = append(attrs, slog.String("request_id", requestId))
+		}
+		if traceId, _, ok := cwsbase.ParseTraceParent(cwsbase.TraceParentFromContext(reqCtx)); ok {
+			attrs = append(attrs, slog.String("trace_id", traceId))
+		}
+		l.LogAttrs(reqCtx, level, "request", attrs...)
+	}
+}
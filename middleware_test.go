@@ -0,0 +1,90 @@
+package cwsutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestIdMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tests := []struct {
+		name        string
+		requestId   string
+		traceParent string
+		expected    string
+	}{
+		{name: "propagated", requestId: "abc-123", traceParent: traceParent, expected: "abc-123"},
+		{name: "from traceparent", traceParent: traceParent, expected: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{name: "invalid id", requestId: "bad id\n", traceParent: traceParent, expected: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{name: "generated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			router := gin.New()
+			router.Use(RequestIdMiddleware(), AccessLogMiddleware(slog.New(slog.NewJSONHandler(&logs, nil))))
+			router.GET("/orders/:id", WrapHandler(func(ctx *gin.Context) error {
+				return NotFoundErrorResponse.EmbedError(errors.New("order not found"))
+			}))
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+			req.Header.Set(RequestIdHeader, tt.requestId)
+			req.Header.Set(TraceParentHeader, tt.traceParent)
+			router.ServeHTTP(w, req)
+
+			requestId := w.Header().Get(RequestIdHeader)
+			if tt.expected != "" && requestId != tt.expected {
+				t.Errorf("X-Request-ID = %q, want %q", requestId, tt.expected)
+			}
+			if requestId == "" {
+				t.Fatal("no request id assigned")
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body["request_id"] != requestId {
+				t.Errorf("body request_id = %v, want %q", body["request_id"], requestId)
+			}
+
+			var line map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+				t.Fatalf("access log %q: %v", logs.String(), err)
+			}
+			expected := map[string]any{
+				"level":      "WARN",
+				"method":     http.MethodGet,
+				"path":       "/orders/1",
+				"route":      "/orders/:id",
+				"status":     float64(http.StatusNotFound),
+				"code":       string(LocalCode_NotFound),
+				"request_id": requestId,
+			}
+			for key, value := range expected {
+				if line[key] != value {
+					t.Errorf("access log %s = %v, want %v", key, line[key], value)
+				}
+			}
+			if tt.traceParent != "" && line["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Errorf("access log trace_id = %v", line["trace_id"])
+			}
+			if !strings.Contains(logs.String(), `"latency"`) {
+				t.Errorf("access log has no latency: %s", logs.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package cwsutil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPIOutputEnv is the environment variable holding the file WriteOpenAPIFromEnv writes the document to
+const OpenAPIOutputEnv = "OPENAPI_OUTPUT"
+
+// matchGinPathParam matches gin path parameters such as :id or *path
+var matchGinPathParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+// OpenAPIInfo is the info object of the generated OpenAPI document
+type OpenAPIInfo struct {
+	// Title is the title of the API
+	Title string `json:"title"`
+	// Version is the version of the API document
+	Version string `json:"version"`
+	// Description is an optional description of the API
+	Description string `json:"description,omitempty"`
+}
+
+// openAPIRoute is a typed route registered for documentation
+type openAPIRoute struct {
+	method   string
+	path     string
+	request  reflect.Type
+	response reflect.Type
+	config   handlerConfig
+	name     string
+}
+
+// OpenAPI collects the typed routes registered with Register and generates an OpenAPI 3.1 document
+type OpenAPI struct {
+	// Info is the info object of the document
+	Info OpenAPIInfo
+	// Servers are the optional base URLs of the API, e.g. "https://api.example.com"
+	Servers []string
+
+	lock   sync.RWMutex
+	routes []openAPIRoute
+}
+
+// NewOpenAPI returns an empty OpenAPI document collector
+func NewOpenAPI(info OpenAPIInfo, servers ...string) *OpenAPI {
+	return &OpenAPI{Info: info, Servers: servers}
+}
+
+// WithSummary sets the summary of the operation in the OpenAPI document
+func WithSummary(summary string) HandlerOption {
+	return func(c *handlerConfig) {
+		c.summary = summary
+	}
+}
+
+// WithDescription sets the description of the operation in the OpenAPI document
+func WithDescription(description string) HandlerOption {
+	return func(c *handlerConfig) {
+		c.description = description
+	}
+}
+
+// WithTags sets the tags grouping the operation in the OpenAPI document
+func WithTags(tags ...string) HandlerOption {
+	return func(c *handlerConfig) {
+		c.tags = append(c.tags, tags...)
+	}
+}
+
+// WithOperationId sets the operation id, the default is the name of the handler function
+func WithOperationId(operationId string) HandlerOption {
+	return func(c *handlerConfig) {
+		c.operationId = operationId
+	}
+}
+
+// WithErrorResponses documents the error responses the handler can return
+// 400 Bad Request for binding failures and 500 Internal Server Error are always documented
+func WithErrorResponses(responses ...CWSLocalizedErrorResponse) HandlerOption {
+	return func(c *handlerConfig) {
+		c.errors = append(c.errors, responses...)
+	}
+}
+
+// Register adds a typed handler to router with Handle and records the route in api for documentation
+// router is usually a *gin.Engine or *gin.RouterGroup, the group base path is included in the documented path
+// e.g. cwsutil.Register(api, v1, http.MethodGet, "/orders/:id", GetOrder, cwsutil.WithErrorResponses(cwsutil.NotFoundErrorResponse))
+func Register[Req any, Resp any](api *OpenAPI, router gin.IRoutes, method string, relativePath string, fn func(ctx context.Context, req Req) (Resp, error), opts ...HandlerOption) {
+	router.Handle(method, relativePath, Handle(fn, opts...))
+
+	fullPath := relativePath
+	if group, ok := router.(interface{ BasePath() string }); ok {
+		fullPath = joinRoutePath(group.BasePath(), relativePath)
+	}
+
+	name := ""
+	if f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()); f != nil {
+		name = f.Name()[strings.LastIndex(f.Name(), ".")+1:]
+	}
+
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	api.routes = append(api.routes, openAPIRoute{
+		method:   strings.ToUpper(method),
+		path:     fullPath,
+		request:  reflect.TypeOf((*Req)(nil)).Elem(),
+		response: reflect.TypeOf((*Resp)(nil)).Elem(),
+		config:   newHandlerConfig(opts),
+		name:     name,
+	})
+}
+
+// joinRoutePath joins a router group base path and a relative route path
+func joinRoutePath(basePath string, relativePath string) string {
+	if relativePath == "" {
+		return basePath
+	}
+	return strings.TrimSuffix(basePath, "/") + "/" + strings.TrimPrefix(relativePath, "/")
+}
+
+// Document builds the OpenAPI 3.1 document of the registered routes
+// Responses are described with the standard {code, message, data} envelope,
+// and the messages of error responses are localized in the default language
+func (api *OpenAPI) Document() map[string]any {
+	api.lock.RLock()
+	defer api.lock.RUnlock()
+
+	builder := newSchemaBuilder()
+	builder.component(reflect.TypeOf(FieldError{}))
+	builder.schemas["ErrorResponse"] = map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"code":    map[string]any{"type": "string"},
+			"message": map[string]any{"type": "string"},
+			"error":   map[string]any{"type": []string{"string", "null"}},
+			"details": map[string]any{"type": "array", "items": map[string]any{"$ref": "#/components/schemas/FieldError"}},
+		},
+		"required": []string{"code", "message"},
+	}
+
+	paths := map[string]any{}
+	for _, route := range api.routes {
+		path := matchGinPathParam.ReplaceAllString(route.path, "{$1}")
+		item, ok := paths[path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.method)] = api.operation(builder, route)
+	}
+
+	document := map[string]any{
+		"openapi":    "3.1.0",
+		"info":       api.Info,
+		"paths":      paths,
+		"components": map[string]any{"schemas": builder.schemas},
+	}
+	if len(api.Servers) > 0 {
+		servers := []any{}
+		for _, url := range api.Servers {
+			servers = append(servers, map[string]any{"url": url})
+		}
+		document["servers"] = servers
+	}
+	return document
+}
+
+// operation builds the operation object of a route
+func (api *OpenAPI) operation(builder *schemaBuilder, route openAPIRoute) map[string]any {
+	operationId := route.config.operationId
+	if operationId == "" {
+		operationId = route.name
+	}
+	if operationId == "" || strings.HasPrefix(operationId, "func") {
+		operationId = strings.ToLower(route.method) + matchSchemaNameInvalid.ReplaceAllString(route.path, "_")
+	}
+
+	operation := map[string]any{"operationId": operationId}
+	if route.config.summary != "" {
+		operation["summary"] = route.config.summary
+	}
+	if route.config.description != "" {
+		operation["description"] = route.config.description
+	}
+	if len(route.config.tags) > 0 {
+		operation["tags"] = route.config.tags
+	}
+	parameters := builder.requestParameters(route.request)
+	// path parameters must always be declared, also when the request struct does not bind them
+	for _, match := range matchGinPathParam.FindAllStringSubmatch(route.path, -1) {
+		declared := false
+		for _, parameter := range parameters {
+			p := parameter.(map[string]any)
+			declared = declared || (p["in"] == "path" && p["name"] == match[1])
+		}
+		if !declared {
+			parameters = append(parameters, map[string]any{"name": match[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+		}
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	if route.method != http.MethodGet && route.method != http.MethodHead && route.method != http.MethodDelete {
+		if schema := builder.requestBodySchema(route.request); schema != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": schema}},
+			}
+		}
+	}
+
+	dataSchema := map[string]any{}
+	if route.response != reflect.TypeOf(CWSLocalizedResponse{}) {
+		dataSchema = builder.schema(route.response)
+	}
+	success := route.config.response
+	responses := map[string]any{
+		strconv.Itoa(success.StatusCode): map[string]any{
+			"description": http.StatusText(success.StatusCode),
+			"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"code":    map[string]any{"type": "string", "examples": []any{success.LocalCode}},
+					"message": map[string]any{"type": "string", "examples": []any{success.ToMessage()}},
+					"data":    dataSchema,
+				},
+				"required": []string{"code", "message", "data"},
+			}}},
+		},
+	}
+
+	// group the error variants by status, each localization code becomes an example
+	variants := map[int][]CWSLocalizedErrorResponse{}
+	errorResponses := append([]CWSLocalizedErrorResponse{BadRequestErrorResponse, InternalServerErrorResponse}, route.config.errors...)
+	for _, resp := range errorResponses {
+		variants[resp.StatusCode] = append(variants[resp.StatusCode], resp)
+	}
+	for status, resps := range variants {
+		examples := map[string]any{}
+		for _, resp := range resps {
+			examples[string(resp.LocalCode)] = map[string]any{
+				"summary": resp.ToMessage(),
+				"value":   map[string]any{"code": resp.LocalCode, "message": resp.ToMessage()},
+			}
+		}
+		responses[strconv.Itoa(status)] = map[string]any{
+			"description": http.StatusText(status),
+			"content": map[string]any{"application/json": map[string]any{
+				"schema":   map[string]any{"$ref": "#/components/schemas/ErrorResponse"},
+				"examples": examples,
+			}},
+		}
+	}
+	operation["responses"] = responses
+	return operation
+}
+
+// Routes returns the method and path of every registered route, sorted by path
+func (api *OpenAPI) Routes() []string {
+	api.lock.RLock()
+	defer api.lock.RUnlock()
+	routes := []string{}
+	for _, route := range api.routes {
+		routes = append(routes, route.method+" "+route.path)
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// MarshalJSON encodes the OpenAPI document
+func (api *OpenAPI) MarshalJSON() ([]byte, error) {
+	return json.Marshal(api.Document())
+}
+
+// Handler returns a gin handler serving the OpenAPI document as JSON
+func (api *OpenAPI) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, api.Document())
+	}
+}
+
+// ServeOpenAPI serves the OpenAPI document of api at relativePath of router, e.g. "/openapi.json"
+func ServeOpenAPI(router gin.IRoutes, relativePath string, api *OpenAPI) {
+	router.GET(relativePath, api.Handler())
+}
+
+// WriteFile writes the indented OpenAPI document to a file
+func (api *OpenAPI) WriteFile(filePath string) error {
+	content, err := json.MarshalIndent(api.Document(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, append(content, '\n'), 0644)
+}
+
+// WriteOpenAPIFromEnv writes the document to the file named by OPENAPI_OUTPUT and reports whether it was requested
+// Call it after the routes are registered and exit when it returns true, so a go:generate directive
+// such as "//go:generate env OPENAPI_OUTPUT=openapi.json go run ." refreshes the document without starting the server
+func WriteOpenAPIFromEnv(api *OpenAPI) (bool, error) {
+	output := cwsbase.GetEnv(OpenAPIOutputEnv, "")
+	if output == "" {
+		return false, nil
+	}
+	return true, api.WriteFile(output)
+}
@@ -0,0 +1,289 @@
+package cwsutil
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// matchSchemaNameInvalid matches characters not allowed in component names, e.g. brackets of generic types
+var matchSchemaNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// openAPIParameterTags are the struct tags bound from request parameters instead of the body
+var openAPIParameterTags = []struct {
+	tag string
+	in  string
+}{
+	{tag: "uri", in: "path"},
+	{tag: "form", in: "query"},
+	{tag: "header", in: "header"},
+}
+
+// schemaBuilder derives JSON schemas from Go types and collects named structs as components
+type schemaBuilder struct {
+	// schemas are the component schemas by name
+	schemas map[string]any
+	// names are the component names of the struct types seen so far
+	names map[reflect.Type]string
+}
+
+// newSchemaBuilder returns an empty schema builder
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{schemas: map[string]any{}, names: map[reflect.Type]string{}}
+}
+
+// schema returns the schema of t, named structs are added as components and referenced
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.objectSchema(t, nil)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + b.component(t)}
+	}
+	return map[string]any{}
+}
+
+// component registers a named struct type as component schema and returns its name
+func (b *schemaBuilder) component(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+
+	name := matchSchemaNameInvalid.ReplaceAllString(t.Name(), "_")
+	for _, used := range b.names {
+		if used == name {
+			// the same type name in another package, prefix the package name
+			name = path.Base(t.PkgPath()) + "." + name
+			break
+		}
+	}
+	b.names[t] = name
+	// the placeholder stops the recursion of self referencing types
+	b.schemas[name] = map[string]any{}
+	b.schemas[name] = b.objectSchema(t, nil)
+	return name
+}
+
+// objectSchema returns the object schema of the json properties of a struct
+// Fields accepted by skip are left out, e.g. the request parameters of a request struct
+func (b *schemaBuilder) objectSchema(t reflect.Type, skip func(field reflect.StructField) bool) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	b.collectProperties(t, skip, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// collectProperties adds the json properties of the fields of t, embedded structs are flattened as encoding/json does
+func (b *schemaBuilder) collectProperties(t reflect.Type, skip func(field reflect.StructField) bool, properties map[string]any, required *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if skip != nil && skip(field) {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.collectProperties(ft, skip, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := b.schema(field.Type)
+		if isRequired := applyBindingConstraints(schema, field.Type, field.Tag.Get("binding")); isRequired {
+			*required = append(*required, name)
+		}
+		properties[name] = schema
+	}
+}
+
+// requestParameters returns the OpenAPI parameters bound from uri, form and header tags of a request struct
+func (b *schemaBuilder) requestParameters(t reflect.Type) []any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	parameters := []any{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Tag == "" {
+			parameters = append(parameters, b.requestParameters(field.Type)...)
+			continue
+		}
+		for _, p := range openAPIParameterTags {
+			name, _, _ := strings.Cut(field.Tag.Get(p.tag), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			schema := b.schema(field.Type)
+			isRequired := applyBindingConstraints(schema, field.Type, field.Tag.Get("binding"))
+			parameters = append(parameters, map[string]any{
+				"name":     name,
+				"in":       p.in,
+				"required": isRequired || p.in == "path",
+				"schema":   schema,
+			})
+			break
+		}
+	}
+	return parameters
+}
+
+// isRequestParameter reports whether a request struct field is bound from uri, form or header tags
+func isRequestParameter(field reflect.StructField) bool {
+	for _, p := range openAPIParameterTags {
+		if name, _, _ := strings.Cut(field.Tag.Get(p.tag), ","); name != "" && name != "-" {
+			return true
+		}
+	}
+	return false
+}
+
+// requestBodySchema returns the body schema of a request struct, nil if it has no body fields
+// Structs without parameter fields are referenced as components, otherwise the body fields are inlined
+func (b *schemaBuilder) requestBodySchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return b.schema(t)
+	}
+	if len(b.requestParameters(t)) == 0 {
+		if t.NumField() == 0 {
+			return nil
+		}
+		return b.schema(t)
+	}
+
+	schema := b.objectSchema(t, isRequestParameter)
+	if len(schema["properties"].(map[string]any)) == 0 {
+		return nil
+	}
+	return schema
+}
+
+// applyBindingConstraints adds the constraints of validator rules to a schema and reports whether the field is required
+// Rules after dive apply to elements and are ignored
+func applyBindingConstraints(schema map[string]any, t reflect.Type, tag string) bool {
+	required := false
+	kind := validationValueKind(t)
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "dive" {
+			break
+		}
+		switch name {
+		case "required":
+			required = true
+		case "min", "gte":
+			setSchemaBound(schema, kind, param, "minLength", "minItems", "minimum")
+		case "max", "lte":
+			setSchemaBound(schema, kind, param, "maxLength", "maxItems", "maximum")
+		case "gt":
+			setSchemaBound(schema, kind, param, "", "", "exclusiveMinimum")
+		case "lt":
+			setSchemaBound(schema, kind, param, "", "", "exclusiveMaximum")
+		case "len":
+			setSchemaBound(schema, kind, param, "minLength", "minItems", "minimum")
+			setSchemaBound(schema, kind, param, "maxLength", "maxItems", "maximum")
+		case "oneof":
+			values := []any{}
+			for _, v := range strings.Fields(param) {
+				if f, err := strconv.ParseFloat(v, 64); err == nil && kind == "number" {
+					values = append(values, f)
+				} else {
+					values = append(values, v)
+				}
+			}
+			schema["enum"] = values
+		case "email":
+			schema["format"] = "email"
+		case "url", "uri":
+			schema["format"] = "uri"
+		case "uuid", "uuid4":
+			schema["format"] = "uuid"
+		}
+	}
+	return required
+}
+
+// setSchemaBound sets the length, item count or numeric bound keyword matching the value kind
+func setSchemaBound(schema map[string]any, kind string, param string, stringKey string, collectionKey string, numberKey string) {
+	key := map[string]string{"string": stringKey, "collection": collectionKey, "number": numberKey}[kind]
+	if key == "" {
+		return
+	}
+	if kind == "number" {
+		if f, err := strconv.ParseFloat(param, 64); err == nil {
+			schema[key] = f
+		}
+		return
+	}
+	if n, err := strconv.Atoi(param); err == nil {
+		schema[key] = n
+	}
+}
@@ -0,0 +1,118 @@
+package cwsutil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type openAPITestOrder struct {
+	Id    string   `json:"id"`
+	Items []string `json:"items"`
+}
+
+type openAPITestCreateOrder struct {
+	Tenant string   `header:"X-Tenant-Id" binding:"required"`
+	Note   string   `json:"note,omitempty" binding:"max=200"`
+	Items  []string `json:"items" binding:"required,min=1"`
+	Color  string   `json:"color" binding:"oneof=red green"`
+}
+
+func createOpenAPITestOrder(ctx context.Context, req openAPITestCreateOrder) (openAPITestOrder, error) {
+	return openAPITestOrder{Items: req.Items}, nil
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+
+	api := NewOpenAPI(OpenAPIInfo{Title: "Orders", Version: "1.0.0"})
+	r := gin.New()
+	v1 := r.Group("/v1")
+	Register(api, v1, http.MethodPost, "/tenants/:tenant/orders", createOpenAPITestOrder,
+		WithTags("orders"), WithErrorResponses(NotFoundErrorResponse, ConflictErrorResponse))
+	ServeOpenAPI(r, "/openapi.json", api)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationId string `json:"operationId"`
+			Parameters  []struct {
+				Name     string `json:"name"`
+				In       string `json:"in"`
+				Required bool   `json:"required"`
+			} `json:"parameters"`
+			RequestBody struct {
+				Content map[string]struct {
+					Schema struct {
+						Required   []string                  `json:"required"`
+						Properties map[string]map[string]any `json:"properties"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+			Responses map[string]struct {
+				Content map[string]struct {
+					Schema struct {
+						Properties map[string]map[string]any `json:"properties"`
+					} `json:"schema"`
+					Examples map[string]any `json:"examples"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+	op, ok := doc.Paths["/v1/tenants/{tenant}/orders"]["post"]
+	if !ok {
+		t.Fatalf("paths = %v", doc.Paths)
+	}
+	if op.OperationId != "createOpenAPITestOrder" {
+		t.Errorf("operationId = %q", op.OperationId)
+	}
+	if len(op.Parameters) != 2 || op.Parameters[0].Name != "X-Tenant-Id" || op.Parameters[0].In != "header" || !op.Parameters[0].Required ||
+		op.Parameters[1].Name != "tenant" || op.Parameters[1].In != "path" {
+		t.Errorf("parameters = %+v", op.Parameters)
+	}
+
+	body := op.RequestBody.Content["application/json"].Schema
+	if len(body.Required) != 1 || body.Required[0] != "items" {
+		t.Errorf("required = %v", body.Required)
+	}
+	if body.Properties["note"]["maxLength"] != float64(200) || body.Properties["items"]["minItems"] != float64(1) {
+		t.Errorf("properties = %v", body.Properties)
+	}
+	if _, ok := body.Properties["Tenant"]; ok {
+		t.Error("header field documented as body property")
+	}
+
+	success := op.Responses["200"].Content["application/json"].Schema
+	if success.Properties["data"]["$ref"] != "#/components/schemas/openAPITestOrder" {
+		t.Errorf("data schema = %v", success.Properties["data"])
+	}
+	for _, status := range []string{"400", "404", "409", "500"} {
+		if _, ok := op.Responses[status]; !ok {
+			t.Errorf("missing %s response", status)
+		}
+	}
+	if _, ok := doc.Components.Schemas["openAPITestOrder"]; !ok {
+		t.Errorf("schemas = %v", doc.Components.Schemas)
+	}
+}
@@ -0,0 +1,160 @@
+package cwsutil
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"github.com/gin-gonic/gin"
+)
+
+// PaginationConfig configures the query parameters read by ParsePageRequest
+type PaginationConfig struct {
+	// PageKey is the query parameter of the 1-based page number
+	PageKey string
+	// SizeKey is the query parameter of the page size
+	SizeKey string
+	// CursorKey is the query parameter of the cursor token
+	CursorKey string
+	// TotalKey is the query parameter requesting the total number of items, e.g. ?total=true
+	TotalKey string
+}
+
+// DefaultPaginationConfig reads ?page=, ?size=, ?cursor= and ?total=
+var DefaultPaginationConfig = PaginationConfig{
+	PageKey:   "page",
+	SizeKey:   "size",
+	CursorKey: "cursor",
+	TotalKey:  "total",
+}
+
+// ParsePageRequest reads the requested page from the query parameters
+// The size defaults to cwsbase.DefaultPageSize and may not exceed cwsbase.MaxPageSize
+// Returns a 400 Bad Request CWSLocalizedErrorResponse for invalid numbers or cursor tokens
+func ParsePageRequest(c *gin.Context, config ...PaginationConfig) (cwsbase.PageRequest, error) {
+	cfg := DefaultPaginationConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	ctx := requestContext(c)
+	request := cwsbase.NewPageRequest(0)
+	if value := c.Query(cfg.PageKey); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil {
+			return request, pageErrorResponse(ctx, cfg.PageKey, value, "numeric", "")
+		}
+		if page < 1 {
+			return request, pageErrorResponse(ctx, cfg.PageKey, value, "min", "1")
+		}
+		request.Page = page
+	}
+	if value := c.Query(cfg.SizeKey); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return request, pageErrorResponse(ctx, cfg.SizeKey, value, "numeric", "")
+		}
+		if size < 1 {
+			return request, pageErrorResponse(ctx, cfg.SizeKey, value, "min", "1")
+		}
+		if size > cwsbase.MaxPageSize {
+			return request, pageErrorResponse(ctx, cfg.SizeKey, value, "max", strconv.Itoa(cwsbase.MaxPageSize))
+		}
+		request.Size = size
+	}
+	if value := c.Query(cfg.CursorKey); value != "" {
+		cursor, err := cwsbase.DecodeCursor(value)
+		if err != nil {
+			return request, pageErrorResponse(ctx, cfg.CursorKey, value, "cursor", "")
+		}
+		request.Cursor = cursor
+	}
+	if value := c.Query(cfg.TotalKey); value != "" {
+		request.WithTotal, _ = strconv.ParseBool(value)
+	}
+	return request, nil
+}
+
+// pageErrorResponse returns a 400 Bad Request response reporting an invalid pagination parameter as field error
+func pageErrorResponse(ctx context.Context, field string, value string, rule string, param string) CWSLocalizedErrorResponse {
+	kind := "number"
+	if rule == "cursor" {
+		kind = "string"
+	}
+	return BadRequestErrorResponse.
+		EmbedError(fmt.Errorf("invalid %s: %q", field, value)).
+		ErrorDetails([]FieldError{{
+			Field: field,
+			Rule:  rule,
+			Param: param,
+			Message: cwsbase.GetLocalizationMessageWithContext(ctx, getValidationMessageCode(rule), map[string]any{
+				"field": field,
+				"rule":  rule,
+				"param": param,
+				"kind":  kind,
+			}),
+		}})
+}
+
+// PageResponse returns the response of a page: the items as data and the next_cursor, has_more
+// and, when counted, total members added to the envelope
+// The response is OKResponse unless another one is given, e.g. with custom message values
+func PageResponse[T any](page cwsbase.Page[T], response ...CWSLocalizedResponse) CWSLocalizedResponse {
+	resp := OKResponse
+	if len(response) > 0 {
+		resp = response[0]
+	}
+
+	var nextCursor any
+	if page.NextCursor != "" {
+		nextCursor = page.NextCursor
+	}
+	resp = resp.ResponseData(page.Items).
+		Extension("next_cursor", nextCursor).
+		Extension("has_more", page.HasMore)
+	if page.Total != nil {
+		resp = resp.Extension("total", *page.Total)
+	}
+	return resp
+}
+
+// WritePage writes a page with PageResponse
+func WritePage[T any](c *gin.Context, page cwsbase.Page[T], response ...CWSLocalizedResponse) {
+	PageResponse(page, response...).WriteResponse(c)
+}
+
+// PageQuery binds the pagination query parameters in request structs of typed handlers
+// e.g. type ListOrdersRequest struct { cwsutil.PageQuery; Status string `form:"status"` }
+type PageQuery struct {
+	// Page is the 1-based page number used in offset mode
+	Page int `form:"page" json:"-" binding:"omitempty,min=1"`
+	// Size is the page size, it is limited to cwsbase.MaxPageSize
+	Size int `form:"size" json:"-" binding:"omitempty,min=1"`
+	// Cursor is the cursor token of the next page
+	Cursor string `form:"cursor" json:"-"`
+	// Total requests the total number of items
+	Total bool `form:"total" json:"-"`
+}
+
+// PageRequest converts the bound query into a page request, ctx selects the language of the field errors
+// Returns a 400 Bad Request CWSLocalizedErrorResponse like ParsePageRequest if the size exceeds cwsbase.MaxPageSize
+// or the cursor token is invalid
+func (q PageQuery) PageRequest(ctx context.Context) (cwsbase.PageRequest, error) {
+	request := cwsbase.NewPageRequest(q.Size)
+	request.WithTotal = q.Total
+	if q.Size > cwsbase.MaxPageSize {
+		return request, pageErrorResponse(ctx, "size", strconv.Itoa(q.Size), "max", strconv.Itoa(cwsbase.MaxPageSize))
+	}
+	if q.Page > 0 {
+		request.Page = q.Page
+	}
+	if q.Cursor != "" {
+		cursor, err := cwsbase.DecodeCursor(q.Cursor)
+		if err != nil {
+			return request, pageErrorResponse(ctx, "cursor", q.Cursor, "cursor", "")
+		}
+		request.Cursor = cursor
+	}
+	return request, nil
+}
@@ -0,0 +1,117 @@
+package cwsutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"github.com/gin-gonic/gin"
+)
+
+func TestParsePageRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+	t.Setenv("CRYPTO_KEY_HEX", "000102030405060708090a0b0c0d0e0f000102030405060708090a0b0c0d0e0f")
+	t.Setenv("CRYPTO_IV_HEX", "000102030405060708090a0b0c0d0e0f")
+
+	cursor, err := cwsbase.EncodeCursor(map[string]any{"id": "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		expected cwsbase.PageRequest
+		invalid  bool
+	}{
+		{query: "", expected: cwsbase.PageRequest{Page: 1, Size: cwsbase.DefaultPageSize}},
+		{query: "page=3&size=10&total=true", expected: cwsbase.PageRequest{Page: 3, Size: 10, WithTotal: true}},
+		{query: "cursor=" + cursor, expected: cwsbase.PageRequest{Page: 1, Size: cwsbase.DefaultPageSize, Cursor: map[string]any{"id": "42"}}},
+		{query: "page=0", invalid: true},
+		{query: "size=1000", invalid: true},
+		{query: "size=abc", invalid: true},
+		{query: "cursor=bogus", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+
+			actual, err := ParsePageRequest(c)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual.Page != tt.expected.Page || actual.Size != tt.expected.Size || actual.WithTotal != tt.expected.WithTotal ||
+				(actual.Cursor == nil) != (tt.expected.Cursor == nil) || actual.Cursor["id"] != tt.expected.Cursor["id"] {
+				t.Errorf("ParsePageRequest() = %+v, want %+v", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPageQuery(t *testing.T) {
+	InitBasicLocalizationData()
+	t.Setenv("CRYPTO_KEY_HEX", "000102030405060708090a0b0c0d0e0f000102030405060708090a0b0c0d0e0f")
+	t.Setenv("CRYPTO_IV_HEX", "000102030405060708090a0b0c0d0e0f")
+
+	tests := []struct {
+		name  string
+		query PageQuery
+		field string
+		rule  string
+	}{
+		{name: "valid", query: PageQuery{Page: 2, Size: 10}},
+		{name: "size", query: PageQuery{Size: 1000}, field: "size", rule: "max"},
+		{name: "cursor", query: PageQuery{Cursor: "bogus"}, field: "cursor", rule: "cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := tt.query.PageRequest(context.Background())
+			if tt.field == "" {
+				if err != nil || request.Page != tt.query.Page || request.Size != tt.query.Size {
+					t.Errorf("PageRequest() = %+v, %v", request, err)
+				}
+				return
+			}
+			var response CWSLocalizedErrorResponse
+			if !errors.As(err, &response) {
+				t.Fatalf("PageRequest() error = %v", err)
+			}
+			details, _ := response.Details().([]FieldError)
+			if len(details) != 1 || details[0].Field != tt.field || details[0].Rule != tt.rule || details[0].Message == "" {
+				t.Errorf("details = %+v", response.Details())
+			}
+		})
+	}
+}
+
+func TestWritePage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+
+	total := int64(5)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	WritePage(c, cwsbase.Page[int]{Items: []int{1, 2}, NextCursor: "next", HasMore: true, Total: &total})
+
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["next_cursor"] != "next" || body["has_more"] != true || body["total"] != float64(5) || len(body["data"].([]any)) != 2 {
+		t.Errorf("body = %v", body)
+	}
+}
@@ -11,7 +11,11 @@
 package cwsutil
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
 
 	"github.com/codeworks-tw/cwsutil/cwsbase"
 	"github.com/gin-gonic/gin"
@@ -27,10 +31,11 @@ func SetLocalizationData(jsonString string) {
 // ParseBody parses the HTTP request body into the provided data structure using Gin's ShouldBind
 // Supports JSON, XML, YAML, and form data binding based on Content-Type header
 // Returns a CWSLocalizedErrorResponse with 400 Bad Request status if parsing fails
+// Validation failures are listed as localized FieldError entries in the "details" field of the response
 func ParseBody(c *gin.Context, data any) error {
 	err := c.ShouldBind(data)
 	if err != nil {
-		return BadRequestErrorResponse.EmbedError(err)
+		return bindingErrorResponse(c, err)
 	}
 	return nil
 }
@@ -38,34 +43,85 @@ func ParseBody(c *gin.Context, data any) error {
 // ParseQuery parses the HTTP query parameters into the provided data structure using Gin's ShouldBindQuery
 // Automatically converts query string parameters to the appropriate struct fields based on struct tags
 // Returns a CWSLocalizedErrorResponse with 400 Bad Request status if parsing fails
+// Validation failures are listed as localized FieldError entries in the "details" field of the response
 func ParseQuery(c *gin.Context, data any) error {
 	err := c.ShouldBindQuery(data)
 	if err != nil {
-		return BadRequestErrorResponse.EmbedError(err)
+		return bindingErrorResponse(c, err)
 	}
 	return nil
 }
 
 // WrapHandler wraps a function that returns an error into a standard Gin handler
 // This provides unified error handling for all HTTP handlers in the application
-// If the error is a CWSLocalizedErrorResponse, it writes the localized response
-// Otherwise, it writes a 500 Internal Server Error and panics to ensure the error is logged
+// If the error is or wraps a CWSLocalizedErrorResponse, it writes the localized response
+// If the error is registered with RegisterErrorResponse (or a built-in mapping), it writes the registered response
+// Otherwise, it writes a 500 Internal Server Error
+// Panics in fn are recovered and written as a 500 Internal Server Error as well
+// Errors and panics are sent with the request details and stack to the sink configured with cwsbase.SetErrorSink
+// Errors returned after the response was written, e.g. by WriteStream or WriteEvents, are reported but not written again
+// since the response has already started; errors the streaming writers reported themselves are not reported twice,
+// nor are errors of requests the client cancelled
 func WrapHandler(fn func(ctx *gin.Context) error) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		defer recoverHandler(ctx)
+
 		err := fn(ctx)
-		if err != nil {
-			if resp, ok := err.(CWSLocalizedErrorResponse); ok {
-				resp.WriteResponse(ctx)
-				return
-			}
-			InternalServerErrorResponse.EmbedError(err).WriteResponse(ctx)
-			panic(err)
+		if err == nil {
+			return
+		}
+		if !ctx.Writer.Written() {
+			writeErrorResponse(ctx, err)
+			return
+		}
+		if errors.Is(err, context.Canceled) || ctx.Request.Context().Err() != nil {
+			return
+		}
+		if reported, _ := ctx.Value(reportedErrorKey).(error); reported == nil || !errors.Is(err, reported) {
+			reportError(ctx, err, http.StatusInternalServerError, nil, false)
 		}
 	}
 }
 
-// WriteResponse writes a standardized JSON response with localized message
-// The response format includes code, message, and data fields
+// writeErrorResponse writes the localized response of err, the registered response or a 500 Internal Server Error
+func writeErrorResponse(ctx *gin.Context, err error) {
+	var resp CWSLocalizedErrorResponse
+	if errors.As(err, &resp) {
+		resp.WriteResponse(ctx)
+		return
+	}
+	if resp, ok := ResolveErrorResponse(err); ok {
+		resp.WriteResponse(ctx)
+		return
+	}
+	InternalServerErrorResponse.EmbedError(err).WriteResponse(ctx)
+}
+
+// recoverHandler converts a panic of a wrapped handler into a reported, localized 500 response
+// http.ErrAbortHandler is re-panicked since it is the standard way to abort a response
+func recoverHandler(ctx *gin.Context) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	if recovered == http.ErrAbortHandler {
+		panic(recovered)
+	}
+
+	err, ok := recovered.(error)
+	if !ok {
+		err = fmt.Errorf("panic: %v", recovered)
+	}
+	reportError(ctx, err, http.StatusInternalServerError, debug.Stack(), true)
+	ctx.Abort()
+	if !ctx.Writer.Written() {
+		InternalServerErrorResponse.EmbedError(err).writeResponse(ctx, false)
+	}
+}
+
+// WriteResponse writes a standardized JSON response with localized message using the selected ResponseFormatter
+// The standard response format includes code, message, and data fields
+// The message is localized in the language negotiated by LocalizationMiddleware
 // Parameters:
 //   - c: Gin context for writing the HTTP response
 //   - statusCode: HTTP status code to return
@@ -73,10 +129,11 @@ func WrapHandler(fn func(ctx *gin.Context) error) gin.HandlerFunc {
 //   - data: Response payload data
 //   - localEmbeddingStrs: Optional values to embed in the localized message using sprintf formatting
 func WriteResponse(c *gin.Context, statusCode int, localCode cwsbase.LocalizationCode, data any, localEmbeddingStrs ...any) {
-	c.JSON(statusCode, gin.H{
-		"code":    localCode,
-		"message": cwsbase.GetLocalizationMessage(localCode, localEmbeddingStrs...),
-		"data":    data,
+	GetResponseFormatter(c).WriteResponse(c, ResponsePayload{
+		StatusCode: statusCode,
+		LocalCode:  localCode,
+		Message:    cwsbase.GetLocalizationMessageWithContext(requestContext(c), localCode, localEmbeddingStrs...),
+		Data:       data,
 	})
 }
 
@@ -91,48 +148,7 @@ func WriteResponse(c *gin.Context, statusCode int, localCode cwsbase.Localizatio
 //   - cursor: MongoDB cursor containing the data to stream
 //   - localEmbeddingStrs: Optional values to embed in the localized message using sprintf formatting
 //
-// Returns an error if cursor iteration or JSON encoding fails
+// Returns an error if cursor iteration or JSON encoding fails, see WriteStream for the mid-stream error handling
 func WriteResponseWithMongoCursor[T any](c *gin.Context, statusCode int, localCode cwsbase.LocalizationCode, cursor *mongo.Cursor, localEmbeddingStrs ...any) error {
-	c.Writer.WriteHeader(statusCode)
-	c.Writer.Header().Set("Content-Type", "application/json; charset=utf-8")
-
-	header := `{
-		"code": "` + string(localCode) + `",
-		"message": "` + cwsbase.GetLocalizationMessage(localCode, localEmbeddingStrs...) + `",
-		"data": [`
-
-	bottom := `]}`
-
-	// Track whether we need to add comma separators between JSON array elements
-	addComma := false
-	c.Writer.Write([]byte(header))
-	// Iterate through cursor and stream each document as JSON
-	for cursor.Next(c) {
-		if addComma {
-			c.Writer.Write([]byte(",")) // Add comma separator for JSON array
-		}
-
-		if cursor.Err() != nil {
-			return cursor.Err()
-		}
-		// Decode cursor document into the specified type T
-		var t T
-		err := cursor.Decode(&t)
-		if err != nil {
-			return err
-		}
-		// Marshal the decoded document back to JSON for streaming
-		d, err := json.Marshal(t)
-		if err != nil {
-			return err
-		}
-
-		// Write the JSON document directly to the response stream
-		c.Writer.Write(d)
-		addComma = true // Set flag to add comma before next element
-	}
-	// Close the JSON response and flush the buffer to ensure all data is sent
-	c.Writer.Write([]byte(bottom))
-	c.Writer.Flush()
-	return nil
+	return WriteStream(c, statusCode, localCode, NewMongoCursorIterator[T](cursor), localEmbeddingStrs...)
 }
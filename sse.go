@@ -0,0 +1,308 @@
+package cwsutil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultSSEHeartbeat is the interval of the comment lines keeping idle event streams open through proxies
+var DefaultSSEHeartbeat = 15 * time.Second
+
+// SSEEvent is a server-sent event
+// Data is written in the standard envelope: a CWSLocalizedResponse as {code, message, data},
+// a CWSLocalizedErrorResponse as {code, message, error, details} and any other value as data of OKResponse
+// The message is localized in the language of the client the event is sent to
+type SSEEvent struct {
+	// Id identifies the event for Last-Event-ID resume, it is assigned by SSEReplayStore.Append
+	Id string
+	// Event is the event type, browsers dispatch events without type as "message"
+	Event string
+	// Data is the response or value sent as event data
+	Data any
+}
+
+// SSEReplayStore keeps recent events of named streams so reconnecting clients can resume after Last-Event-ID
+// The producer appends every event and sends the returned event, which carries the assigned id, to the clients
+type SSEReplayStore interface {
+	// Append stores an event of a stream and returns it with its assigned id
+	Append(ctx context.Context, stream string, event SSEEvent) (SSEEvent, error)
+	// After returns the stored events of a stream following the event with lastEventId,
+	// all stored events if lastEventId is no longer stored
+	After(ctx context.Context, stream string, lastEventId string) ([]SSEEvent, error)
+}
+
+// MemoryReplayStore is an in-memory SSEReplayStore keeping the latest events of each stream
+// Ids are increasing numbers per stream, it suits single instance deployments and tests
+type MemoryReplayStore struct {
+	// Capacity is the number of events kept per stream, 100 when not positive
+	Capacity int
+
+	lock    sync.Mutex
+	streams map[string]*memoryReplayStream
+}
+
+// memoryReplayStream holds the retained events of one stream
+type memoryReplayStream struct {
+	lastId uint64
+	events []SSEEvent
+}
+
+// NewMemoryReplayStore returns an in-memory replay store keeping capacity events per stream
+func NewMemoryReplayStore(capacity int) *MemoryReplayStore {
+	return &MemoryReplayStore{Capacity: capacity}
+}
+
+// Append stores an event and assigns the next id of the stream, the oldest event is dropped when the stream is full
+func (s *MemoryReplayStore) Append(ctx context.Context, stream string, event SSEEvent) (SSEEvent, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.streams == nil {
+		s.streams = map[string]*memoryReplayStream{}
+	}
+	st, ok := s.streams[stream]
+	if !ok {
+		st = &memoryReplayStream{}
+		s.streams[stream] = st
+	}
+
+	capacity := s.Capacity
+	if capacity <= 0 {
+		capacity = 100
+	}
+	st.lastId++
+	event.Id = strconv.FormatUint(st.lastId, 10)
+	st.events = append(st.events, event)
+	if len(st.events) > capacity {
+		st.events = append([]SSEEvent{}, st.events[len(st.events)-capacity:]...)
+	}
+	return event, nil
+}
+
+// After returns the events of a stream following lastEventId
+func (s *MemoryReplayStore) After(ctx context.Context, stream string, lastEventId string) ([]SSEEvent, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	st, ok := s.streams[stream]
+	if !ok {
+		return []SSEEvent{}, nil
+	}
+	for i, event := range st.events {
+		if event.Id == lastEventId {
+			return append([]SSEEvent{}, st.events[i+1:]...), nil
+		}
+	}
+	return append([]SSEEvent{}, st.events...), nil
+}
+
+// SSEOption configures WriteEvents
+type SSEOption func(*sseConfig)
+
+// sseConfig holds the options of an event stream
+type sseConfig struct {
+	heartbeat time.Duration
+	retry     time.Duration
+	store     SSEReplayStore
+	stream    string
+}
+
+// WithSSEHeartbeat sets the heartbeat interval, zero disables heartbeats
+func WithSSEHeartbeat(interval time.Duration) SSEOption {
+	return func(c *sseConfig) {
+		c.heartbeat = interval
+	}
+}
+
+// WithSSERetry tells the browser how long to wait before reconnecting
+func WithSSERetry(retry time.Duration) SSEOption {
+	return func(c *sseConfig) {
+		c.retry = retry
+	}
+}
+
+// WithSSEReplay resumes the named stream from store when the client sends Last-Event-ID
+func WithSSEReplay(store SSEReplayStore, stream string) SSEOption {
+	return func(c *sseConfig) {
+		c.store = store
+		c.stream = stream
+	}
+}
+
+// WriteEvents streams the events of a channel as text/event-stream until the channel is closed or the client disconnects
+// With WithSSEReplay, the events the client missed after its Last-Event-ID header (or ?lastEventId=) are sent first,
+// events of the channel that were already replayed are skipped
+// A comment line is sent every heartbeat interval, DefaultSSEHeartbeat unless set with WithSSEHeartbeat
+// A client disconnect ends the stream normally and returns nil
+func WriteEvents(c *gin.Context, events <-chan SSEEvent, opts ...SSEOption) error {
+	config := sseConfig{heartbeat: DefaultSSEHeartbeat}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	reqCtx := requestContext(c)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// disables response buffering of nginx
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if config.retry > 0 {
+		c.Writer.WriteString("retry: " + strconv.FormatInt(config.retry.Milliseconds(), 10) + "\n\n")
+	}
+	c.Writer.Flush()
+
+	replayed := map[string]bool{}
+	if config.store != nil {
+		lastEventId := c.GetHeader("Last-Event-ID")
+		if lastEventId == "" {
+			lastEventId = c.Query("lastEventId")
+		}
+		if lastEventId != "" {
+			missed, err := config.store.After(reqCtx, config.stream, lastEventId)
+			if err != nil {
+				reportError(c, err, http.StatusInternalServerError, nil, false)
+			}
+			for _, event := range missed {
+				if err := writeSSEEvent(c, event); err != nil {
+					return err
+				}
+				replayed[event.Id] = true
+			}
+			c.Writer.Flush()
+		}
+	}
+
+	var heartbeat <-chan time.Time
+	if config.heartbeat > 0 {
+		ticker := time.NewTicker(config.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-reqCtx.Done():
+			return nil
+		case <-heartbeat:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if event.Id != "" && replayed[event.Id] {
+				continue
+			}
+			if err := writeSSEEvent(c, event); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// WriteEventStream streams the events of an iterator as text/event-stream, see WriteEvents
+// If the iteration fails, the error is reported and an "error" event with the 500 response ends the stream
+// The iterator is closed when the function returns
+func WriteEventStream(c *gin.Context, it StreamIterator[SSEEvent], opts ...SSEOption) error {
+	ctx, cancel := context.WithCancel(requestContext(c))
+	defer cancel()
+
+	events := make(chan SSEEvent)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(events)
+		defer it.Close()
+		send := func(event SSEEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		var err error
+		for err == nil && it.Next(ctx) {
+			var event SSEEvent
+			if event, err = it.Value(); err == nil && !send(event) {
+				return
+			}
+		}
+		if err == nil {
+			err = it.Err()
+		}
+		if err != nil && ctx.Err() == nil {
+			reportError(c, err, http.StatusInternalServerError, nil, false)
+			send(SSEEvent{Event: "error", Data: InternalServerErrorResponse})
+		}
+	}()
+
+	err := WriteEvents(c, events, opts...)
+	cancel()
+	<-done
+	return err
+}
+
+// writeSSEEvent writes one event, the data is the JSON envelope on a single line
+func writeSSEEvent(c *gin.Context, event SSEEvent) error {
+	data, err := json.Marshal(sseEnvelope(requestContext(c), event.Data))
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	if event.Id != "" {
+		b.WriteString("id: " + sseField(event.Id) + "\n")
+	}
+	if event.Event != "" {
+		b.WriteString("event: " + sseField(event.Event) + "\n")
+	}
+	b.WriteString("data: ")
+	b.Write(data)
+	b.WriteString("\n\n")
+	_, err = c.Writer.WriteString(b.String())
+	return err
+}
+
+// sseField removes line breaks, which would end the field and corrupt the event
+func sseField(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// sseEnvelope returns the standard envelope of event data with the message localized in the language of ctx
+func sseEnvelope(ctx context.Context, data any) gin.H {
+	switch resp := data.(type) {
+	case CWSLocalizedErrorResponse:
+		body := responseExtensions(ResponsePayload{Extensions: resp.extensions})
+		body["code"] = resp.LocalCode
+		body["message"] = resp.ToLocalizedMessage(ctx)
+		body["error"] = nil
+		if resp.err != nil && (resp.StatusCode < 500 || isDebugMode()) {
+			body["error"] = resp.err.Error()
+		}
+		if resp.details != nil {
+			body["details"] = resp.details
+		}
+		if requestId := cwsbase.RequestIdFromContext(ctx); requestId != "" {
+			body["request_id"] = requestId
+		}
+		return body
+	case CWSLocalizedResponse:
+		body := responseExtensions(ResponsePayload{Extensions: resp.extensions})
+		body["code"] = resp.LocalCode
+		body["message"] = resp.ToLocalizedMessage(ctx)
+		body["data"] = resp.data
+		return body
+	}
+	return sseEnvelope(ctx, OKResponse.ResponseData(data))
+}
@@ -0,0 +1,127 @@
+package cwsutil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWriteEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+
+	store := NewMemoryReplayStore(2)
+	ctx := context.Background()
+	var stored []SSEEvent
+	for _, progress := range []int{10, 20, 30} {
+		event, err := store.Append(ctx, "job-1", SSEEvent{Event: "progress", Data: progress})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored = append(stored, event)
+	}
+
+	tests := []struct {
+		name        string
+		lastEventId string
+		live        []SSEEvent
+		expected    []string
+		unexpected  []string
+	}{
+		{
+			name:     "live",
+			live:     []SSEEvent{{Event: "done", Data: NotFoundErrorResponse}},
+			expected: []string{"event: done\ndata: {\"code\":\"404\",\"error\":null,\"message\":"},
+		},
+		{
+			name:        "resume",
+			lastEventId: "2",
+			live:        []SSEEvent{stored[2], {Data: OKResponse.ResponseData(gin.H{"quote": "\"\n"})}},
+			expected:    []string{"id: 3\nevent: progress\ndata: {\"code\":\"200\",\"data\":30,", "data: {\"code\":\"200\",\"data\":{\"quote\":\"\\\"\\n\"}"},
+			unexpected:  []string{"id: 2\n"},
+		},
+		{
+			name:        "evicted",
+			lastEventId: "1",
+			expected:    []string{"id: 2\n", "id: 3\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.lastEventId != "" {
+				c.Request.Header.Set("Last-Event-ID", tt.lastEventId)
+			}
+
+			events := make(chan SSEEvent, len(tt.live))
+			for _, event := range tt.live {
+				events <- event
+			}
+			close(events)
+
+			if err := WriteEvents(c, events, WithSSEReplay(store, "job-1"), WithSSERetry(time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			body := w.Body.String()
+			if w.Header().Get("Content-Type") != "text/event-stream" || !strings.HasPrefix(body, "retry: 1000\n\n") {
+				t.Fatalf("headers = %v, body = %q", w.Header(), body)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(body, expected) {
+					t.Errorf("body %q does not contain %q", body, expected)
+				}
+			}
+			for _, unexpected := range tt.unexpected {
+				if strings.Contains(body, unexpected) {
+					t.Errorf("body %q contains %q", body, unexpected)
+				}
+			}
+			if strings.Count(body, "id: 3\n") > 1 {
+				t.Errorf("replayed event sent twice: %q", body)
+			}
+		})
+	}
+}
+
+func TestWriteEventsHeartbeatAndDisconnect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+	// the channel is never closed, the stream ends when the client disconnects
+	if err := WriteEvents(c, make(chan SSEEvent), WithSSEHeartbeat(10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(w.Body.String(), ": heartbeat\n\n") {
+		t.Errorf("body %q has no heartbeat", w.Body.String())
+	}
+}
+
+func TestWriteEventStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	it := NewSliceIterator([]SSEEvent{{Event: "progress", Data: 50}, {Event: "progress", Data: 100}})
+	if err := WriteEventStream(c, it); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(w.Body.String(), "event: progress\n") != 2 {
+		t.Errorf("body = %q", w.Body.String())
+	}
+}
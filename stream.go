@@ -0,0 +1,168 @@
+package cwsutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// StreamErrorTrailer is the HTTP trailer holding the localization code of an error that occurred mid-stream
+const StreamErrorTrailer = "X-Stream-Error"
+
+// streamFlushInterval is the number of items written between flushes of the response
+const streamFlushInterval = 100
+
+// StreamIterator iterates the items of a streamed response without loading them all into memory
+// cwssql.RowIterator and cwsaws.DynamoDBQueryIterator implement it, NewMongoCursorIterator adapts a *mongo.Cursor
+// and NewSliceIterator adapts a slice
+type StreamIterator[T any] interface {
+	// Next advances to the next item and reports whether there is one, ctx is canceled when the client disconnects
+	Next(ctx context.Context) bool
+	// Value decodes the current item
+	Value() (T, error)
+	// Err returns the error that stopped the iteration, nil at the regular end
+	Err() error
+	// Close releases the underlying resources
+	Close() error
+}
+
+// mongoCursorIterator adapts a *mongo.Cursor to StreamIterator
+type mongoCursorIterator[T any] struct {
+	cursor *mongo.Cursor
+}
+
+// NewMongoCursorIterator adapts a MongoDB cursor to StreamIterator, each document is decoded into T
+func NewMongoCursorIterator[T any](cursor *mongo.Cursor) StreamIterator[T] {
+	return &mongoCursorIterator[T]{cursor: cursor}
+}
+
+// Next advances the cursor
+func (it *mongoCursorIterator[T]) Next(ctx context.Context) bool {
+	return it.cursor.Next(ctx)
+}
+
+// Value decodes the current document
+func (it *mongoCursorIterator[T]) Value() (T, error) {
+	var t T
+	err := it.cursor.Decode(&t)
+	return t, err
+}
+
+// Err returns the cursor error
+func (it *mongoCursorIterator[T]) Err() error {
+	return it.cursor.Err()
+}
+
+// Close closes the cursor
+func (it *mongoCursorIterator[T]) Close() error {
+	return it.cursor.Close(context.Background())
+}
+
+// sliceIterator adapts a slice to StreamIterator
+type sliceIterator[T any] struct {
+	items []T
+	index int
+}
+
+// NewSliceIterator adapts items already in memory to StreamIterator, e.g. to export the result of GetAll
+func NewSliceIterator[T any](items []T) StreamIterator[T] {
+	return &sliceIterator[T]{items: items}
+}
+
+// Next advances to the next item
+func (it *sliceIterator[T]) Next(ctx context.Context) bool {
+	if ctx.Err() != nil || it.index >= len(it.items) {
+		return false
+	}
+	it.index++
+	return true
+}
+
+// Value returns the current item
+func (it *sliceIterator[T]) Value() (T, error) {
+	return it.items[it.index-1], nil
+}
+
+// Err returns nil, iterating a slice cannot fail
+func (it *sliceIterator[T]) Err() error {
+	return nil
+}
+
+// Close releases the items
+func (it *sliceIterator[T]) Close() error {
+	it.items = nil
+	return nil
+}
+
+// WriteStream streams the items of an iterator as the data array of the standard {code, message, data} envelope
+// The items are encoded one by one and flushed regularly, so large results are never held in memory
+// If the iteration fails after the response started, the status can no longer change:
+// the array is closed, an "error" member with the localized 500 code and message is appended
+// and the code is also sent in the X-Stream-Error trailer
+// The iteration stops when the client disconnects
+// The iterator is closed when the function returns
+// Returns the error that stopped the stream, if any
+func WriteStream[T any](c *gin.Context, statusCode int, localCode cwsbase.LocalizationCode, it StreamIterator[T], localEmbeddingStrs ...any) error {
+	defer it.Close()
+	reqCtx := requestContext(c)
+
+	code, _ := json.Marshal(localCode)
+	message, _ := json.Marshal(cwsbase.GetLocalizationMessageWithContext(reqCtx, localCode, localEmbeddingStrs...))
+
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Header("Trailer", StreamErrorTrailer)
+	c.Status(statusCode)
+	c.Writer.Write([]byte(`{"code":` + string(code) + `,"message":` + string(message) + `,"data":[`))
+
+	count := 0
+	err := func() error {
+		for it.Next(reqCtx) {
+			if err := reqCtx.Err(); err != nil {
+				return err
+			}
+			item, err := it.Value()
+			if err != nil {
+				return err
+			}
+			d, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				c.Writer.Write([]byte(","))
+			}
+			c.Writer.Write(d)
+			count++
+			if count%streamFlushInterval == 0 {
+				c.Writer.Flush()
+			}
+		}
+		if err := it.Err(); err != nil {
+			return err
+		}
+		return reqCtx.Err()
+	}()
+
+	if err == nil {
+		c.Writer.Write([]byte(`]}`))
+		c.Writer.Flush()
+		return nil
+	}
+
+	if errors.Is(err, context.Canceled) {
+		// the client is gone, there is nobody to write the rest of the response to
+		return err
+	}
+	reportError(c, err, http.StatusInternalServerError, nil, false)
+	errorCode, _ := json.Marshal(LocalCode_InternalServerError)
+	errorMessage, _ := json.Marshal(cwsbase.GetLocalizationMessageWithContext(reqCtx, LocalCode_InternalServerError))
+	c.Writer.Write([]byte(`],"error":{"code":` + string(errorCode) + `,"message":` + string(errorMessage) + `}}`))
+	c.Writer.Header().Set(StreamErrorTrailer, string(LocalCode_InternalServerError))
+	c.Writer.Flush()
+	return err
+}
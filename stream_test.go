@@ -0,0 +1,121 @@
+package cwsutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// failingIterator is a StreamIterator over a slice, failing at index failAt when it is not negative
+type failingIterator struct {
+	items  []string
+	index  int
+	failAt int
+	closed bool
+}
+
+func (it *failingIterator) Next(ctx context.Context) bool {
+	if ctx.Err() != nil || it.index >= len(it.items) {
+		return false
+	}
+	it.index++
+	return true
+}
+
+func (it *failingIterator) Value() (string, error) {
+	if it.index-1 == it.failAt {
+		return "", errors.New("decode failed")
+	}
+	return it.items[it.index-1], nil
+}
+
+func (it *failingIterator) Err() error {
+	return nil
+}
+
+func (it *failingIterator) Close() error {
+	it.closed = true
+	return nil
+}
+
+func TestWriteStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+
+	tests := []struct {
+		name     string
+		items    []string
+		failAt   int
+		cancel   bool
+		expected []string
+		failed   bool
+	}{
+		{name: "empty", items: []string{}, failAt: -1, expected: []string{}},
+		{name: "escaped", items: []string{`a"b`, "c\nd"}, failAt: -1, expected: []string{`a"b`, "c\nd"}},
+		{name: "mid-stream error", items: []string{"a", "b", "c"}, failAt: 1, expected: []string{"a"}, failed: true},
+		{name: "client disconnected", items: []string{"a"}, failAt: -1, cancel: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+			it := &failingIterator{items: tt.items, failAt: tt.failAt}
+			err := WriteStream[string](c, http.StatusOK, LocalCode_OK, it)
+			if !it.closed {
+				t.Error("iterator not closed")
+			}
+			if tt.cancel {
+				if !errors.Is(err, context.Canceled) {
+					t.Fatalf("WriteStream() error = %v, want context.Canceled", err)
+				}
+				return
+			}
+			if (err != nil) != tt.failed {
+				t.Fatalf("WriteStream() error = %v, failed %v", err, tt.failed)
+			}
+			if w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+				t.Errorf("Content-Type = %q", w.Header().Get("Content-Type"))
+			}
+
+			var body struct {
+				Code  string   `json:"code"`
+				Data  []string `json:"data"`
+				Error *struct {
+					Code string `json:"code"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON %q: %v", w.Body.String(), err)
+			}
+			if body.Code != string(LocalCode_OK) || len(body.Data) != len(tt.expected) {
+				t.Fatalf("body = %s", w.Body.String())
+			}
+			for i := range tt.expected {
+				if body.Data[i] != tt.expected[i] {
+					t.Errorf("data[%d] = %q, want %q", i, body.Data[i], tt.expected[i])
+				}
+			}
+			if tt.failed {
+				if body.Error == nil || body.Error.Code != string(LocalCode_InternalServerError) {
+					t.Errorf("error member = %+v", body.Error)
+				}
+				if w.Header().Get(StreamErrorTrailer) != string(LocalCode_InternalServerError) {
+					t.Errorf("trailer = %q", w.Header().Get(StreamErrorTrailer))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package cwsutil
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/codeworks-tw/cwsutil/cwsbase"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Localization codes of field validation messages
+// Messages receive the named values {field}, {rule}, {param} and {kind} ("string", "collection", "number" or "other")
+const (
+	// LocalCode_ValidationInvalid is used for validator tags without a registered message
+	LocalCode_ValidationInvalid cwsbase.LocalizationCode = "validation.invalid"
+	// LocalCode_ValidationRequired is used for the required tag
+	LocalCode_ValidationRequired cwsbase.LocalizationCode = "validation.required"
+	// LocalCode_ValidationMin is used for the min tag
+	LocalCode_ValidationMin cwsbase.LocalizationCode = "validation.min"
+	// LocalCode_ValidationMax is used for the max tag
+	LocalCode_ValidationMax cwsbase.LocalizationCode = "validation.max"
+	// LocalCode_ValidationLen is used for the len tag
+	LocalCode_ValidationLen cwsbase.LocalizationCode = "validation.len"
+	// LocalCode_ValidationEmail is used for the email tag
+	LocalCode_ValidationEmail cwsbase.LocalizationCode = "validation.email"
+	// LocalCode_ValidationOneOf is used for the oneof tag
+	LocalCode_ValidationOneOf cwsbase.LocalizationCode = "validation.oneof"
+	// LocalCode_ValidationGt is used for the gt tag
+	LocalCode_ValidationGt cwsbase.LocalizationCode = "validation.gt"
+	// LocalCode_ValidationGte is used for the gte tag
+	LocalCode_ValidationGte cwsbase.LocalizationCode = "validation.gte"
+	// LocalCode_ValidationLt is used for the lt tag
+	LocalCode_ValidationLt cwsbase.LocalizationCode = "validation.lt"
+	// LocalCode_ValidationLte is used for the lte tag
+	LocalCode_ValidationLte cwsbase.LocalizationCode = "validation.lte"
+	// LocalCode_ValidationUrl is used for the url tag
+	LocalCode_ValidationUrl cwsbase.LocalizationCode = "validation.url"
+	// LocalCode_ValidationUuid is used for the uuid tag
+	LocalCode_ValidationUuid cwsbase.LocalizationCode = "validation.uuid"
+	// LocalCode_ValidationNumeric is used for the numeric tag
+	LocalCode_ValidationNumeric cwsbase.LocalizationCode = "validation.numeric"
+)
+
+// FieldError describes a single failed validation rule of a request field
+type FieldError struct {
+	// Field is the path of the field using json (or form/uri/header) tag names, e.g. "items[0].name"
+	Field string `json:"field"`
+	// Rule is the validator tag that failed, e.g. "required" or "min"
+	Rule string `json:"rule"`
+	// Param is the parameter of the rule, e.g. "3" for min=3
+	Param string `json:"param,omitempty"`
+	// Message is the localized description of the failure
+	Message string `json:"message"`
+}
+
+var validationMessageLock sync.RWMutex
+
+// validationMessages maps validator tags to the localization codes of their messages
+var validationMessages = map[string]cwsbase.LocalizationCode{
+	"required": LocalCode_ValidationRequired,
+	"min":      LocalCode_ValidationMin,
+	"max":      LocalCode_ValidationMax,
+	"len":      LocalCode_ValidationLen,
+	"email":    LocalCode_ValidationEmail,
+	"oneof":    LocalCode_ValidationOneOf,
+	"gt":       LocalCode_ValidationGt,
+	"gte":      LocalCode_ValidationGte,
+	"lt":       LocalCode_ValidationLt,
+	"lte":      LocalCode_ValidationLte,
+	"url":      LocalCode_ValidationUrl,
+	"uuid":     LocalCode_ValidationUuid,
+	"numeric":  LocalCode_ValidationNumeric,
+}
+
+var registerValidationOnce sync.Once
+
+// RegisterValidation makes gin's validator report json, form, uri or header tag names instead of Go struct field names,
+// so FieldError.Field matches the request; InitBasicLocalizationData calls it, it changes the field names of every binding
+func RegisterValidation() {
+	registerValidationOnce.Do(func() {
+		if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+			v.RegisterTagNameFunc(validationFieldName)
+		}
+	})
+}
+
+// RegisterValidationMessage maps a validator tag (including custom ones) to the localization code of its message
+// The message receives the named values {field}, {rule}, {param} and {kind}
+func RegisterValidationMessage(tag string, code cwsbase.LocalizationCode) {
+	validationMessageLock.Lock()
+	defer validationMessageLock.Unlock()
+	validationMessages[tag] = code
+}
+
+// getValidationMessageCode returns the localization code registered for a validator tag
+func getValidationMessageCode(tag string) cwsbase.LocalizationCode {
+	validationMessageLock.RLock()
+	defer validationMessageLock.RUnlock()
+	if code, ok := validationMessages[tag]; ok {
+		return code
+	}
+	return LocalCode_ValidationInvalid
+}
+
+// validationFieldName returns the name reported for a struct field: the json, form, uri or header tag name, or the Go name
+func validationFieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri", "header"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// validationValueKind classifies the validated value for messages that differ between strings, collections and numbers
+func validationValueKind(t reflect.Type) string {
+	if t == nil {
+		return "other"
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "collection"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return "other"
+}
+
+// ValidationFieldErrors converts validator errors into localized field errors using the language carried by ctx
+// Returns false if err does not contain validation errors, e.g. for malformed JSON
+func ValidationFieldErrors(ctx context.Context, err error) ([]FieldError, bool) {
+	var errs []error
+	var sliceErrs binding.SliceValidationError
+	if errors.As(err, &sliceErrs) {
+		errs = sliceErrs
+	} else {
+		errs = []error{err}
+	}
+
+	fields := []FieldError{}
+	for _, e := range errs {
+		var validationErrs validator.ValidationErrors
+		if !errors.As(e, &validationErrs) {
+			continue
+		}
+		for _, fe := range validationErrs {
+			// drop the root struct name from the namespace, e.g. "CreateOrder.items[0].name" becomes "items[0].name"
+			field := fe.Namespace()
+			if _, rest, found := strings.Cut(field, "."); found {
+				field = rest
+			}
+			fields = append(fields, FieldError{
+				Field: field,
+				Rule:  fe.Tag(),
+				Param: fe.Param(),
+				Message: cwsbase.GetLocalizationMessageWithContext(ctx, getValidationMessageCode(fe.Tag()), map[string]any{
+					"field": field,
+					"rule":  fe.Tag(),
+					"param": fe.Param(),
+					"kind":  validationValueKind(fe.Type()),
+				}),
+			})
+		}
+	}
+	return fields, len(fields) > 0
+}
+
+// bindingErrorResponse wraps a binding error into a 400 Bad Request response
+// Validation failures are additionally reported as localized field errors in the response details
+func bindingErrorResponse(ctx *gin.Context, err error) CWSLocalizedErrorResponse {
+	resp := BadRequestErrorResponse.EmbedError(err)
+	if fields, ok := ValidationFieldErrors(requestContext(ctx), err); ok {
+		resp = resp.ErrorDetails(fields)
+	}
+	return resp
+}
@@ -0,0 +1,86 @@
+package cwsutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type validationTestItem struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type validationTestBody struct {
+	Email string               `json:"email" binding:"required,email"`
+	Nick  string               `json:"nick_name" binding:"min=3"`
+	Color string               `json:"color" binding:"oneof=red green"`
+	Items []validationTestItem `json:"items" binding:"min=1,dive"`
+}
+
+func TestParseBodyFieldErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	InitBasicLocalizationData()
+
+	r := gin.New()
+	r.Use(LocalizationMiddleware())
+	r.POST("/", WrapHandler(func(ctx *gin.Context) error {
+		var body validationTestBody
+		return ParseBody(ctx, &body)
+	}))
+
+	tests := []struct {
+		lang     string
+		expected []FieldError
+	}{
+		{
+			lang: "en",
+			expected: []FieldError{
+				{Field: "email", Rule: "email", Message: "email must be a valid email address"},
+				{Field: "nick_name", Rule: "min", Param: "3", Message: "nick_name must be at least 3 characters long"},
+				{Field: "color", Rule: "oneof", Param: "red green", Message: "color must be one of [red green]"},
+				{Field: "items[0].name", Rule: "required", Message: "items[0].name is required"},
+			},
+		},
+		{
+			lang: "zh-TW",
+			expected: []FieldError{
+				{Field: "email", Rule: "email", Message: "email 必須是有效的電子郵件地址"},
+				{Field: "nick_name", Rule: "min", Param: "3", Message: "nick_name 長度至少為 3 個字元"},
+				{Field: "color", Rule: "oneof", Param: "red green", Message: "color 必須是 [red green] 其中之一"},
+				{Field: "items[0].name", Rule: "required", Message: "items[0].name 為必填"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email":"x","nick_name":"ab","color":"blue","items":[{}]}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept-Language", tt.lang)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			var body struct {
+				Details []FieldError `json:"details"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Details) != len(tt.expected) {
+				t.Fatalf("details = %+v, want %+v", body.Details, tt.expected)
+			}
+			for i, e := range tt.expected {
+				if body.Details[i] != e {
+					t.Errorf("details[%d] = %+v, want %+v", i, body.Details[i], e)
+				}
+			}
+		})
+	}
+}